@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// CollectionSpecification describes collection or view returned by
+// listCollections command.
+type CollectionSpecification struct {
+	Name    string         `bson:"name"`
+	Type    string         `bson:"type"`
+	Options bson.Raw       `bson:"options"`
+	Info    CollectionInfo `bson:"info"`
+	IDIndex bson.Raw       `bson:"idIndex,omitempty"`
+}
+
+type CollectionInfo struct {
+	ReadOnly bool             `bson:"readOnly"`
+	UUID     primitive.Binary `bson:"uuid,omitempty"`
+}
+
+type TimeSeriesOptions struct {
+	TimeField   string
+	MetaField   string
+	Granularity string
+}
+
+type CreateCollectionOptions struct {
+	Capped             bool
+	Size               int64
+	Max                int64
+	Validator          interface{}
+	ValidationLevel    string
+	ValidationAction   string
+	Collation          *options.Collation
+	Clustered          bool
+	TimeSeries         *TimeSeriesOptions
+	ExpireAfterSeconds int64
+}
+
+type CreateCollectionOption func(*CreateCollectionOptions)
+
+// CreateCollectionCapped makes collection capped with given size in bytes
+// and maximal number of documents, max is ignored when zero.
+func CreateCollectionCapped(size, max int64) CreateCollectionOption {
+	return func(opts *CreateCollectionOptions) {
+		opts.Capped = true
+		opts.Size = size
+		opts.Max = max
+	}
+}
+
+// CreateCollectionValidator sets validator of collection documents, level
+// and action use server defaults when empty.
+func CreateCollectionValidator(validator interface{}, level, action string) CreateCollectionOption {
+	return func(opts *CreateCollectionOptions) {
+		opts.Validator = validator
+		opts.ValidationLevel = level
+		opts.ValidationAction = action
+	}
+}
+
+func CreateCollectionCollation(collation *options.Collation) CreateCollectionOption {
+	return func(opts *CreateCollectionOptions) {
+		opts.Collation = collation
+	}
+}
+
+// CreateCollectionClustered makes collection clustered by _id, requires
+// MongoDB 5.3 or newer.
+func CreateCollectionClustered() CreateCollectionOption {
+	return func(opts *CreateCollectionOptions) {
+		opts.Clustered = true
+	}
+}
+
+// CreateCollectionTimeSeries makes collection time-series, requires MongoDB
+// 5.0 or newer. Meta field and granularity are optional.
+func CreateCollectionTimeSeries(timeField, metaField, granularity string) CreateCollectionOption {
+	return func(opts *CreateCollectionOptions) {
+		opts.TimeSeries = &TimeSeriesOptions{
+			TimeField:   timeField,
+			MetaField:   metaField,
+			Granularity: granularity,
+		}
+	}
+}
+
+// CreateCollectionExpireAfter sets lifetime of documents of time-series or
+// clustered collection.
+func CreateCollectionExpireAfter(ttl time.Duration) CreateCollectionOption {
+	return func(opts *CreateCollectionOptions) {
+		opts.ExpireAfterSeconds = int64(ttl / time.Second)
+	}
+}
+
+func (m *Mongodb) ListCollectionNames(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.ListCollectionsOptions,
+) ([]string, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	return m.database.ListCollectionNames(ctx, filter, opts...)
+}
+
+func (m *Mongodb) ListCollections(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.ListCollectionsOptions,
+) ([]*CollectionSpecification, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	cursor, err := m.database.ListCollections(ctx, filter, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []*CollectionSpecification
+	err = cursor.All(ctx, &specs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return specs, nil
+}
+
+func (m *Mongodb) CreateCollection(ctx context.Context, name string, options ...CreateCollectionOption) error {
+	opts := &CreateCollectionOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	cmd := bson.D{{Key: "create", Value: name}}
+
+	if opts.Capped {
+		cmd = append(cmd, bson.E{Key: "capped", Value: true}, bson.E{Key: "size", Value: opts.Size})
+
+		if opts.Max > 0 {
+			cmd = append(cmd, bson.E{Key: "max", Value: opts.Max})
+		}
+	}
+
+	if opts.Validator != nil {
+		cmd = append(cmd, bson.E{Key: "validator", Value: opts.Validator})
+	}
+
+	if opts.ValidationLevel != "" {
+		cmd = append(cmd, bson.E{Key: "validationLevel", Value: opts.ValidationLevel})
+	}
+
+	if opts.ValidationAction != "" {
+		cmd = append(cmd, bson.E{Key: "validationAction", Value: opts.ValidationAction})
+	}
+
+	if opts.Collation != nil {
+		cmd = append(cmd, bson.E{Key: "collation", Value: opts.Collation.ToDocument()})
+	}
+
+	if opts.Clustered {
+		index := bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "unique", Value: true}}
+		cmd = append(cmd, bson.E{Key: "clusteredIndex", Value: index})
+	}
+
+	if opts.TimeSeries != nil {
+		ts := bson.D{{Key: "timeField", Value: opts.TimeSeries.TimeField}}
+
+		if opts.TimeSeries.MetaField != "" {
+			ts = append(ts, bson.E{Key: "metaField", Value: opts.TimeSeries.MetaField})
+		}
+
+		if opts.TimeSeries.Granularity != "" {
+			ts = append(ts, bson.E{Key: "granularity", Value: opts.TimeSeries.Granularity})
+		}
+
+		cmd = append(cmd, bson.E{Key: "timeseries", Value: ts})
+	}
+
+	if opts.ExpireAfterSeconds > 0 {
+		cmd = append(cmd, bson.E{Key: "expireAfterSeconds", Value: opts.ExpireAfterSeconds})
+	}
+
+	return m.database.RunCommand(ctx, cmd).Err()
+}
+
+// CreateView creates read-only view of the source collection with documents
+// produced by the aggregation pipeline.
+func (m *Mongodb) CreateView(
+	ctx context.Context,
+	name string,
+	source string,
+	pipeline interface{},
+	collation *options.Collation,
+) error {
+	if pipeline == nil {
+		pipeline = bson.A{}
+	}
+
+	cmd := bson.D{
+		{Key: "create", Value: name},
+		{Key: "viewOn", Value: source},
+		{Key: "pipeline", Value: pipeline},
+	}
+
+	if collation != nil {
+		cmd = append(cmd, bson.E{Key: "collation", Value: collation.ToDocument()})
+	}
+
+	return m.database.RunCommand(ctx, cmd).Err()
+}
+
+// RenameCollection renames collection of the database, existing target
+// collection is dropped when dropTarget is set, otherwise the command fails.
+func (m *Mongodb) RenameCollection(ctx context.Context, from, to string, dropTarget bool) error {
+	cmd := bson.D{
+		{Key: "renameCollection", Value: m.name + "." + from},
+		{Key: "to", Value: m.name + "." + to},
+		{Key: "dropTarget", Value: dropTarget},
+	}
+	err := m.client.Database("admin").RunCommand(ctx, cmd).Err()
+
+	m.forget(from, to)
+
+	return err
+}
+
+// forget removes collections from the cache, so state of collection wrappers
+// is not reused after collection was dropped or renamed.
+func (m *Mongodb) forget(names ...string) {
+	m.mx.Lock()
+
+	for _, name := range names {
+		delete(m.collections, name)
+	}
+
+	m.mx.Unlock()
+
+	var hooks []func()
+	m.hooksMx.Lock()
+
+	for _, name := range names {
+		hooks = append(hooks, m.forgetHooks[name]...)
+		delete(m.forgetHooks, name)
+	}
+
+	m.hooksMx.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// onForget registers function called when the collection is dropped or
+// renamed. Collection wrappers use it to reset their state, it doesn't take
+// the database lock, so it may be called from wrappers.
+func (m *Mongodb) onForget(name string, fn func()) {
+	m.hooksMx.Lock()
+	defer m.hooksMx.Unlock()
+
+	if m.forgetHooks == nil {
+		m.forgetHooks = make(map[string][]func())
+	}
+
+	m.forgetHooks[name] = append(m.forgetHooks[name], fn)
+}
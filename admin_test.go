@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+)
+
+type AdminTestSuite struct {
+	suite.Suite
+	db *Mongodb
+}
+
+func Test_Admin(t *testing.T) {
+	suite.Run(t, new(AdminTestSuite))
+}
+
+func (suite *AdminTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	res, err := db.Collection("stubs").InsertMany(context.Background(), stubs)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+	}
+
+	assert.Len(suite.T(), res.InsertedIDs, len(stubs))
+
+	suite.db = db.(*Mongodb)
+}
+
+func (suite *AdminTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *AdminTestSuite) TestAdmin_CreateCollection_Ok() {
+	ctx := context.Background()
+	validator := bson.M{"$jsonSchema": bson.M{"required": bson.A{"field_string"}}}
+
+	err := suite.db.CreateCollection(ctx, "capped", CreateCollectionCapped(4096, 10))
+	assert.NoError(suite.T(), err)
+
+	err = suite.db.CreateCollection(
+		ctx,
+		"validated",
+		CreateCollectionValidator(validator, "strict", "error"),
+		CreateCollectionCollation(&options.Collation{Locale: "en", Strength: 2}),
+	)
+	assert.NoError(suite.T(), err)
+
+	err = suite.db.CreateView(ctx, "view", "stubs", bson.A{bson.M{"$match": bson.M{"field_string": "value1"}}}, nil)
+	assert.NoError(suite.T(), err)
+
+	names, err := suite.db.ListCollectionNames(ctx, nil)
+	assert.NoError(suite.T(), err)
+	assert.ElementsMatch(suite.T(), []string{"stubs", "capped", "validated", "view"}, names)
+
+	specs, err := suite.db.ListCollections(ctx, bson.M{"name": bson.M{"$in": bson.A{"capped", "view"}}})
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), specs, 2)
+
+	for _, spec := range specs {
+		switch spec.Name {
+		case "capped":
+			assert.Equal(suite.T(), "collection", spec.Type)
+			assert.Equal(suite.T(), true, spec.Options.Lookup("capped").Boolean())
+		case "view":
+			assert.Equal(suite.T(), "view", spec.Type)
+			assert.True(suite.T(), spec.Info.ReadOnly)
+			assert.Equal(suite.T(), "stubs", spec.Options.Lookup("viewOn").StringValue())
+		}
+	}
+
+	_, err = suite.db.Collection("validated").InsertOne(ctx, bson.M{"field_float": 1})
+	assert.Error(suite.T(), err)
+
+	count, err := suite.db.Collection("view").CountDocuments(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, count)
+}
+
+func (suite *AdminTestSuite) TestAdmin_RenameCollection_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	err := suite.db.RenameCollection(ctx, "stubs", "renamed", false)
+	assert.NoError(suite.T(), err)
+	assert.NotSame(suite.T(), collection, suite.db.Collection("stubs"))
+
+	count, err := suite.db.Collection("renamed").EstimatedDocumentCount(ctx)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), len(stubs), count)
+
+	collection = suite.db.Collection("renamed")
+	err = collection.Drop(ctx)
+	assert.NoError(suite.T(), err)
+	assert.NotSame(suite.T(), collection, suite.db.Collection("renamed"))
+
+	names, err := suite.db.ListCollectionNames(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), names)
+}
+
+func (suite *AdminTestSuite) TestAdmin_RenameCollection_Error() {
+	ctx := context.Background()
+
+	err := suite.db.RenameCollection(ctx, "missing", "renamed", false)
+	assert.Error(suite.T(), err)
+}
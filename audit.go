@@ -0,0 +1,646 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	DefaultAuditCollection = "audit"
+
+	AuditOperationInsertOne         = "insertOne"
+	AuditOperationInsertMany        = "insertMany"
+	AuditOperationUpdateOne         = "updateOne"
+	AuditOperationUpdateMany        = "updateMany"
+	AuditOperationReplaceOne        = "replaceOne"
+	AuditOperationDeleteOne         = "deleteOne"
+	AuditOperationDeleteMany        = "deleteMany"
+	AuditOperationFindOneAndDelete  = "findOneAndDelete"
+	AuditOperationFindOneAndReplace = "findOneAndReplace"
+	AuditOperationFindOneAndUpdate  = "findOneAndUpdate"
+)
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor interface{}) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (interface{}, bool) {
+	actor := ctx.Value(actorKey{})
+	return actor, actor != nil
+}
+
+type AuditRecord struct {
+	Actor      interface{} `bson:"actor,omitempty"`
+	Collection string      `bson:"collection"`
+	Operation  string      `bson:"operation"`
+	Filter     interface{} `bson:"filter,omitempty"`
+	Update     interface{} `bson:"update,omitempty"`
+	Before     bson.Raw    `bson:"before,omitempty"`
+	After      interface{} `bson:"after,omitempty"`
+	Matched    int64       `bson:"matched"`
+	Modified   int64       `bson:"modified"`
+	CreatedAt  time.Time   `bson:"created_at"`
+}
+
+type AuditOptions struct {
+	Collection    string
+	Clock         Clock
+	InTransaction bool
+	ErrorHandler  func(ctx context.Context, err error)
+}
+
+type AuditOption func(*AuditOptions)
+
+func AuditCollectionName(name string) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.Collection = name
+	}
+}
+
+func AuditClock(clock Clock) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.Clock = clock
+	}
+}
+
+// AuditInTransaction makes audit records to be written in the transaction of
+// the session passed in the context of the audited operation, so records are
+// rolled back together with changes.
+func AuditInTransaction(val bool) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.InTransaction = val
+	}
+}
+
+// AuditErrorHandler sets handler of audit records writing errors. Without
+// handler such errors are returned from the audited operation.
+func AuditErrorHandler(fn func(ctx context.Context, err error)) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.ErrorHandler = fn
+	}
+}
+
+// AuditCollection writes audit record for every write operation. Single
+// document operations are performed as find-and-modify to capture before and
+// after images of the changed document.
+type AuditCollection struct {
+	CollectionInterface
+	db   Database
+	opts *AuditOptions
+}
+
+func NewAuditCollection(db Database, collection CollectionInterface, options ...AuditOption) *AuditCollection {
+	opts := &AuditOptions{
+		Collection: DefaultAuditCollection,
+		Clock:      SystemClock,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return &AuditCollection{CollectionInterface: collection, db: db, opts: opts}
+}
+
+func Audit(options ...AuditOption) CollectionWrapper {
+	return func(db Database, collection CollectionInterface) CollectionInterface {
+		return NewAuditCollection(db, collection, options...)
+	}
+}
+
+func (m *AuditCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	res, err := m.CollectionInterface.DeleteMany(ctx, filter, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec := m.record(ctx, AuditOperationDeleteMany, filter)
+	rec.Matched = res.DeletedCount
+	rec.Modified = res.DeletedCount
+
+	return res, m.write(ctx, rec)
+}
+
+func (m *AuditCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	opt := options.MergeDeleteOptions(opts...)
+	deleteOpt := options.FindOneAndDelete()
+	deleteOpt.Collation = opt.Collation
+	deleteOpt.Hint = opt.Hint
+
+	before, err := m.CollectionInterface.FindOneAndDelete(ctx, filter, deleteOpt).DecodeBytes()
+
+	if err == mongo.ErrNoDocuments {
+		return &mongo.DeleteResult{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec := m.record(ctx, AuditOperationDeleteOne, filter)
+	rec.Before = before
+	rec.Matched = 1
+	rec.Modified = 1
+
+	return &mongo.DeleteResult{DeletedCount: 1}, m.write(ctx, rec)
+}
+
+func (m *AuditCollection) FindOneAndDelete(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) SingleResultInterface {
+	res := m.CollectionInterface.FindOneAndDelete(ctx, filter, opts...)
+	before, err := res.DecodeBytes()
+
+	if err != nil {
+		return res
+	}
+
+	rec := m.record(ctx, AuditOperationFindOneAndDelete, filter)
+	rec.Before = before
+	rec.Matched = 1
+	rec.Modified = 1
+
+	return m.singleResult(ctx, res, rec)
+}
+
+func (m *AuditCollection) FindOneAndReplace(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) SingleResultInterface {
+	opt := options.MergeFindOneAndReplaceOptions(opts...)
+	res := m.CollectionInterface.FindOneAndReplace(ctx, filter, replacement, opts...)
+	doc, err := res.DecodeBytes()
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return res
+	}
+
+	rec := m.record(ctx, AuditOperationFindOneAndReplace, filter)
+	rec.Update = replacement
+	m.setImage(rec, doc, opt.ReturnDocument)
+
+	if doc == nil && opt.Upsert != nil && *opt.Upsert {
+		if err = m.upsertImage(ctx, rec, filter); err != nil {
+			return &SingleResult{err: err}
+		}
+	}
+
+	return m.singleResult(ctx, res, rec)
+}
+
+func (m *AuditCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	opt := options.MergeFindOneAndUpdateOptions(opts...)
+	res := m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, opts...)
+	doc, err := res.DecodeBytes()
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return res
+	}
+
+	rec := m.record(ctx, AuditOperationFindOneAndUpdate, filter)
+	rec.Update = update
+	m.setImage(rec, doc, opt.ReturnDocument)
+
+	if doc == nil && opt.Upsert != nil && *opt.Upsert {
+		if err = m.upsertImage(ctx, rec, filter); err != nil {
+			return &SingleResult{err: err}
+		}
+	}
+
+	return m.singleResult(ctx, res, rec)
+}
+
+func (m *AuditCollection) InsertMany(
+	ctx context.Context,
+	documents []interface{},
+	opts ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	res, err := m.CollectionInterface.InsertMany(ctx, documents, opts...)
+
+	if res == nil {
+		return nil, err
+	}
+
+	ordered := options.MergeInsertManyOptions(opts...).Ordered
+	written := writtenModels(err, len(documents), ordered == nil || *ordered)
+	records := make([]interface{}, 0, len(documents))
+
+	for i, document := range documents {
+		if !written[i] || i >= len(res.InsertedIDs) {
+			continue
+		}
+
+		rec := m.record(ctx, AuditOperationInsertMany, nil)
+		rec.After = m.inserted(document, res.InsertedIDs[i])
+		rec.Modified = 1
+		records = append(records, rec)
+	}
+
+	return res, m.writeAfter(ctx, err, records...)
+}
+
+func (m *AuditCollection) InsertOne(
+	ctx context.Context,
+	document interface{},
+	opts ...*options.InsertOneOptions,
+) (*mongo.InsertOneResult, error) {
+	res, err := m.CollectionInterface.InsertOne(ctx, document, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec := m.record(ctx, AuditOperationInsertOne, nil)
+	rec.After = m.inserted(document, res.InsertedID)
+	rec.Modified = 1
+
+	return res, m.write(ctx, rec)
+}
+
+func (m *AuditCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	opt := options.MergeReplaceOptions(opts...)
+	replaceOpt := options.FindOneAndReplace().SetReturnDocument(options.Before)
+	replaceOpt.BypassDocumentValidation = opt.BypassDocumentValidation
+	replaceOpt.Collation = opt.Collation
+	replaceOpt.Hint = opt.Hint
+	replaceOpt.Upsert = opt.Upsert
+
+	before, err := m.CollectionInterface.FindOneAndReplace(ctx, filter, replacement, replaceOpt).DecodeBytes()
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	rec := m.record(ctx, AuditOperationReplaceOne, filter)
+	rec.Update = replacement
+
+	return m.updateResult(ctx, rec, filter, before, opt.Upsert != nil && *opt.Upsert)
+}
+
+func (m *AuditCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	res, err := m.CollectionInterface.UpdateMany(ctx, filter, update, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec := m.record(ctx, AuditOperationUpdateMany, filter)
+	rec.Update = update
+	rec.Matched = res.MatchedCount
+	rec.Modified = res.ModifiedCount + res.UpsertedCount
+
+	return res, m.write(ctx, rec)
+}
+
+func (m *AuditCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	opt := options.MergeUpdateOptions(opts...)
+	updateOpt := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	updateOpt.ArrayFilters = opt.ArrayFilters
+	updateOpt.BypassDocumentValidation = opt.BypassDocumentValidation
+	updateOpt.Collation = opt.Collation
+	updateOpt.Hint = opt.Hint
+	updateOpt.Upsert = opt.Upsert
+
+	before, err := m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, updateOpt).DecodeBytes()
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	rec := m.record(ctx, AuditOperationUpdateOne, filter)
+	rec.Update = update
+
+	return m.updateResult(ctx, rec, filter, before, opt.Upsert != nil && *opt.Upsert)
+}
+
+// BulkWrite writes audit record for each successfully executed write model,
+// including ones of partially failed bulk operation. Images of documents are
+// not captured, except inserted documents with their identifiers.
+func (m *AuditCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	models = m.insertModels(models)
+	res, err := m.CollectionInterface.BulkWrite(ctx, models, opts...)
+
+	ordered := options.MergeBulkWriteOptions(opts...).Ordered
+	written := writtenModels(err, len(models), ordered == nil || *ordered)
+	records := make([]interface{}, 0, len(models))
+
+	for i, model := range models {
+		if !written[i] {
+			continue
+		}
+
+		var rec *AuditRecord
+
+		switch v := model.(type) {
+		case *mongo.InsertOneModel:
+			rec = m.record(ctx, AuditOperationInsertOne, nil)
+			rec.After = v.Document
+		case *mongo.UpdateOneModel:
+			rec = m.record(ctx, AuditOperationUpdateOne, v.Filter)
+			rec.Update = v.Update
+		case *mongo.UpdateManyModel:
+			rec = m.record(ctx, AuditOperationUpdateMany, v.Filter)
+			rec.Update = v.Update
+		case *mongo.ReplaceOneModel:
+			rec = m.record(ctx, AuditOperationReplaceOne, v.Filter)
+			rec.Update = v.Replacement
+		case *mongo.DeleteOneModel:
+			rec = m.record(ctx, AuditOperationDeleteOne, v.Filter)
+		case *mongo.DeleteManyModel:
+			rec = m.record(ctx, AuditOperationDeleteMany, v.Filter)
+		default:
+			continue
+		}
+
+		records = append(records, rec)
+	}
+
+	return res, m.writeAfter(ctx, err, records...)
+}
+
+// insertModels sets generated identifiers to documents of insert models
+// without them, so audit records contain identifiers of inserted documents.
+func (m *AuditCollection) insertModels(models []mongo.WriteModel) []mongo.WriteModel {
+	result := make([]mongo.WriteModel, len(models))
+
+	for i, model := range models {
+		result[i] = model
+		v, ok := model.(*mongo.InsertOneModel)
+
+		if !ok {
+			continue
+		}
+
+		doc, err := toDocument(v.Document)
+
+		if err != nil {
+			continue
+		}
+
+		if _, ok := lookup(doc, "_id"); !ok {
+			doc = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, doc...)
+		}
+
+		result[i] = &mongo.InsertOneModel{Document: doc}
+	}
+
+	return result
+}
+
+func (m *AuditCollection) record(ctx context.Context, operation string, filter interface{}) *AuditRecord {
+	actor, _ := ActorFromContext(ctx)
+
+	return &AuditRecord{
+		Actor:      actor,
+		Collection: m.Name(),
+		Operation:  operation,
+		Filter:     filter,
+		CreatedAt:  m.opts.Clock.Now().UTC(),
+	}
+}
+
+// after reads image of the document changed by the write with the before
+// image. Upserted document is unknown for the write, so it is read by the
+// write filter.
+func (m *AuditCollection) after(ctx context.Context, filter interface{}, before bson.Raw) (bson.Raw, error) {
+	if before != nil {
+		filter = bson.D{{Key: "_id", Value: before.Lookup("_id")}}
+	}
+
+	after, err := m.CollectionInterface.FindOne(ctx, filter).DecodeBytes()
+
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	return after, err
+}
+
+func (m *AuditCollection) inserted(document interface{}, id interface{}) interface{} {
+	doc, err := toDocument(document)
+
+	if err != nil {
+		return document
+	}
+
+	if _, ok := lookup(doc, "_id"); !ok {
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+	}
+
+	return doc
+}
+
+func (m *AuditCollection) setImage(rec *AuditRecord, doc bson.Raw, returnDocument *options.ReturnDocument) {
+	if doc == nil {
+		return
+	}
+
+	rec.Matched = 1
+	rec.Modified = 1
+
+	if returnDocument != nil && *returnDocument == options.After {
+		rec.After = doc
+		return
+	}
+
+	rec.Before = doc
+}
+
+// upsertImage sets image of the document inserted by find-and-modify upsert,
+// which returns no document when the before image is requested.
+func (m *AuditCollection) upsertImage(ctx context.Context, rec *AuditRecord, filter interface{}) error {
+	after, err := m.after(ctx, filter, nil)
+
+	if err != nil {
+		return err
+	}
+
+	rec.After = after
+	rec.Modified = 1
+
+	return nil
+}
+
+// updateResult builds result of single document write made with before image
+// returned. Document is upserted only if nothing matched and upsert is
+// requested.
+func (m *AuditCollection) updateResult(
+	ctx context.Context,
+	rec *AuditRecord,
+	filter interface{},
+	before bson.Raw,
+	upsert bool,
+) (*mongo.UpdateResult, error) {
+	res := &mongo.UpdateResult{}
+
+	if before == nil && !upsert {
+		return res, nil
+	}
+
+	after, err := m.after(ctx, filter, before)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if before != nil {
+		res.MatchedCount = 1
+
+		if !bytes.Equal(before, after) {
+			res.ModifiedCount = 1
+		}
+	} else {
+		res.UpsertedCount = 1
+
+		if after != nil {
+			_ = after.Lookup("_id").Unmarshal(&res.UpsertedID)
+		}
+	}
+
+	if res.MatchedCount == 1 && res.ModifiedCount == 0 {
+		return res, nil
+	}
+
+	rec.Before = before
+	rec.After = after
+	rec.Matched = res.MatchedCount
+	rec.Modified = 1
+
+	return res, m.write(ctx, rec)
+}
+
+// writeAfter writes audit records of the operation which failed with err
+// after some writes were applied. Error of the operation takes precedence over
+// error of audit records writing.
+func (m *AuditCollection) writeAfter(ctx context.Context, err error, records ...interface{}) error {
+	auditErr := m.write(ctx, records...)
+
+	if err != nil {
+		return err
+	}
+
+	return auditErr
+}
+
+// writtenModels returns which of n writes of bulk operation are applied
+// despite of its err. Ordered operation stops at the first failed write.
+func writtenModels(err error, n int, ordered bool) []bool {
+	result := make([]bool, n)
+	exception, ok := err.(mongo.BulkWriteException)
+
+	if err != nil && !ok {
+		return result
+	}
+
+	for i := range result {
+		result[i] = true
+	}
+
+	for _, writeErr := range exception.WriteErrors {
+		if writeErr.Index < 0 || writeErr.Index >= n {
+			continue
+		}
+
+		if !ordered {
+			result[writeErr.Index] = false
+			continue
+		}
+
+		for i := writeErr.Index; i < n; i++ {
+			result[i] = false
+		}
+	}
+
+	return result
+}
+
+func (m *AuditCollection) singleResult(ctx context.Context, res SingleResultInterface, rec *AuditRecord) SingleResultInterface {
+	if rec.Modified == 0 && rec.After == nil {
+		return res
+	}
+
+	if err := m.write(ctx, rec); err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return res
+}
+
+func (m *AuditCollection) write(ctx context.Context, records ...interface{}) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if !m.opts.InTransaction {
+		ctx = detachedContext{Context: ctx}
+	}
+
+	_, err := m.db.Collection(m.opts.Collection).InsertMany(ctx, records)
+
+	if err != nil && m.opts.ErrorHandler != nil {
+		m.opts.ErrorHandler(ctx, err)
+		return nil
+	}
+
+	return err
+}
+
+// detachedContext hides session of the parent context, so operations made
+// with it are not part of the parent session transaction.
+type detachedContext struct {
+	context.Context
+}
+
+func (m detachedContext) Value(key interface{}) interface{} {
+	val := m.Context.Value(key)
+
+	if _, ok := val.(mongo.Session); ok {
+		return nil
+	}
+
+	return val
+}
@@ -0,0 +1,326 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	db    Database
+	clock *frozenClock
+}
+
+func Test_Audit(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (suite *AuditTestSuite) SetupTest() {
+	suite.clock = &frozenClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	opts := []Option{
+		Dsn("mongodb://localhost:27017/test"),
+		WrapCollection("stubs", Audit(AuditClock(suite.clock))),
+	}
+	db, err := New(opts...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *AuditTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *AuditTestSuite) records(operation string) []*AuditRecord {
+	ctx := context.Background()
+	cursor, err := suite.db.Collection(DefaultAuditCollection).Find(ctx, bson.M{"operation": operation})
+	assert.NoError(suite.T(), err)
+
+	var records []*AuditRecord
+	err = cursor.All(ctx, &records)
+	assert.NoError(suite.T(), err)
+
+	return records
+}
+
+func (suite *AuditTestSuite) TestAudit_Insert_Ok() {
+	ctx := WithActor(context.Background(), "user1")
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertOne(ctx, bson.M{"_id": 1, "field_string": "value1"})
+	assert.NoError(suite.T(), err)
+
+	res, err := collection.InsertMany(ctx, stubs)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), res.InsertedIDs, len(stubs))
+
+	records := suite.records(AuditOperationInsertOne)
+	assert.Len(suite.T(), records, 1)
+	assert.Equal(suite.T(), "user1", records[0].Actor)
+	assert.Equal(suite.T(), "stubs", records[0].Collection)
+	assert.True(suite.T(), suite.clock.now.Equal(records[0].CreatedAt))
+
+	records = suite.records(AuditOperationInsertMany)
+	assert.Len(suite.T(), records, len(stubs))
+}
+
+func (suite *AuditTestSuite) TestAudit_UpdateOne_Ok() {
+	ctx := WithActor(context.Background(), "user1")
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertOne(ctx, bson.M{"_id": 1, "field_string": "value1"})
+	assert.NoError(suite.T(), err)
+
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": 1}, bson.M{"$set": bson.M{"field_string": "value2"}})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, res.MatchedCount)
+	assert.EqualValues(suite.T(), 1, res.ModifiedCount)
+
+	res, err = collection.UpdateOne(ctx, bson.M{"_id": 1}, bson.M{"$set": bson.M{"field_string": "value2"}})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, res.MatchedCount)
+	assert.EqualValues(suite.T(), 0, res.ModifiedCount)
+
+	res, err = collection.UpdateOne(ctx, bson.M{"_id": 2}, bson.M{"$set": bson.M{"field_string": "value3"}}, options.Update().SetUpsert(true))
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, res.UpsertedCount)
+	assert.EqualValues(suite.T(), 2, res.UpsertedID)
+
+	res, err = collection.UpdateOne(ctx, bson.M{"_id": 3}, bson.M{"$set": bson.M{"field_string": "value3"}})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, res.MatchedCount)
+	assert.EqualValues(suite.T(), 0, res.UpsertedCount)
+
+	records := suite.records(AuditOperationUpdateOne)
+	assert.Len(suite.T(), records, 2)
+	assert.Equal(suite.T(), "value1", records[0].Before.Lookup("field_string").StringValue())
+	assert.Equal(suite.T(), "value2", records[0].After.(bson.D).Map()["field_string"])
+	assert.Nil(suite.T(), records[1].Before)
+}
+
+func (suite *AuditTestSuite) TestAudit_FindOneAndModify_Upsert_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	err := collection.FindOneAndUpdate(ctx, bson.M{"_id": 1}, bson.M{"$set": bson.M{"field_string": "value1"}}, options.FindOneAndUpdate().SetUpsert(true)).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+
+	err = collection.FindOneAndReplace(ctx, bson.M{"_id": 2}, bson.M{"field_string": "value2"}, options.FindOneAndReplace().SetUpsert(true)).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+
+	records := suite.records(AuditOperationFindOneAndUpdate)
+	assert.Len(suite.T(), records, 1)
+	assert.Nil(suite.T(), records[0].Before)
+	assert.Equal(suite.T(), "value1", records[0].After.(bson.D).Map()["field_string"])
+
+	records = suite.records(AuditOperationFindOneAndReplace)
+	assert.Len(suite.T(), records, 1)
+	assert.Equal(suite.T(), "value2", records[0].After.(bson.D).Map()["field_string"])
+}
+
+func (suite *AuditTestSuite) TestAudit_Delete_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertMany(ctx, stubs)
+	assert.NoError(suite.T(), err)
+
+	res, err := collection.DeleteOne(ctx, bson.M{"field_string": "value4"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, res.DeletedCount)
+
+	res, err = collection.DeleteOne(ctx, bson.M{"field_string": "value4"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, res.DeletedCount)
+
+	res, err = collection.DeleteMany(ctx, bson.M{"field_string": "value1"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, res.DeletedCount)
+
+	var stub Stub
+	err = collection.FindOneAndDelete(ctx, bson.M{"field_string": "value2"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "value2", stub.FieldString)
+
+	records := suite.records(AuditOperationDeleteOne)
+	assert.Len(suite.T(), records, 1)
+	assert.Equal(suite.T(), "value4", records[0].Before.Lookup("field_string").StringValue())
+
+	records = suite.records(AuditOperationDeleteMany)
+	assert.Len(suite.T(), records, 1)
+	assert.EqualValues(suite.T(), 3, records[0].Modified)
+
+	records = suite.records(AuditOperationFindOneAndDelete)
+	assert.Len(suite.T(), records, 1)
+	assert.NotNil(suite.T(), records[0].Before)
+}
+
+func (suite *AuditTestSuite) TestAudit_BulkWrite_Ok() {
+	ctx := context.Background()
+	models := []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(bson.M{"_id": 1}),
+		mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": 1}).SetUpdate(bson.M{"$set": bson.M{"field_string": "value"}}),
+		mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": 1}),
+	}
+
+	_, err := suite.db.Collection("stubs").BulkWrite(ctx, models)
+	assert.NoError(suite.T(), err)
+
+	count, err := suite.db.Collection(DefaultAuditCollection).CountDocuments(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, count)
+}
+
+func (suite *AuditTestSuite) TestAudit_PartialFailure_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertMany(ctx, []interface{}{bson.M{"_id": 1}, bson.M{"_id": 1}, bson.M{"_id": 2}})
+	assert.Error(suite.T(), err)
+
+	records := suite.records(AuditOperationInsertMany)
+	assert.Len(suite.T(), records, 1)
+
+	models := []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(bson.M{"field_string": "value"}),
+		mongo.NewInsertOneModel().SetDocument(bson.M{"_id": 1}),
+	}
+	_, err = collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	assert.Error(suite.T(), err)
+
+	records = suite.records(AuditOperationInsertOne)
+	assert.Len(suite.T(), records, 1)
+	assert.Contains(suite.T(), records[0].After.(bson.D).Map(), "_id")
+}
+
+type upsertingCollection struct {
+	CollectionInterface
+	doc     bson.Raw
+	records []interface{}
+}
+
+func (m *upsertingCollection) Name() string {
+	return "stubs"
+}
+
+func (m *upsertingCollection) FindOneAndUpdate(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	return &SingleResult{err: mongo.ErrNoDocuments}
+}
+
+func (m *upsertingCollection) FindOne(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.FindOneOptions,
+) SingleResultInterface {
+	return &cachedSingleResult{raw: m.doc}
+}
+
+func (m *upsertingCollection) InsertMany(
+	_ context.Context,
+	documents []interface{},
+	_ ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	m.records = append(m.records, documents...)
+	return &mongo.InsertManyResult{}, nil
+}
+
+func TestAuditCollection_FindOneAndUpdate_Upsert_Ok(t *testing.T) {
+	ctx := context.Background()
+	doc, err := bson.Marshal(bson.M{"_id": 1, "field_string": "value"})
+	assert.NoError(t, err)
+
+	inner := &upsertingCollection{doc: doc}
+	collection := NewAuditCollection(&recordedDatabase{collection: inner}, inner)
+	update := bson.M{"$set": bson.M{"field_string": "value"}}
+
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": 1}, update).Err()
+	assert.Equal(t, mongo.ErrNoDocuments, err)
+	assert.Empty(t, inner.records)
+
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": 1}, update, options.FindOneAndUpdate().SetUpsert(true)).Err()
+	assert.Equal(t, mongo.ErrNoDocuments, err)
+	assert.Len(t, inner.records, 1)
+
+	rec := inner.records[0].(*AuditRecord)
+	assert.Equal(t, AuditOperationFindOneAndUpdate, rec.Operation)
+	assert.Nil(t, rec.Before)
+	assert.Equal(t, bson.Raw(doc), rec.After)
+	assert.EqualValues(t, 0, rec.Matched)
+	assert.EqualValues(t, 1, rec.Modified)
+}
+
+func TestWrittenModels_Ok(t *testing.T) {
+	exception := mongo.BulkWriteException{
+		WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1}}},
+	}
+
+	assert.Equal(t, []bool{true, true, true}, writtenModels(nil, 3, true))
+	assert.Equal(t, []bool{true, false, false}, writtenModels(exception, 3, true))
+	assert.Equal(t, []bool{true, false, true}, writtenModels(exception, 3, false))
+	assert.Equal(t, []bool{false, false, false}, writtenModels(context.Canceled, 3, false))
+}
+
+func TestAuditCollection_InsertModels_Ok(t *testing.T) {
+	collection := NewAuditCollection(nil, nil)
+	models := []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(bson.M{"field": "value"}),
+		mongo.NewInsertOneModel().SetDocument(bson.M{"_id": 1}),
+		mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": 1}),
+	}
+
+	result := collection.insertModels(models)
+	assert.Len(t, result, 3)
+
+	doc := result[0].(*mongo.InsertOneModel).Document.(bson.D)
+	assert.Equal(t, "_id", doc[0].Key)
+	assert.IsType(t, primitive.ObjectID{}, doc[0].Value)
+	assert.Equal(t, bson.D{{Key: "_id", Value: int32(1)}}, result[1].(*mongo.InsertOneModel).Document)
+	assert.Equal(t, models[2], result[2])
+}
+
+func TestDetachedContext_Ok(t *testing.T) {
+	ctx := WithActor(context.Background(), "user1")
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	assert.NoError(t, err)
+
+	sess, err := client.StartSession()
+	assert.NoError(t, err)
+
+	sessCtx := mongo.NewSessionContext(ctx, sess)
+	assert.NotNil(t, mongo.SessionFromContext(sessCtx))
+
+	detached := detachedContext{Context: sessCtx}
+	assert.Nil(t, mongo.SessionFromContext(detached))
+
+	actor, ok := ActorFromContext(detached)
+	assert.True(t, ok)
+	assert.Equal(t, "user1", actor)
+
+	sess.EndSession(ctx)
+	assert.NoError(t, client.Disconnect(ctx))
+}
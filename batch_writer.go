@@ -0,0 +1,350 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBatchWriterSize     = 1000
+	DefaultBatchWriterBytes    = 16 * 1024 * 1024
+	DefaultBatchWriterInterval = time.Second
+	DefaultBatchWriterQueue    = 10000
+)
+
+var (
+	ErrorBatchWriterClosed = errors.New("batch writer is closed")
+)
+
+// BatchResult is result of the single write model, Err is nil when the model
+// was written successfully.
+type BatchResult struct {
+	Model mongo.WriteModel
+	Err   error
+}
+
+type BatchCallback func(result BatchResult)
+
+type BatchWriterOptions struct {
+	Size     int
+	Bytes    int
+	Interval time.Duration
+	Queue    int
+	Callback BatchCallback
+}
+
+type BatchWriterOption func(*BatchWriterOptions)
+
+// BatchWriterSize sets maximal number of models written with single bulk
+// write.
+func BatchWriterSize(size int) BatchWriterOption {
+	return func(opts *BatchWriterOptions) {
+		opts.Size = size
+	}
+}
+
+// BatchWriterBytes sets maximal approximate size of documents written with
+// single bulk write.
+func BatchWriterBytes(bytes int) BatchWriterOption {
+	return func(opts *BatchWriterOptions) {
+		opts.Bytes = bytes
+	}
+}
+
+// BatchWriterInterval sets interval of flushing not full batches, batches are
+// flushed only by size when interval is zero.
+func BatchWriterInterval(interval time.Duration) BatchWriterOption {
+	return func(opts *BatchWriterOptions) {
+		opts.Interval = interval
+	}
+}
+
+// BatchWriterQueue sets number of models waiting for write, Write blocks when
+// the queue is full.
+func BatchWriterQueue(queue int) BatchWriterOption {
+	return func(opts *BatchWriterOptions) {
+		opts.Queue = queue
+	}
+}
+
+// BatchWriterCallback sets function called with result of every written
+// model. Callback is called from the writer goroutine, so it must not block
+// and must not call Write.
+func BatchWriterCallback(callback BatchCallback) BatchWriterOption {
+	return func(opts *BatchWriterOptions) {
+		opts.Callback = callback
+	}
+}
+
+// BatchWriter collects write models from many goroutines and writes them to
+// the collection with unordered bulk writes in the background.
+type BatchWriter struct {
+	collection CollectionInterface
+	opts       *BatchWriterOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mx      sync.RWMutex
+	closed  bool
+	writers sync.WaitGroup
+	queue   chan mongo.WriteModel
+	closing chan struct{}
+	flushes chan chan struct{}
+	done    chan struct{}
+
+	batch []mongo.WriteModel
+	bytes int
+}
+
+func NewBatchWriter(collection CollectionInterface, options ...BatchWriterOption) *BatchWriter {
+	opts := &BatchWriterOptions{
+		Size:     DefaultBatchWriterSize,
+		Bytes:    DefaultBatchWriterBytes,
+		Interval: DefaultBatchWriterInterval,
+		Queue:    DefaultBatchWriterQueue,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	writer := &BatchWriter{
+		collection: collection,
+		opts:       opts,
+		ctx:        ctx,
+		cancel:     cancel,
+		queue:      make(chan mongo.WriteModel, opts.Queue),
+		closing:    make(chan struct{}),
+		flushes:    make(chan chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go writer.run()
+
+	return writer
+}
+
+// Write adds models to the queue. It blocks while the queue is full until
+// the context is done or the writer is closed.
+func (m *BatchWriter) Write(ctx context.Context, models ...mongo.WriteModel) error {
+	m.mx.RLock()
+
+	if m.closed {
+		m.mx.RUnlock()
+		return ErrorBatchWriterClosed
+	}
+
+	m.writers.Add(1)
+	m.mx.RUnlock()
+
+	defer m.writers.Done()
+
+	for _, model := range models {
+		select {
+		case m.queue <- model:
+		case <-m.closing:
+			return ErrorBatchWriterClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Flush writes models added before the call and waits for completion.
+func (m *BatchWriter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+
+	select {
+	case m.flushes <- done:
+	case <-m.done:
+		return ErrorBatchWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting new models and waits until queued models are
+// written. Writes blocked on the full queue fail with ErrorBatchWriterClosed.
+// When the context is done before, pending writes are cancelled.
+func (m *BatchWriter) Close(ctx context.Context) error {
+	m.mx.Lock()
+
+	if !m.closed {
+		m.closed = true
+		close(m.closing)
+	}
+
+	m.mx.Unlock()
+
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		m.cancel()
+		return ctx.Err()
+	}
+}
+
+func (m *BatchWriter) run() {
+	defer close(m.done)
+	defer m.cancel()
+
+	var tick <-chan time.Time
+
+	if m.opts.Interval > 0 {
+		ticker := time.NewTicker(m.opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case model := <-m.queue:
+			m.add(model)
+		case <-m.closing:
+			// writes in progress return as soon as closing is signalled,
+			// models they have queued are written with the last batch
+			m.writers.Wait()
+			m.drain()
+			m.flush()
+			return
+		case done := <-m.flushes:
+			m.drain()
+			m.flush()
+			close(done)
+		case <-tick:
+			m.flush()
+		}
+	}
+}
+
+// drain moves models already waiting in the queue to the batch.
+func (m *BatchWriter) drain() {
+	for {
+		select {
+		case model := <-m.queue:
+			m.add(model)
+		default:
+			return
+		}
+	}
+}
+
+func (m *BatchWriter) add(model mongo.WriteModel) {
+	size := writeModelSize(model)
+
+	if len(m.batch) > 0 && m.opts.Bytes > 0 && m.bytes+size > m.opts.Bytes {
+		m.flush()
+	}
+
+	m.batch = append(m.batch, model)
+	m.bytes += size
+
+	if (m.opts.Size > 0 && len(m.batch) >= m.opts.Size) || (m.opts.Bytes > 0 && m.bytes >= m.opts.Bytes) {
+		m.flush()
+	}
+}
+
+func (m *BatchWriter) flush() {
+	if len(m.batch) == 0 {
+		return
+	}
+
+	batch := m.batch
+	m.batch = nil
+	m.bytes = 0
+
+	_, err := m.collection.BulkWrite(m.ctx, batch, options.BulkWrite().SetOrdered(false))
+
+	if m.opts.Callback == nil {
+		return
+	}
+
+	errs := bulkWriteErrors(err, len(batch))
+
+	for i, model := range batch {
+		m.opts.Callback(BatchResult{Model: model, Err: errs[i]})
+	}
+}
+
+// bulkWriteErrors maps error of unordered bulk write to errors of single
+// models.
+func bulkWriteErrors(err error, n int) []error {
+	errs := make([]error, n)
+
+	if exception, ok := err.(mongo.BulkWriteException); ok {
+		for _, writeErr := range exception.WriteErrors {
+			if writeErr.Index >= 0 && writeErr.Index < n {
+				errs[writeErr.Index] = writeErr
+			}
+		}
+
+		if exception.WriteConcernError != nil {
+			for i := range errs {
+				if errs[i] == nil {
+					errs[i] = exception.WriteConcernError
+				}
+			}
+		}
+	} else if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+
+	return errs
+}
+
+// writeModelSize returns approximate size of documents of the write model.
+func writeModelSize(model mongo.WriteModel) int {
+	var docs []interface{}
+
+	switch v := model.(type) {
+	case *mongo.InsertOneModel:
+		docs = append(docs, v.Document)
+	case *mongo.UpdateOneModel:
+		docs = append(docs, v.Filter, v.Update)
+	case *mongo.UpdateManyModel:
+		docs = append(docs, v.Filter, v.Update)
+	case *mongo.ReplaceOneModel:
+		docs = append(docs, v.Filter, v.Replacement)
+	case *mongo.DeleteOneModel:
+		docs = append(docs, v.Filter)
+	case *mongo.DeleteManyModel:
+		docs = append(docs, v.Filter)
+	}
+
+	size := 0
+
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+
+		if isPipeline(doc) {
+			doc = bson.D{{Key: "pipeline", Value: toPipeline(doc)}}
+		}
+
+		if raw, err := bson.Marshal(doc); err == nil {
+			size += len(raw)
+		}
+	}
+
+	return size
+}
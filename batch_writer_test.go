@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"testing"
+	"time"
+)
+
+type bulkWriteCollection struct {
+	CollectionInterface
+	mx      sync.Mutex
+	batches [][]mongo.WriteModel
+	err     error
+	block   chan struct{}
+}
+
+func (m *bulkWriteCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	_ ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	if m.block != nil {
+		select {
+		case <-m.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.batches = append(m.batches, models)
+	return &mongo.BulkWriteResult{}, m.err
+}
+
+func (m *bulkWriteCollection) sizes() []int {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	var sizes []int
+
+	for _, batch := range m.batches {
+		sizes = append(sizes, len(batch))
+	}
+
+	return sizes
+}
+
+func TestBatchWriter_Size_Ok(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{}
+	writer := NewBatchWriter(collection, BatchWriterSize(2), BatchWriterInterval(0))
+
+	for i := 0; i < 5; i++ {
+		err := writer.Write(ctx, mongo.NewInsertOneModel().SetDocument(bson.M{"i": i}))
+		assert.NoError(t, err)
+	}
+
+	err := writer.Close(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 1}, collection.sizes())
+
+	err = writer.Write(ctx, mongo.NewInsertOneModel().SetDocument(bson.M{}))
+	assert.Equal(t, ErrorBatchWriterClosed, err)
+}
+
+func TestBatchWriter_Bytes_Ok(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{}
+	model := mongo.NewInsertOneModel().SetDocument(bson.M{"field": "value"})
+	writer := NewBatchWriter(collection, BatchWriterBytes(writeModelSize(model)*2+1), BatchWriterInterval(0))
+
+	err := writer.Write(ctx, model, model, model, model)
+	assert.NoError(t, err)
+
+	err = writer.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 2}, collection.sizes())
+
+	err = writer.Close(ctx)
+	assert.NoError(t, err)
+}
+
+func TestBatchWriter_Interval_Ok(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{}
+	writer := NewBatchWriter(collection, BatchWriterInterval(10*time.Millisecond))
+
+	err := writer.Write(ctx, mongo.NewInsertOneModel().SetDocument(bson.M{}))
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return len(collection.sizes()) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	err = writer.Close(ctx)
+	assert.NoError(t, err)
+}
+
+func TestBatchWriter_Backpressure_Error(t *testing.T) {
+	collection := &bulkWriteCollection{block: make(chan struct{})}
+	writer := NewBatchWriter(collection, BatchWriterSize(1), BatchWriterQueue(1), BatchWriterInterval(0))
+	model := mongo.NewInsertOneModel().SetDocument(bson.M{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := writer.Write(ctx, model, model, model)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	close(collection.block)
+
+	err = writer.Close(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, collection.sizes())
+}
+
+func TestBatchWriter_Callback_Ok(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{
+		err: mongo.BulkWriteException{
+			WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000}}},
+		},
+	}
+	var results []BatchResult
+	writer := NewBatchWriter(collection, BatchWriterCallback(func(result BatchResult) {
+		results = append(results, result)
+	}))
+	model1 := mongo.NewInsertOneModel().SetDocument(bson.M{"_id": 1})
+	model2 := mongo.NewInsertOneModel().SetDocument(bson.M{"_id": 2})
+
+	err := writer.Write(ctx, model1, model2)
+	assert.NoError(t, err)
+
+	err = writer.Close(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, model1, results[0].Model)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, model2, results[1].Model)
+	assert.Error(t, results[1].Err)
+
+	collection.err = errors.New("connection error")
+	results = nil
+	writer = NewBatchWriter(collection, BatchWriterCallback(func(result BatchResult) {
+		results = append(results, result)
+	}))
+
+	err = writer.Write(ctx, model1, model2)
+	assert.NoError(t, err)
+
+	err = writer.Close(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, collection.err, results[0].Err)
+	assert.Equal(t, collection.err, results[1].Err)
+}
+
+func TestBatchWriter_Close_Error(t *testing.T) {
+	collection := &bulkWriteCollection{block: make(chan struct{})}
+	var results []BatchResult
+	writer := NewBatchWriter(collection, BatchWriterCallback(func(result BatchResult) {
+		results = append(results, result)
+	}))
+
+	err := writer.Write(context.Background(), mongo.NewInsertOneModel().SetDocument(bson.M{}))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	go writer.Flush(context.Background())
+
+	err = writer.Close(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	<-writer.done
+	assert.Len(t, results, 1)
+	assert.Equal(t, context.Canceled, results[0].Err)
+}
+
+func TestBatchWriter_Close_BlockedWrite_Error(t *testing.T) {
+	collection := &bulkWriteCollection{block: make(chan struct{})}
+	writer := NewBatchWriter(collection, BatchWriterSize(1), BatchWriterQueue(1), BatchWriterInterval(0))
+	model := mongo.NewInsertOneModel().SetDocument(bson.M{})
+	written := make(chan error)
+
+	go func() {
+		written <- writer.Write(context.Background(), model, model, model)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(writer.queue) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	closed := make(chan error)
+
+	go func() {
+		closed <- writer.Close(ctx)
+	}()
+
+	select {
+	case err := <-closed:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		assert.FailNow(t, "close is blocked by write")
+	}
+
+	assert.Equal(t, ErrorBatchWriterClosed, <-written)
+}
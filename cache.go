@@ -0,0 +1,171 @@
+package database
+
+import (
+	"container/list"
+	"go.mongodb.org/mongo-driver/bson"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMemoryCacheCapacity = 1000
+	DefaultMemoryCacheTTL      = time.Minute
+)
+
+// Cache stores documents read by CachedCollection. Every entry is stored with
+// tags, Invalidate removes all entries having any of the given tags.
+type Cache interface {
+	Get(key string) (bson.Raw, bool)
+	Set(key string, value bson.Raw, tags ...string)
+	Invalidate(tags ...string)
+}
+
+type MemoryCacheOptions struct {
+	Capacity int
+	TTL      time.Duration
+	Clock    Clock
+}
+
+type MemoryCacheOption func(*MemoryCacheOptions)
+
+func MemoryCacheCapacity(capacity int) MemoryCacheOption {
+	return func(opts *MemoryCacheOptions) {
+		opts.Capacity = capacity
+	}
+}
+
+// MemoryCacheTTL sets lifetime of cache entries, entries never expire when
+// ttl is zero.
+func MemoryCacheTTL(ttl time.Duration) MemoryCacheOption {
+	return func(opts *MemoryCacheOptions) {
+		opts.TTL = ttl
+	}
+}
+
+func MemoryCacheClock(clock Clock) MemoryCacheOption {
+	return func(opts *MemoryCacheOptions) {
+		opts.Clock = clock
+	}
+}
+
+// MemoryCache is in-memory LRU cache with expiring entries.
+type MemoryCache struct {
+	opts *MemoryCacheOptions
+
+	mx      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	tags    map[string]map[*list.Element]struct{}
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     bson.Raw
+	tags      []string
+	expiresAt time.Time
+}
+
+func NewMemoryCache(options ...MemoryCacheOption) *MemoryCache {
+	opts := &MemoryCacheOptions{
+		Capacity: DefaultMemoryCacheCapacity,
+		TTL:      DefaultMemoryCacheTTL,
+		Clock:    SystemClock,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	cache := &MemoryCache{
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[*list.Element]struct{}),
+	}
+
+	return cache
+}
+
+func (m *MemoryCache) Get(key string) (bson.Raw, bool) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	el, ok := m.entries[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*memoryCacheEntry)
+
+	if !entry.expiresAt.IsZero() && !m.opts.Clock.Now().Before(entry.expiresAt) {
+		m.remove(el)
+		return nil, false
+	}
+
+	m.lru.MoveToFront(el)
+	return entry.value, true
+}
+
+func (m *MemoryCache) Set(key string, value bson.Raw, tags ...string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+
+	entry := &memoryCacheEntry{key: key, value: value, tags: tags}
+
+	if m.opts.TTL > 0 {
+		entry.expiresAt = m.opts.Clock.Now().Add(m.opts.TTL)
+	}
+
+	el := m.lru.PushFront(entry)
+	m.entries[key] = el
+
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[*list.Element]struct{})
+		}
+
+		m.tags[tag][el] = struct{}{}
+	}
+
+	for m.opts.Capacity > 0 && m.lru.Len() > m.opts.Capacity {
+		m.remove(m.lru.Back())
+	}
+}
+
+func (m *MemoryCache) Invalidate(tags ...string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	for _, tag := range tags {
+		for el := range m.tags[tag] {
+			m.remove(el)
+		}
+	}
+}
+
+func (m *MemoryCache) Len() int {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return m.lru.Len()
+}
+
+func (m *MemoryCache) remove(el *list.Element) {
+	entry := el.Value.(*memoryCacheEntry)
+
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], el)
+
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+
+	delete(m.entries, entry.key)
+	m.lru.Remove(el)
+}
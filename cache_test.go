@@ -0,0 +1,58 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_Ok(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheCapacity(2), MemoryCacheTTL(0))
+	doc1, _ := bson.Marshal(bson.M{"_id": 1})
+	doc2, _ := bson.Marshal(bson.M{"_id": 2})
+	doc3, _ := bson.Marshal(bson.M{"_id": 3})
+
+	cache.Set("key1", doc1, "tag", "tag1")
+	cache.Set("key2", doc2, "tag", "tag2")
+
+	val, ok := cache.Get("key1")
+	assert.True(t, ok)
+	assert.Equal(t, bson.Raw(doc1), val)
+
+	cache.Set("key3", doc3, "tag", "tag3")
+	assert.Equal(t, 2, cache.Len())
+
+	_, ok = cache.Get("key2")
+	assert.False(t, ok)
+
+	cache.Invalidate("tag1")
+
+	_, ok = cache.Get("key1")
+	assert.False(t, ok)
+
+	_, ok = cache.Get("key3")
+	assert.True(t, ok)
+
+	cache.Invalidate("tag")
+	assert.Equal(t, 0, cache.Len())
+	assert.Len(t, cache.tags, 0)
+}
+
+func TestMemoryCache_TTL_Ok(t *testing.T) {
+	clock := &frozenClock{now: time.Now()}
+	cache := NewMemoryCache(MemoryCacheTTL(time.Minute), MemoryCacheClock(clock))
+	doc, _ := bson.Marshal(bson.M{"_id": 1})
+
+	cache.Set("key", doc, "tag")
+
+	_, ok := cache.Get("key")
+	assert.True(t, ok)
+
+	clock.now = clock.now.Add(time.Minute)
+
+	_, ok = cache.Get("key")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Len())
+	assert.Len(t, cache.tags, 0)
+}
@@ -0,0 +1,477 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type withoutCacheKey struct{}
+
+// WithoutCache returns context which makes cached collections read documents
+// from the database bypassing the cache.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutCacheKey{}, true)
+}
+
+func isWithoutCache(ctx context.Context) bool {
+	val, _ := ctx.Value(withoutCacheKey{}).(bool)
+	return val
+}
+
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CachedCollection serves FindOne selecting single document by _id from the
+// cache by normalized filter and options. Entries are invalidated by _id when
+// filter of the write operation selects documents by _id, otherwise all
+// entries of the collection are invalidated. Inserts do not invalidate the
+// cache, since missing documents are never cached and inserted document can't
+// match cached lookup of another _id.
+//
+// Documents read before a concurrent write are not cached after the write
+// invalidated the cache, so writes and reads must be made through the same
+// CachedCollection for the cache to stay consistent.
+//
+// Cache keys are built from the filter CachedCollection receives, so it must
+// be applied before wrappers which add conditions to the filter, e.g. Tenancy.
+type CachedCollection struct {
+	CollectionInterface
+	cache     Cache
+	namespace string
+	hits      uint64
+	misses    uint64
+
+	mx         sync.Mutex
+	generation uint64
+}
+
+type cachedSingleResult struct {
+	raw bson.Raw
+}
+
+func NewCachedCollection(collection CollectionInterface, cache Cache) *CachedCollection {
+	return &CachedCollection{CollectionInterface: collection, cache: cache}
+}
+
+// Caching wraps collection with CachedCollection. Entries of the collection
+// are invalidated when it is dropped or renamed with the database. Keys and
+// tags of entries are prefixed with the database name, so databases sharing
+// wrappers, e.g. tenant databases of Router, may share the cache.
+func Caching(cache Cache) CollectionWrapper {
+	return func(db Database, collection CollectionInterface) CollectionInterface {
+		cached := NewCachedCollection(collection, cache)
+
+		if mdb, ok := db.(*Mongodb); ok {
+			cached.namespace = mdb.name + "." + collection.Name()
+			mdb.onForget(collection.Name(), func() {
+				cached.invalidateTags(cached.ns())
+			})
+		}
+
+		return cached
+	}
+}
+
+func (m *CachedCollection) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&m.hits),
+		Misses: atomic.LoadUint64(&m.misses),
+	}
+}
+
+func (m *CachedCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	defer m.invalidate(filter)
+	return m.CollectionInterface.DeleteMany(ctx, filter, opts...)
+}
+
+func (m *CachedCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	defer m.invalidate(filter)
+	return m.CollectionInterface.DeleteOne(ctx, filter, opts...)
+}
+
+func (m *CachedCollection) Drop(ctx context.Context) error {
+	defer m.invalidateTags(m.ns())
+	return m.CollectionInterface.Drop(ctx)
+}
+
+func (m *CachedCollection) FindOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) SingleResultInterface {
+	if ids, ok := filterIds(filter); isWithoutCache(ctx) || !ok || len(ids) != 1 {
+		return m.CollectionInterface.FindOne(ctx, filter, opts...)
+	}
+
+	key, err := m.key(filter, opts)
+
+	if err != nil {
+		return m.CollectionInterface.FindOne(ctx, filter, opts...)
+	}
+
+	if raw, ok := m.cache.Get(key); ok {
+		atomic.AddUint64(&m.hits, 1)
+		return &cachedSingleResult{raw: raw}
+	}
+
+	atomic.AddUint64(&m.misses, 1)
+
+	m.mx.Lock()
+	generation := m.generation
+	m.mx.Unlock()
+
+	res := m.CollectionInterface.FindOne(ctx, filter, opts...)
+
+	if raw, err := res.DecodeBytes(); err == nil {
+		m.set(key, raw, generation)
+	}
+
+	return res
+}
+
+func (m *CachedCollection) FindOneAndDelete(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) SingleResultInterface {
+	res := m.CollectionInterface.FindOneAndDelete(ctx, filter, opts...)
+	m.invalidateResult(filter, res)
+
+	return res
+}
+
+func (m *CachedCollection) FindOneAndReplace(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) SingleResultInterface {
+	res := m.CollectionInterface.FindOneAndReplace(ctx, filter, replacement, opts...)
+	m.invalidateResult(filter, res)
+
+	return res
+}
+
+func (m *CachedCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	res := m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, opts...)
+	m.invalidateResult(filter, res)
+
+	return res
+}
+
+func (m *CachedCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	defer m.invalidate(filter)
+	return m.CollectionInterface.ReplaceOne(ctx, filter, replacement, opts...)
+}
+
+func (m *CachedCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	defer m.invalidate(filter)
+	return m.CollectionInterface.UpdateMany(ctx, filter, update, opts...)
+}
+
+func (m *CachedCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	defer m.invalidate(filter)
+	return m.CollectionInterface.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (m *CachedCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	var filters []interface{}
+
+	for _, model := range models {
+		switch v := model.(type) {
+		case *mongo.UpdateOneModel:
+			filters = append(filters, v.Filter)
+		case *mongo.UpdateManyModel:
+			filters = append(filters, v.Filter)
+		case *mongo.ReplaceOneModel:
+			filters = append(filters, v.Filter)
+		case *mongo.DeleteOneModel:
+			filters = append(filters, v.Filter)
+		case *mongo.DeleteManyModel:
+			filters = append(filters, v.Filter)
+		}
+	}
+
+	defer m.invalidate(filters...)
+	return m.CollectionInterface.BulkWrite(ctx, models, opts...)
+}
+
+// invalidate removes entries of documents selected by filters by _id or all
+// entries of the collection when any filter does not select by _id.
+func (m *CachedCollection) invalidate(filters ...interface{}) {
+	var tags []string
+
+	for _, filter := range filters {
+		ids, ok := filterIds(filter)
+
+		if !ok {
+			m.invalidateTags(m.ns())
+			return
+		}
+
+		for _, id := range ids {
+			tag, ok := m.idTag(id)
+
+			if !ok {
+				m.invalidateTags(m.ns())
+				return
+			}
+
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) > 0 {
+		m.invalidateTags(tags...)
+	}
+}
+
+// invalidateTags removes entries with tags and makes documents read before
+// invalidation not to be cached.
+func (m *CachedCollection) invalidateTags(tags ...string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.generation++
+	m.cache.Invalidate(tags...)
+}
+
+// set caches document read at the generation unless the cache was
+// invalidated since then.
+func (m *CachedCollection) set(key string, raw bson.Raw, generation uint64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if m.generation != generation {
+		return
+	}
+
+	tags := []string{m.ns()}
+
+	if tag, ok := m.idTag(raw.Lookup("_id")); ok {
+		tags = append(tags, tag)
+	}
+
+	m.cache.Set(key, raw, tags...)
+}
+
+func (m *CachedCollection) invalidateResult(filter interface{}, res SingleResultInterface) {
+	if _, ok := filterIds(filter); ok {
+		m.invalidate(filter)
+		return
+	}
+
+	raw, err := res.DecodeBytes()
+
+	if err == mongo.ErrNoDocuments {
+		return
+	}
+
+	if err == nil {
+		if tag, ok := m.idTag(raw.Lookup("_id")); ok {
+			m.invalidateTags(tag)
+			return
+		}
+	}
+
+	m.invalidateTags(m.ns())
+}
+
+func (m *CachedCollection) key(filter interface{}, opts []*options.FindOneOptions) (string, error) {
+	opt := options.MergeFindOneOptions(opts...)
+	opt.BatchSize = nil
+	opt.Comment = nil
+	opt.MaxAwaitTime = nil
+	opt.MaxTime = nil
+	opt.Hint = normalizeDocument(opt.Hint)
+	opt.Max = normalizeDocument(opt.Max)
+	opt.Min = normalizeDocument(opt.Min)
+	opt.Projection = normalizeDocument(opt.Projection)
+	opt.Sort = normalizeDocument(opt.Sort)
+
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	key, err := bson.MarshalExtJSON(bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: opt},
+	}, true, false)
+
+	if err != nil {
+		return "", err
+	}
+
+	return m.ns() + ":" + string(key), nil
+}
+
+func (m *CachedCollection) idTag(id interface{}) (string, bool) {
+	if rv, ok := id.(bson.RawValue); ok && rv.Type == 0 {
+		return "", false
+	}
+
+	val, err := bson.MarshalExtJSON(bson.D{{Key: "_id", Value: id}}, true, false)
+
+	if err != nil {
+		return "", false
+	}
+
+	return m.ns() + "/" + string(val), true
+}
+
+// ns returns namespace of keys and tags of the collection entries.
+func (m *CachedCollection) ns() string {
+	if m.namespace != "" {
+		return m.namespace
+	}
+
+	return m.Name()
+}
+
+func (m *cachedSingleResult) Decode(v interface{}) error {
+	return bson.Unmarshal(m.raw, v)
+}
+
+func (m *cachedSingleResult) DecodeBytes() (bson.Raw, error) {
+	return m.raw, nil
+}
+
+func (m *cachedSingleResult) Err() error {
+	return nil
+}
+
+// filterIds returns values of _id selected by equality or $in condition of
+// the filter.
+func filterIds(filter interface{}) ([]interface{}, bool) {
+	if filter == nil {
+		return nil, false
+	}
+
+	doc, err := toDocument(filter)
+
+	if err != nil {
+		return nil, false
+	}
+
+	val, ok := lookup(doc, "_id")
+
+	if !ok {
+		return nil, false
+	}
+
+	cond, ok := val.(bson.D)
+
+	if !ok || len(cond) == 0 || !strings.HasPrefix(cond[0].Key, "$") {
+		return []interface{}{val}, true
+	}
+
+	if len(cond) != 1 {
+		return nil, false
+	}
+
+	switch cond[0].Key {
+	case "$eq":
+		return []interface{}{cond[0].Value}, true
+	case "$in":
+		values, ok := cond[0].Value.(bson.A)
+		return values, ok
+	}
+
+	return nil, false
+}
+
+// normalizeDocument converts maps to documents with sorted keys, so the same
+// filter always has the same representation.
+func normalizeDocument(val interface{}) interface{} {
+	switch v := val.(type) {
+	case bson.M:
+		return normalizeMap(v)
+	case map[string]interface{}:
+		return normalizeMap(v)
+	case bson.D:
+		doc := make(bson.D, len(v))
+
+		for i, el := range v {
+			doc[i] = bson.E{Key: el.Key, Value: normalizeDocument(el.Value)}
+		}
+
+		return doc
+	case bson.A:
+		arr := make(bson.A, len(v))
+
+		for i, el := range v {
+			arr[i] = normalizeDocument(el)
+		}
+
+		return arr
+	case []interface{}:
+		return normalizeDocument(bson.A(v))
+	case []bson.M:
+		arr := make(bson.A, len(v))
+
+		for i, el := range v {
+			arr[i] = normalizeMap(el)
+		}
+
+		return arr
+	}
+
+	return val
+}
+
+func normalizeMap(m map[string]interface{}) bson.D {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	doc := make(bson.D, len(keys))
+
+	for i, key := range keys {
+		doc[i] = bson.E{Key: key, Value: normalizeDocument(m[key])}
+	}
+
+	return doc
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+)
+
+type CachedStub struct {
+	Id          string `bson:"_id"`
+	FieldString string `bson:"field_string"`
+}
+
+func TestFilterIds_Ok(t *testing.T) {
+	ids, ok := filterIds(bson.M{"_id": "1", "field_string": "value"})
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"1"}, ids)
+
+	ids, ok = filterIds(bson.M{"_id": bson.M{"$eq": "1"}})
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"1"}, ids)
+
+	ids, ok = filterIds(bson.M{"_id": bson.M{"$in": bson.A{"1", "2"}}})
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"1", "2"}, ids)
+
+	_, ok = filterIds(bson.M{"_id": bson.M{"$gt": "1"}})
+	assert.False(t, ok)
+
+	_, ok = filterIds(bson.M{"field_string": "value"})
+	assert.False(t, ok)
+
+	_, ok = filterIds(nil)
+	assert.False(t, ok)
+}
+
+func TestCachedCollection_Key_Ok(t *testing.T) {
+	collection := NewCachedCollection(&Collection{collection: &mongo.Collection{}}, NewMemoryCache())
+
+	key1, err := collection.key(bson.M{"a": 1, "b": bson.M{"c": 1, "d": 2}, "e": 3}, nil)
+	assert.NoError(t, err)
+
+	key2, err := collection.key(bson.M{"e": 3, "b": bson.M{"d": 2, "c": 1}, "a": 1}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, key1, key2)
+
+	key2, err = collection.key(bson.M{"a": 1, "b": bson.M{"c": 1, "d": 2}, "e": 3}, []*options.FindOneOptions{
+		options.FindOne().SetProjection(bson.M{"a": 1}),
+	})
+	assert.NoError(t, err)
+	assert.NotEqual(t, key1, key2)
+
+	key2, err = collection.key(bson.M{"a": 1, "b": bson.M{"c": 1, "d": 2}, "e": 3}, []*options.FindOneOptions{
+		options.FindOne().SetComment("comment"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, key1, key2)
+}
+
+type racingCollection struct {
+	CollectionInterface
+	raw    bson.Raw
+	onFind func()
+}
+
+func (m *racingCollection) Name() string {
+	return "stubs"
+}
+
+func (m *racingCollection) FindOne(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.FindOneOptions,
+) SingleResultInterface {
+	if m.onFind != nil {
+		m.onFind()
+	}
+
+	return &cachedSingleResult{raw: m.raw}
+}
+
+func TestCachedCollection_FindOne_Race_Ok(t *testing.T) {
+	raw, err := bson.Marshal(bson.M{"_id": "1"})
+	assert.NoError(t, err)
+
+	inner := &racingCollection{raw: raw}
+	collection := NewCachedCollection(inner, NewMemoryCache())
+	inner.onFind = func() {
+		collection.invalidate(bson.M{"_id": "1"})
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, collection.FindOne(ctx, bson.M{"_id": "1"}).Err())
+	assert.NoError(t, collection.FindOne(ctx, bson.M{"_id": "1"}).Err())
+	assert.Equal(t, CacheStats{Hits: 0, Misses: 2}, collection.Stats())
+
+	inner.onFind = nil
+	assert.NoError(t, collection.FindOne(ctx, bson.M{"_id": "1"}).Err())
+	assert.NoError(t, collection.FindOne(ctx, bson.M{"_id": "1"}).Err())
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3}, collection.Stats())
+
+	assert.NoError(t, collection.FindOne(ctx, bson.M{"field_string": "value"}).Err())
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3}, collection.Stats())
+}
+
+type CachedCollectionTestSuite struct {
+	suite.Suite
+	db         Database
+	collection *CachedCollection
+}
+
+func Test_CachedCollection(t *testing.T) {
+	suite.Run(t, new(CachedCollectionTestSuite))
+}
+
+func (suite *CachedCollectionTestSuite) SetupTest() {
+	opts := []Option{
+		Dsn("mongodb://localhost:27017/test"),
+		WrapCollection("stubs", Caching(NewMemoryCache())),
+	}
+	db, err := New(opts...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	_, err = db.Collection("stubs").InsertMany(context.Background(), []interface{}{
+		&CachedStub{Id: "1", FieldString: "value1"},
+		&CachedStub{Id: "2", FieldString: "value2"},
+	})
+
+	if err != nil {
+		assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+	}
+
+	suite.db = db
+	suite.collection = db.Collection("stubs").(*CachedCollection)
+}
+
+func (suite *CachedCollectionTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *CachedCollectionTestSuite) TestCachedCollection_FindOne_Ok() {
+	ctx := context.Background()
+	var stub CachedStub
+
+	err := suite.collection.FindOne(ctx, bson.M{"_id": "1"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "value1", stub.FieldString)
+
+	err = suite.collection.FindOne(ctx, bson.M{"_id": "1"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CacheStats{Hits: 1, Misses: 1}, suite.collection.Stats())
+
+	err = suite.collection.FindOne(WithoutCache(ctx), bson.M{"_id": "1"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CacheStats{Hits: 1, Misses: 1}, suite.collection.Stats())
+
+	err = suite.collection.FindOne(ctx, bson.M{"_id": "3"}).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+
+	err = suite.collection.FindOne(ctx, bson.M{"_id": "3"}).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+	assert.Equal(suite.T(), CacheStats{Hits: 1, Misses: 3}, suite.collection.Stats())
+}
+
+func (suite *CachedCollectionTestSuite) TestCachedCollection_Invalidate_Ok() {
+	ctx := context.Background()
+	var stub CachedStub
+
+	err := suite.collection.FindOne(ctx, bson.M{"_id": "1"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+
+	err = suite.collection.FindOne(ctx, bson.M{"field_string": "value2"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.collection.UpdateOne(ctx, bson.M{"_id": "1"}, bson.M{"$set": bson.M{"field_string": "value3"}})
+	assert.NoError(suite.T(), err)
+
+	err = suite.collection.FindOne(ctx, bson.M{"_id": "1"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "value3", stub.FieldString)
+
+	err = suite.collection.FindOne(ctx, bson.M{"field_string": "value2"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CacheStats{Hits: 0, Misses: 2}, suite.collection.Stats())
+
+	res := suite.collection.FindOneAndUpdate(ctx, bson.M{"field_string": "value2"}, bson.M{"$set": bson.M{"field_string": "value4"}})
+	assert.NoError(suite.T(), res.Err())
+
+	err = suite.collection.FindOne(ctx, bson.M{"field_string": "value2"}).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+
+	_, err = suite.collection.BulkWrite(ctx, []mongo.WriteModel{
+		mongo.NewDeleteOneModel().SetFilter(bson.M{"field_string": "value3"}),
+	})
+	assert.NoError(suite.T(), err)
+
+	err = suite.collection.FindOne(ctx, bson.M{"_id": "1"}).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+}
+
+func (suite *CachedCollectionTestSuite) TestCachedCollection_DatabaseDrop_Ok() {
+	ctx := context.Background()
+
+	err := suite.collection.FindOne(ctx, bson.M{"_id": "1"}).Err()
+	assert.NoError(suite.T(), err)
+
+	err = suite.db.Drop()
+	assert.NoError(suite.T(), err)
+
+	err = suite.collection.FindOne(ctx, bson.M{"_id": "1"}).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+}
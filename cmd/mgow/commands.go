@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"os"
+)
+
+const (
+	outputPretty = "pretty"
+	outputLines  = "jsonl"
+)
+
+func ping(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errorUsage
+	}
+
+	err := db.Ping(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	version, err := db.ServerVersion(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, "ok, server version", version)
+	return err
+}
+
+func collections(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errorUsage
+	}
+
+	specs, err := db.ListCollections(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		if _, err = fmt.Fprintf(out, "%s\t%s\n", spec.Name, spec.Type); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func count(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	flags := commandFlags("count")
+	limit := flags.Int64("limit", 0, "maximal number of counted documents")
+	skip := flags.Int64("skip", 0, "number of skipped documents")
+
+	if err := parseFlags(flags, args, 1, 2); err != nil {
+		return err
+	}
+
+	filter, err := parseDocument(flags.Arg(1))
+
+	if err != nil {
+		return err
+	}
+
+	opts := options.Count()
+
+	if *limit > 0 {
+		opts.SetLimit(*limit)
+	}
+
+	if *skip > 0 {
+		opts.SetSkip(*skip)
+	}
+
+	n, err := db.Collection(flags.Arg(0)).CountDocuments(ctx, filter, opts)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, n)
+	return err
+}
+
+func find(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	flags := commandFlags("find")
+	sort := flags.String("sort", "", "sort document")
+	projection := flags.String("projection", "", "projection document")
+	limit := flags.Int64("limit", 0, "maximal number of documents")
+	skip := flags.Int64("skip", 0, "number of skipped documents")
+	output := flags.String("output", outputPretty, "output format, pretty or jsonl")
+	canonical := flags.Bool("canonical", false, "print canonical Extended JSON")
+
+	if err := parseFlags(flags, args, 1, 2); err != nil {
+		return err
+	}
+
+	filter, err := parseDocument(flags.Arg(1))
+
+	if err != nil {
+		return err
+	}
+
+	opts := options.Find()
+
+	if *sort != "" {
+		if opts.Sort, err = parseDocument(*sort); err != nil {
+			return err
+		}
+	}
+
+	if *projection != "" {
+		if opts.Projection, err = parseDocument(*projection); err != nil {
+			return err
+		}
+	}
+
+	if *limit > 0 {
+		opts.SetLimit(*limit)
+	}
+
+	if *skip > 0 {
+		opts.SetSkip(*skip)
+	}
+
+	cursor, err := db.Collection(flags.Arg(0)).Find(ctx, filter, opts)
+
+	if err != nil {
+		return err
+	}
+
+	return printCursor(ctx, cursor, out, *output, *canonical)
+}
+
+func aggregate(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	flags := commandFlags("aggregate")
+	output := flags.String("output", outputPretty, "output format, pretty or jsonl")
+	canonical := flags.Bool("canonical", false, "print canonical Extended JSON")
+
+	if err := parseFlags(flags, args, 2, 2); err != nil {
+		return err
+	}
+
+	pipeline, err := parsePipeline(flags.Arg(1))
+
+	if err != nil {
+		return err
+	}
+
+	cursor, err := db.Collection(flags.Arg(0)).Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return err
+	}
+
+	return printCursor(ctx, cursor, out, *output, *canonical)
+}
+
+func export(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	flags := commandFlags("export")
+	format := flags.String("format", string(database.ExportRelaxed), "output format, canonical, relaxed or bson")
+	gzip := flags.Bool("gzip", false, "compress output with gzip")
+	path := flags.String("out", "", "output file, defaults to standard output")
+
+	if err := parseFlags(flags, args, 1, 2); err != nil {
+		return err
+	}
+
+	filter, err := parseDocument(flags.Arg(1))
+
+	if err != nil {
+		return err
+	}
+
+	w := out
+
+	if *path != "" {
+		file, err := os.Create(*path)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		w = file
+	}
+
+	n, err := database.Export(ctx, db.Collection(flags.Arg(0)), filter, w, database.ExportFormat(*format), database.ExportGzip(*gzip))
+
+	if err != nil {
+		return err
+	}
+
+	if *path != "" {
+		_, err = fmt.Fprintln(out, "exported", n, "documents")
+	}
+
+	return err
+}
+
+func importCollection(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	flags := commandFlags("import")
+	mode := flags.String("mode", string(database.ImportInsert), "import mode, insert, upsert or merge")
+	path := flags.String("in", "", "input file, defaults to standard input")
+	batch := flags.Int("batch", database.DefaultImportBatchSize, "number of documents of single bulk write")
+
+	if err := parseFlags(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+
+	if *path != "" {
+		file, err := os.Open(*path)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		r = file
+	}
+
+	res, err := database.Import(ctx, db.Collection(flags.Arg(0)), r, database.ImportMode(*mode), database.ImportBatchSize(*batch))
+
+	if res != nil {
+		fmt.Fprintf(
+			out,
+			"documents %d, inserted %d, matched %d, modified %d, upserted %d, failed %d\n",
+			res.Documents, res.Inserted, res.Matched, res.Modified, res.Upserted, len(res.Errors),
+		)
+
+		for _, importErr := range res.Errors {
+			fmt.Fprintln(out, importErr)
+		}
+	}
+
+	return err
+}
+
+func printCursor(ctx context.Context, cursor database.CursorInterface, out io.Writer, output string, canonical bool) error {
+	if output != outputPretty && output != outputLines {
+		return fmt.Errorf("%w: unknown output %s", errorUsage, output)
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var raw bson.Raw
+
+		if err := cursor.Decode(&raw); err != nil {
+			return err
+		}
+
+		data, err := bson.MarshalExtJSON(raw, canonical, false)
+
+		if err != nil {
+			return err
+		}
+
+		if output == outputPretty {
+			buf := &bytes.Buffer{}
+
+			if err = json.Indent(buf, data, "", "  "); err != nil {
+				return err
+			}
+
+			data = buf.Bytes()
+		}
+
+		if _, err = out.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// parseDocument parses Extended JSON document, empty string is parsed as
+// empty document.
+func parseDocument(data string) (bson.D, error) {
+	doc := bson.D{}
+
+	if data == "" {
+		return doc, nil
+	}
+
+	err := bson.UnmarshalExtJSON([]byte(data), false, &doc)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid document %s: %w", data, err)
+	}
+
+	return doc, nil
+}
+
+func parsePipeline(data string) (bson.A, error) {
+	var doc struct {
+		Pipeline bson.A `bson:"pipeline"`
+	}
+
+	err := bson.UnmarshalExtJSON([]byte(`{"pipeline":`+data+`}`), false, &doc)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline %s: %w", data, err)
+	}
+
+	return doc.Pipeline, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+var (
+	errorIndexKey = errors.New("index specification must have key document")
+)
+
+type indexSpec struct {
+	collection string
+	indexes    []bson.D
+}
+
+// indexes ensures indexes of the spec file, which is Extended JSON document
+// with collection names as keys and arrays of index specifications of
+// createIndexes command as values, e.g.
+//
+//	{"users": [{"key": {"email": 1}, "unique": true}]}
+//
+// Index name is generated from the key when it is not set.
+func indexes(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errorUsage
+	}
+
+	data, err := ioutil.ReadFile(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	specs, err := parseIndexSpecs(data)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	for _, spec := range specs {
+		cmd := bson.D{{Key: "createIndexes", Value: spec.collection}, {Key: "indexes", Value: spec.indexes}}
+		err = db.RunCommand(ctx, cmd).Err()
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", spec.collection, err)
+		}
+
+		for _, index := range spec.indexes {
+			fmt.Fprintf(out, "%s\t%s\n", spec.collection, index.Map()["name"])
+		}
+	}
+
+	return nil
+}
+
+func parseIndexSpecs(data []byte) ([]*indexSpec, error) {
+	var doc bson.D
+	err := bson.UnmarshalExtJSON(data, false, &doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make([]*indexSpec, 0, len(doc))
+
+	for _, el := range doc {
+		arr, ok := el.Value.(bson.A)
+
+		if !ok {
+			return nil, fmt.Errorf("%s: indexes must be an array", el.Key)
+		}
+
+		spec := &indexSpec{collection: el.Key}
+
+		for _, val := range arr {
+			index, ok := val.(bson.D)
+
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", errorIndexKey, el.Key)
+			}
+
+			key, ok := index.Map()["key"].(bson.D)
+
+			if !ok || len(key) == 0 {
+				return nil, fmt.Errorf("%w: %s", errorIndexKey, el.Key)
+			}
+
+			if _, ok := index.Map()["name"]; !ok {
+				index = append(index, bson.E{Key: "name", Value: indexName(key)})
+			}
+
+			spec.indexes = append(spec.indexes, index)
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// indexName generates index name the same way as the driver, e.g.
+// "email_1_created_at_-1".
+func indexName(key bson.D) string {
+	parts := make([]string, 0, len(key)*2)
+
+	for _, el := range key {
+		parts = append(parts, el.Key, fmt.Sprint(el.Value))
+	}
+
+	return strings.Join(parts, "_")
+}
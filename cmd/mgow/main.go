@@ -0,0 +1,154 @@
+// Command mgow is a command-line tool for MongoDB databases built on the
+// wrapper, so connection string and read preference are handled the same way
+// as in services using it.
+//
+// Usage:
+//
+//	mgow [flags] command [command flags] [arguments]
+//
+// Commands:
+//
+//	ping                                  check connection to the database
+//	collections                           list collections of the database
+//	count collection [filter]             count documents matched by filter
+//	find collection [filter]              print documents matched by filter
+//	aggregate collection pipeline         print result of aggregation
+//	export collection [filter]            export documents of collection
+//	import collection                     import documents to collection
+//	indexes spec                          ensure indexes of spec file
+//	migrate up|down|status                run migrations of directory
+//	schema collection                     infer schema of sampled documents
+//
+// Filters and pipelines are Extended JSON, DSN is taken from MGOW_DSN
+// environment variable unless -dsn flag is set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"io"
+	"os"
+	"os/signal"
+)
+
+const (
+	defaultDsn = "mongodb://localhost:27017/test"
+	envDsn     = "MGOW_DSN"
+)
+
+var (
+	errorUsage = errors.New("invalid usage")
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, db database.Database, args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"ping":        {usage: "ping", run: ping},
+	"collections": {usage: "collections", run: collections},
+	"count":       {usage: "count [-limit n] [-skip n] collection [filter]", run: count},
+	"find":        {usage: "find [-sort doc] [-projection doc] [-limit n] [-skip n] [-output pretty|jsonl] [-canonical] collection [filter]", run: find},
+	"aggregate":   {usage: "aggregate [-output pretty|jsonl] [-canonical] collection pipeline", run: aggregate},
+	"export":      {usage: "export [-format canonical|relaxed|bson] [-gzip] [-out file] collection [filter]", run: export},
+	"import":      {usage: "import [-mode insert|upsert|merge] [-in file] [-batch n] collection", run: importCollection},
+	"indexes":     {usage: "indexes spec", run: indexes},
+	"migrate":     {usage: "migrate [-dir path] [-collection name] [-dry-run] up|down [n]|status", run: migrations},
+	"schema":      {usage: "schema [-sample n] [-go name] [-package name] collection", run: schema},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, out, errOut io.Writer) int {
+	flags := flag.NewFlagSet("mgow", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	dsn := flags.String("dsn", "", "connection string, defaults to "+envDsn+" or "+defaultDsn)
+	mode := flags.String("mode", database.DefaultMode, "read preference mode")
+	timeout := flags.Duration("timeout", 0, "timeout of the command, zero means no timeout")
+	flags.Usage = func() {
+		fmt.Fprintln(errOut, "usage: mgow [flags] command [command flags] [arguments]")
+		flags.PrintDefaults()
+		fmt.Fprintln(errOut, "commands:")
+
+		for _, name := range []string{"ping", "collections", "count", "find", "aggregate", "export", "import", "indexes", "migrate", "schema"} {
+			fmt.Fprintln(errOut, "  "+commands[name].usage)
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+
+	if !ok {
+		flags.Usage()
+		return 2
+	}
+
+	if *dsn == "" {
+		*dsn = os.Getenv(envDsn)
+	}
+
+	if *dsn == "" {
+		*dsn = defaultDsn
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	db, err := database.New(database.Dsn(*dsn), database.Mode(*mode))
+
+	if err != nil {
+		fmt.Fprintln(errOut, "mgow:", err)
+		return 1
+	}
+
+	defer db.Close()
+
+	err = cmd.run(ctx, db, flags.Args()[1:], out)
+
+	if errors.Is(err, errorUsage) {
+		fmt.Fprintln(errOut, "usage: mgow [flags]", cmd.usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(errOut, "mgow:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// commandFlags returns flag set of the command which reports usage errors
+// with errorUsage.
+func commandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string, min, max int) error {
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errorUsage, err)
+	}
+
+	if flags.NArg() < min || flags.NArg() > max {
+		return errorUsage
+	}
+
+	return nil
+}
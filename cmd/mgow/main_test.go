@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	database "github.com/sidmal/mgo-wrapper"
+	"github.com/sidmal/mgo-wrapper/migrate"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRun_Usage_Error(t *testing.T) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+
+	assert.Equal(t, 2, run([]string{"unknown"}, out, errOut))
+	assert.Empty(t, out.String())
+	assert.Contains(t, errOut.String(), "usage: mgow")
+	assert.Contains(t, errOut.String(), commands["migrate"].usage)
+}
+
+func TestParseDocument_Ok(t *testing.T) {
+	doc, err := parseDocument(`{"field_string": "value1", "field_float": {"$gt": 1}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "field_string", Value: "value1"}, {Key: "field_float", Value: bson.D{{Key: "$gt", Value: int32(1)}}}}, doc)
+
+	doc, err = parseDocument("")
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{}, doc)
+}
+
+func TestParseDocument_Error(t *testing.T) {
+	_, err := parseDocument(`{"field_string":`)
+	assert.Error(t, err)
+}
+
+func TestParsePipeline_Ok(t *testing.T) {
+	pipeline, err := parsePipeline(`[{"$match": {"field_string": "value1"}}, {"$limit": 1}]`)
+	assert.NoError(t, err)
+	assert.Len(t, pipeline, 2)
+	assert.Equal(t, bson.D{{Key: "$limit", Value: int32(1)}}, pipeline[1])
+}
+
+func TestParseIndexSpecs_Ok(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/indexes.json")
+	assert.NoError(t, err)
+
+	specs, err := parseIndexSpecs(data)
+	assert.NoError(t, err)
+	assert.Len(t, specs, 1)
+	assert.Equal(t, "stubs", specs[0].collection)
+	assert.Len(t, specs[0].indexes, 2)
+	assert.Equal(t, "field_string_1_field_float_-1", specs[0].indexes[0].Map()["name"])
+	assert.Equal(t, "ttl", specs[0].indexes[1].Map()["name"])
+}
+
+func TestParseIndexSpecs_Error(t *testing.T) {
+	_, err := parseIndexSpecs([]byte(`{"stubs": [{"unique": true}]}`))
+	assert.ErrorIs(t, err, errorIndexKey)
+
+	_, err = parseIndexSpecs([]byte(`{"stubs": {"key": {"field_string": 1}}}`))
+	assert.Error(t, err)
+}
+
+func TestRegisterMigrations_Ok(t *testing.T) {
+	migrator := migrate.New(nil)
+	assert.NoError(t, registerMigrations(migrator, "testdata/migrations"))
+
+	migrations := migrator.Migrations()
+	assert.Len(t, migrations, 2)
+	assert.EqualValues(t, 1, migrations[0].Version)
+	assert.Equal(t, "create stubs", migrations[0].Description)
+	assert.NotNil(t, migrations[0].Down)
+	assert.EqualValues(t, 2, migrations[1].Version)
+	assert.Nil(t, migrations[1].Down)
+}
+
+func TestFieldTypes_Ok(t *testing.T) {
+	field := &database.FieldSchema{
+		Path:      "tags",
+		Count:     4,
+		Parents:   5,
+		Types:     map[string]int64{"array": 3, "string": 1},
+		ItemTypes: map[string]int64{"string": 4, "int": 1},
+		MaxItems:  3,
+	}
+
+	assert.Equal(t, "array 75%, string 25%", fieldTypes(field))
+	assert.Equal(t, "items 0..3 of int|string, CONFLICT", fieldNotes(field))
+	assert.Equal(t, "80%", percent(field.Count, field.Parents))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"github.com/sidmal/mgo-wrapper/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.json$`)
+)
+
+type migrationCommands struct {
+	Up   []bson.D `bson:"up"`
+	Down []bson.D `bson:"down"`
+}
+
+// migrations runs migrations of the directory. Migration is Extended JSON file
+// named as version followed by description, e.g. 0001_add_users.json, with
+// arrays of database commands run in order by up and down keys.
+func migrations(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	flags := commandFlags("migrate")
+	dir := flags.String("dir", "migrations", "directory of migration files")
+	collection := flags.String("collection", migrate.DefaultCollection, "collection of applied migrations")
+	dryRun := flags.Bool("dry-run", false, "print migrations without running them")
+
+	if err := parseFlags(flags, args, 1, 2); err != nil {
+		return err
+	}
+
+	migrator := migrate.New(db, migrate.Collection(*collection), migrate.DryRun(*dryRun))
+	err := registerMigrations(migrator, *dir)
+
+	if err != nil {
+		return err
+	}
+
+	var applied []*migrate.Migration
+
+	switch flags.Arg(0) {
+	case "up":
+		applied, err = migrator.Up(ctx)
+	case "down":
+		n := 1
+
+		if flags.NArg() == 2 {
+			if n, err = strconv.Atoi(flags.Arg(1)); err != nil {
+				return fmt.Errorf("%w: %v", errorUsage, err)
+			}
+		}
+
+		applied, err = migrator.Down(ctx, n)
+	case "status":
+		return migrationStatus(ctx, migrator, out)
+	default:
+		return errorUsage
+	}
+
+	for _, migration := range applied {
+		fmt.Fprintf(out, "%s %d %s\n", flags.Arg(0), migration.Version, migration.Description)
+	}
+
+	return err
+}
+
+func migrationStatus(ctx context.Context, migrator *migrate.Migrator, out io.Writer) error {
+	statuses, err := migrator.Status(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		applied := "pending"
+
+		if status.Applied {
+			applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(out, "%d\t%s\t%s\n", status.Version, status.Description, applied)
+	}
+
+	return nil
+}
+
+func registerMigrations(migrator *migrate.Migrator, dir string) error {
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		match := migrationFile.FindStringSubmatch(file.Name())
+
+		if file.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseUint(match[1], 10, 64)
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+
+		if err != nil {
+			return err
+		}
+
+		commands := &migrationCommands{}
+		err = bson.UnmarshalExtJSON(data, false, commands)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name(), err)
+		}
+
+		var down migrate.Func
+
+		if len(commands.Down) > 0 {
+			down = runCommands(commands.Down)
+		}
+
+		err = migrator.Register(version, strings.ReplaceAll(match[2], "_", " "), runCommands(commands.Up), down)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func runCommands(commands []bson.D) migrate.Func {
+	return func(ctx context.Context, db database.Database) error {
+		for _, cmd := range commands {
+			if err := db.RunCommand(ctx, cmd).Err(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
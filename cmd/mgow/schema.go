@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// schema prints fields of sampled documents of the collection with their
+// types, presence and conflicts, or Go source of structs decoding them.
+func schema(ctx context.Context, db database.Database, args []string, out io.Writer) error {
+	flags := commandFlags("schema")
+	sample := flags.Int64("sample", database.DefaultInferSampleSize, "number of sampled documents")
+	name := flags.String("go", "", "print Go struct with the name instead of the report")
+	pkg := flags.String("package", "models", "package of the Go source")
+
+	if err := parseFlags(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	inferred, err := database.InferSchema(ctx, db.Collection(flags.Arg(0)), database.InferSampleSize(*sample))
+
+	if err != nil {
+		return err
+	}
+
+	if *name != "" {
+		src, err := inferred.GoSource(*pkg, *name)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = out.Write(src)
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "sampled %d documents\n", inferred.Documents)
+	fmt.Fprintln(w, "path\tpresence\ttypes\t")
+
+	for _, field := range inferred.Fields {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", field.Path, percent(field.Count, field.Parents), fieldTypes(field), fieldNotes(field))
+	}
+
+	return w.Flush()
+}
+
+func fieldTypes(field *database.FieldSchema) string {
+	types := make([]string, 0, len(field.Types))
+
+	for _, t := range field.TypeNames() {
+		if len(field.Types) > 1 {
+			t += " " + percent(field.Types[t], field.Count)
+		}
+
+		types = append(types, t)
+	}
+
+	return strings.Join(types, ", ")
+}
+
+func fieldNotes(field *database.FieldSchema) string {
+	var notes []string
+
+	if field.ItemTypes != nil {
+		items := make([]string, 0, len(field.ItemTypes))
+
+		for t := range field.ItemTypes {
+			items = append(items, t)
+		}
+
+		sort.Strings(items)
+
+		notes = append(notes, fmt.Sprintf("items %d..%d of %s", field.MinItems, field.MaxItems, strings.Join(items, "|")))
+	}
+
+	if field.Conflict() {
+		notes = append(notes, "CONFLICT")
+	}
+
+	return strings.Join(notes, ", ")
+}
+
+func percent(n, total int64) string {
+	if total == 0 {
+		return "0%"
+	}
+
+	return fmt.Sprintf("%d%%", n*100/total)
+}
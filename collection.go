@@ -25,6 +25,10 @@ type CollectionInterface interface {
 	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
 	Indexes() mongo.IndexView
+	Name() string
+	Drop(ctx context.Context) error
+	EstimatedDocumentCount(ctx context.Context, opts ...*options.EstimatedDocumentCountOptions) (int64, error)
+	Explain(ctx context.Context, op ExplainOperation, filter interface{}, opts ...ExplainOption) (*ExplainResult, error)
 }
 
 type SingleResultInterface interface {
@@ -35,10 +39,12 @@ type SingleResultInterface interface {
 
 type Collection struct {
 	collection *mongo.Collection
+	db         *Mongodb
 }
 
 type SingleResult struct {
 	singleResult *mongo.SingleResult
+	err          error
 }
 
 func (m *Collection) Aggregate(
@@ -195,14 +201,47 @@ func (m *Collection) Indexes() mongo.IndexView {
 	return m.collection.Indexes()
 }
 
+func (m *Collection) Name() string {
+	return m.collection.Name()
+}
+
+func (m *Collection) Drop(ctx context.Context) error {
+	err := m.collection.Drop(ctx)
+
+	if m.db != nil {
+		m.db.forget(m.Name())
+	}
+
+	return err
+}
+
+func (m *Collection) EstimatedDocumentCount(
+	ctx context.Context,
+	opts ...*options.EstimatedDocumentCountOptions,
+) (int64, error) {
+	return m.collection.EstimatedDocumentCount(ctx, opts...)
+}
+
 func (m *SingleResult) Decode(v interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+
 	return m.singleResult.Decode(v)
 }
 
 func (m *SingleResult) DecodeBytes() (bson.Raw, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
 	return m.singleResult.DecodeBytes()
 }
 
 func (m *SingleResult) Err() error {
+	if m.err != nil {
+		return m.err
+	}
+
 	return m.singleResult.Err()
 }
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// BuildInfo is result of buildInfo command, Raw contains the whole command
+// reply.
+type BuildInfo struct {
+	Version           string   `bson:"version"`
+	GitVersion        string   `bson:"gitVersion"`
+	VersionArray      []int32  `bson:"versionArray"`
+	Bits              int32    `bson:"bits"`
+	Debug             bool     `bson:"debug"`
+	MaxBsonObjectSize int32    `bson:"maxBsonObjectSize"`
+	StorageEngines    []string `bson:"storageEngines"`
+	Raw               bson.Raw `bson:"-"`
+}
+
+// DbStats is result of dbStats command, sizes are in bytes. Raw contains the
+// whole command reply.
+type DbStats struct {
+	Db          string   `bson:"db"`
+	Collections int64    `bson:"collections"`
+	Views       int64    `bson:"views"`
+	Objects     int64    `bson:"objects"`
+	AvgObjSize  float64  `bson:"avgObjSize"`
+	DataSize    float64  `bson:"dataSize"`
+	StorageSize float64  `bson:"storageSize"`
+	Indexes     int64    `bson:"indexes"`
+	IndexSize   float64  `bson:"indexSize"`
+	Raw         bson.Raw `bson:"-"`
+}
+
+// CollStats is result of collStats command, sizes are in bytes. Raw contains
+// the whole command reply.
+type CollStats struct {
+	Ns             string             `bson:"ns"`
+	Count          int64              `bson:"count"`
+	Size           float64            `bson:"size"`
+	AvgObjSize     float64            `bson:"avgObjSize"`
+	StorageSize    float64            `bson:"storageSize"`
+	Capped         bool               `bson:"capped"`
+	Nindexes       int64              `bson:"nindexes"`
+	TotalIndexSize float64            `bson:"totalIndexSize"`
+	IndexSizes     map[string]float64 `bson:"indexSizes"`
+	Raw            bson.Raw           `bson:"-"`
+}
+
+type CollModOptions struct {
+	Validator          interface{}
+	ValidationLevel    string
+	ValidationAction   string
+	Index              bson.D
+	ExpireAfterSeconds *int64
+}
+
+type CollModOption func(*CollModOptions)
+
+// CollModValidator replaces validator of collection documents, level and
+// action are not changed when empty.
+func CollModValidator(validator interface{}, level, action string) CollModOption {
+	return func(opts *CollModOptions) {
+		opts.Validator = validator
+		opts.ValidationLevel = level
+		opts.ValidationAction = action
+	}
+}
+
+// CollModIndexTTL changes lifetime of documents of TTL index with given name.
+func CollModIndexTTL(name string, ttl time.Duration) CollModOption {
+	return func(opts *CollModOptions) {
+		opts.Index = bson.D{
+			{Key: "name", Value: name},
+			{Key: "expireAfterSeconds", Value: int64(ttl / time.Second)},
+		}
+	}
+}
+
+// CollModIndexKeyTTL changes lifetime of documents of TTL index with given
+// key pattern.
+func CollModIndexKeyTTL(keys interface{}, ttl time.Duration) CollModOption {
+	return func(opts *CollModOptions) {
+		opts.Index = bson.D{
+			{Key: "keyPattern", Value: keys},
+			{Key: "expireAfterSeconds", Value: int64(ttl / time.Second)},
+		}
+	}
+}
+
+// CollModExpireAfter changes lifetime of documents of time-series or
+// clustered collection.
+func CollModExpireAfter(ttl time.Duration) CollModOption {
+	return func(opts *CollModOptions) {
+		seconds := int64(ttl / time.Second)
+		opts.ExpireAfterSeconds = &seconds
+	}
+}
+
+func (m *Mongodb) RunCommand(
+	ctx context.Context,
+	cmd interface{},
+	opts ...*options.RunCmdOptions,
+) SingleResultInterface {
+	result := m.database.RunCommand(ctx, cmd, opts...)
+	return &SingleResult{singleResult: result}
+}
+
+func (m *Mongodb) RunCommandCursor(
+	ctx context.Context,
+	cmd interface{},
+	opts ...*options.RunCmdOptions,
+) (CursorInterface, error) {
+	cursor, err := m.database.RunCommandCursor(ctx, cmd, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cursor{cursor: cursor}, nil
+}
+
+func (m *Mongodb) BuildInfo(ctx context.Context) (*BuildInfo, error) {
+	info := &BuildInfo{}
+	raw, err := m.runCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}, info)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info.Raw = raw
+	return info, nil
+}
+
+// ServerVersion returns version of the server, e.g. "4.4.6".
+func (m *Mongodb) ServerVersion(ctx context.Context) (string, error) {
+	info, err := m.BuildInfo(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	return info.Version, nil
+}
+
+func (m *Mongodb) DbStats(ctx context.Context) (*DbStats, error) {
+	stats := &DbStats{}
+	raw, err := m.runCommand(ctx, bson.D{{Key: "dbStats", Value: 1}}, stats)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Raw = raw
+	return stats, nil
+}
+
+func (m *Mongodb) CollStats(ctx context.Context, name string) (*CollStats, error) {
+	stats := &CollStats{}
+	raw, err := m.runCommand(ctx, bson.D{{Key: "collStats", Value: name}}, stats)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Raw = raw
+	return stats, nil
+}
+
+func (m *Mongodb) CollMod(ctx context.Context, name string, options ...CollModOption) error {
+	opts := &CollModOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	cmd := bson.D{{Key: "collMod", Value: name}}
+
+	if opts.Validator != nil {
+		cmd = append(cmd, bson.E{Key: "validator", Value: opts.Validator})
+	}
+
+	if opts.ValidationLevel != "" {
+		cmd = append(cmd, bson.E{Key: "validationLevel", Value: opts.ValidationLevel})
+	}
+
+	if opts.ValidationAction != "" {
+		cmd = append(cmd, bson.E{Key: "validationAction", Value: opts.ValidationAction})
+	}
+
+	if opts.Index != nil {
+		cmd = append(cmd, bson.E{Key: "index", Value: opts.Index})
+	}
+
+	if opts.ExpireAfterSeconds != nil {
+		cmd = append(cmd, bson.E{Key: "expireAfterSeconds", Value: *opts.ExpireAfterSeconds})
+	}
+
+	return m.RunCommand(ctx, cmd).Err()
+}
+
+func (m *Mongodb) runCommand(ctx context.Context, cmd interface{}, result interface{}) (bson.Raw, error) {
+	raw, err := m.RunCommand(ctx, cmd).DecodeBytes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return raw, bson.Unmarshal(raw, result)
+}
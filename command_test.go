@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"testing"
+	"time"
+)
+
+type CommandTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Command(t *testing.T) {
+	suite.Run(t, new(CommandTestSuite))
+}
+
+func (suite *CommandTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	res, err := db.Collection("stubs").InsertMany(context.Background(), stubs)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+	}
+
+	assert.Len(suite.T(), res.InsertedIDs, len(stubs))
+
+	suite.db = db
+}
+
+func (suite *CommandTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *CommandTestSuite) TestCommand_RunCommand_Ok() {
+	ctx := context.Background()
+
+	raw, err := suite.db.RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}).DecodeBytes()
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, raw.Lookup("ok").Double())
+
+	cursor, err := suite.db.RunCommandCursor(ctx, bson.D{
+		{Key: "find", Value: "stubs"},
+		{Key: "filter", Value: bson.M{"field_string": "value1"}},
+	})
+	assert.NoError(suite.T(), err)
+
+	var results []*Stub
+	err = cursor.All(ctx, &results)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 3)
+
+	err = suite.db.RunCommand(ctx, bson.D{{Key: "unknownCommand", Value: 1}}).Err()
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CommandTestSuite) TestCommand_Stats_Ok() {
+	ctx := context.Background()
+
+	info, err := suite.db.BuildInfo(ctx)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), info.VersionArray)
+	assert.NotEmpty(suite.T(), info.Raw)
+
+	version, err := suite.db.ServerVersion(ctx)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), strings.HasPrefix(version, info.Version))
+
+	dbStats, err := suite.db.DbStats(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "test", dbStats.Db)
+	assert.EqualValues(suite.T(), len(stubs), dbStats.Objects)
+
+	collStats, err := suite.db.CollStats(ctx, "stubs")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "test.stubs", collStats.Ns)
+	assert.EqualValues(suite.T(), len(stubs), collStats.Count)
+	assert.Contains(suite.T(), collStats.IndexSizes, "_id_")
+}
+
+func (suite *CommandTestSuite) TestCommand_CollMod_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"created_at": 1},
+		Options: options.Index().SetName("ttl").SetExpireAfterSeconds(60),
+	})
+	assert.NoError(suite.T(), err)
+
+	err = suite.db.CollMod(ctx, "stubs", CollModIndexTTL("ttl", time.Hour))
+	assert.NoError(suite.T(), err)
+
+	cursor, err := collection.Indexes().List(ctx)
+	assert.NoError(suite.T(), err)
+
+	var indexes []bson.M
+	err = cursor.All(ctx, &indexes)
+	assert.NoError(suite.T(), err)
+
+	for _, index := range indexes {
+		if index["name"] == "ttl" {
+			assert.EqualValues(suite.T(), 3600, index["expireAfterSeconds"])
+		}
+	}
+
+	validator := bson.M{"field_string": bson.M{"$type": "string"}}
+	err = suite.db.CollMod(ctx, "stubs", CollModValidator(validator, "strict", "error"))
+	assert.NoError(suite.T(), err)
+
+	_, err = collection.InsertOne(ctx, bson.M{"field_string": 1})
+	assert.Error(suite.T(), err)
+}
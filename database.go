@@ -5,6 +5,7 @@ import (
 	"errors"
 	dsnParser "github.com/sidmal/dsn-parser"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"sync"
@@ -23,8 +24,22 @@ var (
 type Database interface {
 	Close() error
 	Ping(ctx context.Context) error
+	StartSession(opts ...*options.SessionOptions) (mongo.Session, error)
 	Drop() error
 	Collection(name string) CollectionInterface
+	GridFS(name string) (BucketInterface, error)
+	ListCollectionNames(ctx context.Context, filter interface{}, opts ...*options.ListCollectionsOptions) ([]string, error)
+	ListCollections(ctx context.Context, filter interface{}, opts ...*options.ListCollectionsOptions) ([]*CollectionSpecification, error)
+	CreateCollection(ctx context.Context, name string, options ...CreateCollectionOption) error
+	CreateView(ctx context.Context, name, source string, pipeline interface{}, collation *options.Collation) error
+	RenameCollection(ctx context.Context, from, to string, dropTarget bool) error
+	RunCommand(ctx context.Context, cmd interface{}, opts ...*options.RunCmdOptions) SingleResultInterface
+	RunCommandCursor(ctx context.Context, cmd interface{}, opts ...*options.RunCmdOptions) (CursorInterface, error)
+	BuildInfo(ctx context.Context) (*BuildInfo, error)
+	ServerVersion(ctx context.Context) (string, error)
+	DbStats(ctx context.Context) (*DbStats, error)
+	CollStats(ctx context.Context, name string) (*CollStats, error)
+	CollMod(ctx context.Context, name string, options ...CollModOption) error
 }
 
 type Mongodb struct {
@@ -32,9 +47,14 @@ type Mongodb struct {
 	conn *Options
 	mx   sync.Mutex
 
+	shared bool
+
 	client      *mongo.Client
 	database    *mongo.Database
-	collections map[string]*Collection
+	collections map[string]CollectionInterface
+
+	hooksMx     sync.Mutex
+	forgetHooks map[string][]func()
 }
 
 func New(options ...Option) (Database, error) {
@@ -63,6 +83,7 @@ func New(options ...Option) (Database, error) {
 	}
 
 	conn.ModeOpts = opts.ModeOpts
+	conn.Wrappers = opts.Wrappers
 
 	db := new(Mongodb)
 	err := db.Open(conn)
@@ -113,12 +134,30 @@ func (m *Mongodb) open() error {
 		return err
 	}
 
-	m.collections = make(map[string]*Collection)
+	m.name = dsn.Database
+	m.collections = make(map[string]CollectionInterface)
 	m.database = m.client.Database(dsn.Database)
 	return nil
 }
 
+// sibling returns database with given name which shares connection with the
+// current one. Closing of sibling doesn't close shared connection.
+func (m *Mongodb) sibling(name string) *Mongodb {
+	return &Mongodb{
+		name:        name,
+		conn:        m.conn,
+		shared:      true,
+		client:      m.client,
+		database:    m.client.Database(name),
+		collections: make(map[string]CollectionInterface),
+	}
+}
+
 func (m *Mongodb) Close() error {
+	if m.shared {
+		return nil
+	}
+
 	if m.client != nil {
 		return m.client.Disconnect(m.conn.Context)
 	}
@@ -138,8 +177,36 @@ func (m *Mongodb) Ping(ctx context.Context) error {
 	return m.client.Ping(ctx, readpref.Primary())
 }
 
+// StartSession starts client session for causally consistent operations and
+// transactions, operations are made in the session with context created by
+// mongo.NewSessionContext or passed to the session callbacks.
+func (m *Mongodb) StartSession(opts ...*options.SessionOptions) (mongo.Session, error) {
+	if m.client == nil {
+		return nil, ErrorSessionNotInit
+	}
+
+	return m.client.StartSession(opts...)
+}
+
 func (m *Mongodb) Drop() error {
-	return m.database.Drop(m.conn.Context)
+	err := m.database.Drop(m.conn.Context)
+
+	m.mx.Lock()
+	m.collections = make(map[string]CollectionInterface)
+	m.mx.Unlock()
+
+	m.hooksMx.Lock()
+	hooks := m.forgetHooks
+	m.forgetHooks = nil
+	m.hooksMx.Unlock()
+
+	for _, fns := range hooks {
+		for _, fn := range fns {
+			fn()
+		}
+	}
+
+	return err
 }
 
 func (m *Mongodb) Collection(name string) CollectionInterface {
@@ -149,9 +216,32 @@ func (m *Mongodb) Collection(name string) CollectionInterface {
 	if !ok {
 		col = &Collection{
 			collection: m.database.Collection(name),
+			db:         m,
 		}
+
+		for _, wrapper := range m.conn.Wrappers[name] {
+			col = wrapper(m, col)
+		}
+
 		m.collections[name] = col
 	}
 	m.mx.Unlock()
 	return col
 }
+
+// GridFS returns GridFS bucket with given name, default bucket "fs" is used
+// when name is empty. Bucket keeps buffers and deadlines, so it is created on
+// every call and must not be shared between goroutines.
+func (m *Mongodb) GridFS(name string) (BucketInterface, error) {
+	if name == "" {
+		name = options.DefaultName
+	}
+
+	bucket, err := gridfs.NewBucket(m.database, options.GridFSBucket().SetName(name))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bucket{bucket: bucket}, nil
+}
@@ -0,0 +1,85 @@
+package dbtest
+
+import (
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"testing"
+)
+
+const (
+	maxNameLength = 63
+)
+
+// NewDatabase creates database with unique name for the test, so tests can
+// run in parallel without sharing data. The database is dropped and closed at
+// the test cleanup, the test fails when the database can not be created.
+func NewDatabase(t testing.TB, options ...Option) database.Database {
+	t.Helper()
+
+	opts := &Options{
+		Dsn:    DefaultDsn,
+		Prefix: DefaultPrefix,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	dsn := dsnWithDatabase(opts.Dsn, databaseName(opts.Prefix, t.Name()))
+	db, err := database.New(append(opts.Database, database.Dsn(dsn))...)
+
+	if err != nil {
+		t.Fatalf("database init failed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := db.Drop(); err != nil {
+			t.Errorf("database deletion failed: %v", err)
+		}
+
+		if err := db.Close(); err != nil {
+			t.Errorf("database closing failed: %v", err)
+		}
+	})
+
+	return db
+}
+
+// databaseName builds database name from the prefix, the test name and
+// random suffix, characters not allowed in database names are replaced.
+func databaseName(prefix, test string) string {
+	suffix := "_" + primitive.NewObjectID().Hex()
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+
+		return '_'
+	}, prefix+"_"+test)
+
+	if len(name) > maxNameLength-len(suffix) {
+		name = name[:maxNameLength-len(suffix)]
+	}
+
+	return name + suffix
+}
+
+// dsnWithDatabase replaces database name of the connection string keeping
+// hosts, credentials and connection options.
+func dsnWithDatabase(dsn, name string) string {
+	hosts := strings.Index(dsn, "://") + len("://")
+	end := len(dsn)
+
+	if i := strings.IndexAny(dsn[hosts:], "/?"); i >= 0 {
+		end = hosts + i
+	}
+
+	query := ""
+
+	if i := strings.Index(dsn[end:], "?"); i >= 0 {
+		query = dsn[end+i:]
+	}
+
+	return dsn[:end] + "/" + name + query
+}
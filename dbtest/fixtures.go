@@ -0,0 +1,229 @@
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrorFixtureFormat  = errors.New("fixture format is not supported")
+	ErrorFixtureInvalid = errors.New("fixture collection must be an array of documents")
+)
+
+// LoadFixtures inserts documents of fixture files to the database. Fixture
+// is document with collection names as keys and arrays of documents as
+// values, written in Extended JSON (.json) or YAML (.yaml, .yml). YAML values
+// may use Extended JSON type wrappers, e.g. {$oid: ...}, timestamps are
+// loaded as dates.
+func LoadFixtures(ctx context.Context, db database.Database, paths ...string) error {
+	for _, path := range paths {
+		fixture, err := ReadFixture(path)
+
+		if err != nil {
+			return err
+		}
+
+		for name, docs := range fixture {
+			if len(docs) == 0 {
+				continue
+			}
+
+			_, err = db.Collection(name).InsertMany(ctx, docs)
+
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadFixture reads documents of the fixture file by collection names.
+func ReadFixture(path string) (map[string][]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		data, err = yamlToJSON(data)
+	default:
+		err = ErrorFixtureFormat
+	}
+
+	if err == nil {
+		var fixture map[string][]interface{}
+		fixture, err = parseFixture(data)
+
+		if err == nil {
+			return fixture, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %w", path, err)
+}
+
+func parseFixture(data []byte) (map[string][]interface{}, error) {
+	var doc bson.D
+	err := bson.UnmarshalExtJSON(data, false, &doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := make(map[string][]interface{}, len(doc))
+
+	for _, el := range doc {
+		arr, ok := el.Value.(bson.A)
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrorFixtureInvalid, el.Key)
+		}
+
+		docs := make([]interface{}, len(arr))
+
+		for i, val := range arr {
+			if _, ok := val.(bson.D); !ok {
+				return nil, fmt.Errorf("%w: %s", ErrorFixtureInvalid, el.Key)
+			}
+
+			docs[i] = val
+		}
+
+		fixture[el.Key] = docs
+	}
+
+	return fixture, nil
+}
+
+// yamlToJSON converts YAML document to relaxed Extended JSON keeping order of
+// keys, floats are written with fraction to be parsed as doubles.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal(data, &node)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(node.Content) == 0 {
+		return []byte("{}"), nil
+	}
+
+	buf := &bytes.Buffer{}
+	err = writeYAMLNode(buf, node.Content[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeYAMLNode(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return writeYAMLNode(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+
+			if err := writeYAMLNode(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := writeYAMLNode(buf, item); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteByte(']')
+		return nil
+	}
+
+	switch node.ShortTag() {
+	case "!!null":
+		buf.WriteString("null")
+	case "!!bool", "!!int":
+		var val interface{}
+
+		if err := node.Decode(&val); err != nil {
+			return err
+		}
+
+		data, _ := json.Marshal(val)
+		buf.Write(data)
+	case "!!float":
+		var val float64
+
+		if err := node.Decode(&val); err != nil {
+			return err
+		}
+
+		switch {
+		case math.IsNaN(val):
+			buf.WriteString(`{"$numberDouble":"NaN"}`)
+		case math.IsInf(val, 1):
+			buf.WriteString(`{"$numberDouble":"Infinity"}`)
+		case math.IsInf(val, -1):
+			buf.WriteString(`{"$numberDouble":"-Infinity"}`)
+		default:
+			str := strconv.FormatFloat(val, 'f', -1, 64)
+
+			if !strings.Contains(str, ".") {
+				str += ".0"
+			}
+
+			buf.WriteString(str)
+		}
+	case "!!timestamp":
+		var val time.Time
+
+		if err := node.Decode(&val); err != nil {
+			return err
+		}
+
+		data, _ := json.Marshal(map[string]string{"$date": val.UTC().Format(time.RFC3339Nano)})
+		buf.Write(data)
+	default:
+		data, _ := json.Marshal(node.Value)
+		buf.Write(data)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package dbtest
+
+import (
+	database "github.com/sidmal/mgo-wrapper"
+)
+
+const (
+	DefaultDsn    = "mongodb://localhost:27017/test"
+	DefaultPrefix = "test"
+)
+
+type Options struct {
+	Dsn      string
+	Prefix   string
+	Database []database.Option
+}
+
+type Option func(*Options)
+
+// Dsn sets connection string of test databases, database name of the DSN is
+// replaced with the unique one.
+func Dsn(dsn string) Option {
+	return func(opts *Options) {
+		opts.Dsn = dsn
+	}
+}
+
+func Prefix(prefix string) Option {
+	return func(opts *Options) {
+		opts.Prefix = prefix
+	}
+}
+
+// DatabaseOptions sets options of test databases except DSN, e.g. collection
+// wrappers.
+func DatabaseOptions(options ...database.Option) Option {
+	return func(opts *Options) {
+		opts.Database = append(opts.Database, options...)
+	}
+}
@@ -0,0 +1,101 @@
+package dbtest
+
+import (
+	"context"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Snapshot keeps documents of collections to restore them between tests.
+// Restore skips collections with unchanged content by comparing hashes of
+// dbHash command, other collections are emptied and the kept documents are
+// inserted again, so indexes and options of collections are preserved.
+//
+// Collections are accessed with wrappers of the database, so it should be
+// created without wrappers which modify filters or documents.
+type Snapshot struct {
+	db          database.Database
+	collections []string
+	docs        map[string][]interface{}
+	hashes      map[string]string
+}
+
+func TakeSnapshot(ctx context.Context, db database.Database, collections ...string) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		db:          db,
+		collections: collections,
+		docs:        make(map[string][]interface{}, len(collections)),
+	}
+
+	for _, name := range collections {
+		cursor, err := db.Collection(name).Find(ctx, bson.D{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		var docs []bson.Raw
+		err = cursor.All(ctx, &docs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			snapshot.docs[name] = append(snapshot.docs[name], doc)
+		}
+	}
+
+	snapshot.hashes = snapshot.hash(ctx)
+	return snapshot, nil
+}
+
+// Restore brings collections of the snapshot to the state they had when
+// snapshot was taken.
+func (m *Snapshot) Restore(ctx context.Context) error {
+	hashes := m.hash(ctx)
+
+	for _, name := range m.collections {
+		hash, ok := hashes[name]
+
+		if ok && hash == m.hashes[name] {
+			continue
+		}
+
+		collection := m.db.Collection(name)
+		_, err := collection.DeleteMany(ctx, bson.D{})
+
+		if err != nil {
+			return err
+		}
+
+		if len(m.docs[name]) == 0 {
+			continue
+		}
+
+		_, err = collection.InsertMany(ctx, m.docs[name])
+
+		if err != nil {
+			return err
+		}
+	}
+
+	m.hashes = m.hash(ctx)
+	return nil
+}
+
+// hash returns hashes of content of existing collections of the snapshot,
+// the result is empty when dbHash command is not available.
+func (m *Snapshot) hash(ctx context.Context) map[string]string {
+	cmd := bson.D{{Key: "dbHash", Value: 1}, {Key: "collections", Value: m.collections}}
+
+	var res struct {
+		Collections map[string]string `bson:"collections"`
+	}
+
+	if err := m.db.RunCommand(ctx, cmd).Decode(&res); err != nil {
+		return nil
+	}
+
+	return res.Collections
+}
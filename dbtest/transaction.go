@@ -0,0 +1,220 @@
+package dbtest
+
+import (
+	"context"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+)
+
+// transactionDatabase runs collection operations and commands in the session
+// of the test transaction.
+type transactionDatabase struct {
+	database.Database
+	session mongo.Session
+}
+
+type transactionCollection struct {
+	database.CollectionInterface
+	session mongo.Session
+}
+
+// NewTransaction starts session and transaction for the test and returns
+// database which runs collection operations in the transaction, so changes
+// made by the test are isolated without recreation of data. The transaction
+// is aborted and the session is ended at the test cleanup.
+//
+// Transactions require replica set or sharded cluster and are limited in
+// lifetime by the server, collections and indexes should be created before
+// the transaction with MongoDB older than 4.4. Session must not be used
+// concurrently, so the test must not run operations in parallel goroutines.
+// EstimatedDocumentCount and Explain are not supported in transactions, so
+// they run outside of it. Closing of the returned database does not close the
+// database it was created from.
+func NewTransaction(t testing.TB, db database.Database, opts ...*options.TransactionOptions) database.Database {
+	t.Helper()
+
+	session, err := db.StartSession()
+
+	if err != nil {
+		t.Fatalf("session start failed: %v", err)
+	}
+
+	err = session.StartTransaction(opts...)
+
+	if err != nil {
+		session.EndSession(context.Background())
+		t.Fatalf("transaction start failed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+
+		if err := session.AbortTransaction(ctx); err != nil {
+			t.Errorf("transaction abort failed: %v", err)
+		}
+
+		session.EndSession(ctx)
+	})
+
+	return &transactionDatabase{Database: db, session: session}
+}
+
+func (m *transactionDatabase) Close() error {
+	return nil
+}
+
+func (m *transactionDatabase) Collection(name string) database.CollectionInterface {
+	return &transactionCollection{CollectionInterface: m.Database.Collection(name), session: m.session}
+}
+
+func (m *transactionDatabase) RunCommand(
+	ctx context.Context,
+	cmd interface{},
+	opts ...*options.RunCmdOptions,
+) database.SingleResultInterface {
+	return m.Database.RunCommand(mongo.NewSessionContext(ctx, m.session), cmd, opts...)
+}
+
+func (m *transactionCollection) Aggregate(
+	ctx context.Context,
+	pipeline interface{},
+	opts ...*options.AggregateOptions,
+) (database.CursorInterface, error) {
+	return m.CollectionInterface.Aggregate(m.context(ctx), pipeline, opts...)
+}
+
+func (m *transactionCollection) CountDocuments(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.CountOptions,
+) (int64, error) {
+	return m.CollectionInterface.CountDocuments(m.context(ctx), filter, opts...)
+}
+
+func (m *transactionCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	return m.CollectionInterface.DeleteMany(m.context(ctx), filter, opts...)
+}
+
+func (m *transactionCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	return m.CollectionInterface.DeleteOne(m.context(ctx), filter, opts...)
+}
+
+func (m *transactionCollection) Distinct(
+	ctx context.Context,
+	fieldName string,
+	filter interface{},
+	opts ...*options.DistinctOptions,
+) ([]interface{}, error) {
+	return m.CollectionInterface.Distinct(m.context(ctx), fieldName, filter, opts...)
+}
+
+func (m *transactionCollection) Find(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (database.CursorInterface, error) {
+	return m.CollectionInterface.Find(m.context(ctx), filter, opts...)
+}
+
+func (m *transactionCollection) FindOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) database.SingleResultInterface {
+	return m.CollectionInterface.FindOne(m.context(ctx), filter, opts...)
+}
+
+func (m *transactionCollection) FindOneAndDelete(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) database.SingleResultInterface {
+	return m.CollectionInterface.FindOneAndDelete(m.context(ctx), filter, opts...)
+}
+
+func (m *transactionCollection) FindOneAndReplace(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) database.SingleResultInterface {
+	return m.CollectionInterface.FindOneAndReplace(m.context(ctx), filter, replacement, opts...)
+}
+
+func (m *transactionCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) database.SingleResultInterface {
+	return m.CollectionInterface.FindOneAndUpdate(m.context(ctx), filter, update, opts...)
+}
+
+func (m *transactionCollection) InsertMany(
+	ctx context.Context,
+	documents []interface{},
+	opts ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	return m.CollectionInterface.InsertMany(m.context(ctx), documents, opts...)
+}
+
+func (m *transactionCollection) InsertOne(
+	ctx context.Context,
+	document interface{},
+	opts ...*options.InsertOneOptions,
+) (*mongo.InsertOneResult, error) {
+	return m.CollectionInterface.InsertOne(m.context(ctx), document, opts...)
+}
+
+func (m *transactionCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	return m.CollectionInterface.ReplaceOne(m.context(ctx), filter, replacement, opts...)
+}
+
+func (m *transactionCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	return m.CollectionInterface.UpdateMany(m.context(ctx), filter, update, opts...)
+}
+
+func (m *transactionCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	return m.CollectionInterface.UpdateOne(m.context(ctx), filter, update, opts...)
+}
+
+func (m *transactionCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	return m.CollectionInterface.BulkWrite(m.context(ctx), models, opts...)
+}
+
+func (m *transactionCollection) context(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return mongo.NewSessionContext(ctx, m.session)
+}
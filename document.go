@@ -0,0 +1,200 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+)
+
+var (
+	ErrorPipelineType = errors.New("pipeline must be a slice of stages")
+)
+
+// Stages which must be the first in pipeline. Restricting match is placed
+// after stages reading documents of the collection, stages not reading them
+// are left as is.
+var (
+	matchAfterStages = map[string]bool{
+		"$geoNear":      true,
+		"$search":       true,
+		"$vectorSearch": true,
+	}
+	matchSkipStages = map[string]bool{
+		"$collStats":  true,
+		"$indexStats": true,
+		"$documents":  true,
+	}
+)
+
+// toDocument converts any value accepted by the driver as document (maps,
+// structs, bson.D, bson.Raw) to ordered document to make it modifiable.
+func toDocument(val interface{}) (bson.D, error) {
+	if val == nil {
+		return bson.D{}, nil
+	}
+
+	data, ok := val.(bson.Raw)
+
+	if !ok {
+		var err error
+		data, err = bson.Marshal(val)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var doc bson.D
+	err := bson.Unmarshal(data, &doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func isPipeline(val interface{}) bool {
+	if val == nil {
+		return false
+	}
+
+	switch val.(type) {
+	case bson.D, bson.Raw, []byte:
+		return false
+	}
+
+	kind := reflect.TypeOf(val).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+func toPipeline(val interface{}) bson.A {
+	if val == nil {
+		return bson.A{}
+	}
+
+	rv := reflect.ValueOf(val)
+	result := make(bson.A, 0, rv.Len())
+
+	for i := 0; i < rv.Len(); i++ {
+		result = append(result, rv.Index(i).Interface())
+	}
+
+	return result
+}
+
+func lookup(doc bson.D, key string) (interface{}, bool) {
+	for _, el := range doc {
+		if el.Key == key {
+			return el.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+func set(doc bson.D, key string, val interface{}) bson.D {
+	for i, el := range doc {
+		if el.Key == key {
+			doc[i].Value = val
+			return doc
+		}
+	}
+
+	return append(doc, bson.E{Key: key, Value: val})
+}
+
+func unset(doc bson.D, key string) bson.D {
+	result := make(bson.D, 0, len(doc))
+
+	for _, el := range doc {
+		if el.Key != key {
+			result = append(result, el)
+		}
+	}
+
+	return result
+}
+
+// andFilter restricts filter of any supported type with additional conditions
+// without inspecting the original filter.
+func andFilter(filter interface{}, cond bson.D) interface{} {
+	if filter == nil {
+		return cond
+	}
+
+	return bson.D{{Key: "$and", Value: bson.A{filter, cond}}}
+}
+
+// matchPipeline restricts aggregation pipeline with $match stage of the
+// condition. Pipeline values which are not slices of stages can't be
+// restricted, so they are rejected instead of being passed unfiltered.
+func matchPipeline(pipeline interface{}, cond bson.D) (bson.A, error) {
+	if pipeline != nil && !isPipeline(pipeline) {
+		return nil, fmt.Errorf("%w: %T", ErrorPipelineType, pipeline)
+	}
+
+	stages := toPipeline(pipeline)
+	match := bson.D{{Key: "$match", Value: cond}}
+
+	if len(stages) == 0 {
+		return bson.A{match}, nil
+	}
+
+	first, err := toDocument(stages[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(first) > 0 && matchSkipStages[first[0].Key] {
+		return stages, nil
+	}
+
+	if len(first) > 0 && matchAfterStages[first[0].Key] {
+		return append(bson.A{stages[0], match}, stages[1:]...), nil
+	}
+
+	return append(bson.A{match}, stages...), nil
+}
+
+// addUpdateOperator adds field to the operator of update document, or stage
+// to the end of update pipeline.
+func addUpdateOperator(update interface{}, operator, field string, val interface{}) (interface{}, error) {
+	if isPipeline(update) {
+		stage := bson.D{{Key: "$set", Value: bson.D{{Key: field, Value: val}}}}
+
+		if operator == "$inc" {
+			stage = bson.D{{Key: "$set", Value: bson.D{{Key: field, Value: bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$" + field, 0}}}, val}}}}}}}
+		}
+
+		return append(toPipeline(update), stage), nil
+	}
+
+	doc, err := toDocument(update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields, _ := lookup(doc, operator)
+	fieldsDoc, _ := fields.(bson.D)
+
+	return set(doc, operator, set(fieldsDoc, field, val)), nil
+}
+
+func toInt64(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestToDocument_Ok(t *testing.T) {
+	raw, err := bson.Marshal(bson.M{"field": "value"})
+	assert.NoError(t, err)
+
+	values := []interface{}{
+		bson.M{"field": "value"},
+		bson.D{{Key: "field", Value: "value"}},
+		struct {
+			Field string `bson:"field"`
+		}{Field: "value"},
+		bson.Raw(raw),
+	}
+
+	for _, v := range values {
+		doc, err := toDocument(v)
+		assert.NoError(t, err)
+		assert.Equal(t, bson.D{{Key: "field", Value: "value"}}, doc)
+	}
+
+	doc, err := toDocument(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, doc)
+}
+
+func TestToDocument_Error(t *testing.T) {
+	_, err := toDocument("string")
+	assert.Error(t, err)
+}
+
+func TestIsPipeline_Ok(t *testing.T) {
+	assert.True(t, isPipeline(mongo.Pipeline{}))
+	assert.True(t, isPipeline([]bson.M{}))
+	assert.True(t, isPipeline(bson.A{}))
+	assert.False(t, isPipeline(bson.M{}))
+	assert.False(t, isPipeline(bson.D{}))
+	assert.False(t, isPipeline(bson.Raw{}))
+	assert.False(t, isPipeline(nil))
+}
+
+func TestAndFilter_Ok(t *testing.T) {
+	cond := bson.D{{Key: "field", Value: 1}}
+	assert.Equal(t, cond, andFilter(nil, cond))
+
+	filter := bson.M{"other": 2}
+	assert.Equal(t, bson.D{{Key: "$and", Value: bson.A{filter, cond}}}, andFilter(filter, cond))
+}
+
+func TestAddUpdateOperator_Ok(t *testing.T) {
+	update, err := addUpdateOperator(bson.M{"$inc": bson.M{"counter": 1}}, "$inc", "_v", int64(1))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "$inc", Value: bson.D{{Key: "counter", Value: int32(1)}, {Key: "_v", Value: int64(1)}}}}, update)
+
+	update, err = addUpdateOperator(bson.M{"$set": bson.M{"field": "value"}}, "$inc", "_v", int64(1))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "field", Value: "value"}}},
+		{Key: "$inc", Value: bson.D{{Key: "_v", Value: int64(1)}}},
+	}, update)
+
+	update, err = addUpdateOperator([]bson.M{{"$set": bson.M{"field": "value"}}}, "$set", "updated", true)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{
+		bson.M{"$set": bson.M{"field": "value"}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "updated", Value: true}}}},
+	}, update)
+}
+
+func TestLookupSetUnset_Ok(t *testing.T) {
+	doc := bson.D{{Key: "a", Value: 1}}
+	doc = set(doc, "b", 2)
+	doc = set(doc, "a", 3)
+	assert.Equal(t, bson.D{{Key: "a", Value: 3}, {Key: "b", Value: 2}}, doc)
+
+	val, ok := lookup(doc, "b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+
+	doc = unset(doc, "a")
+	_, ok = lookup(doc, "a")
+	assert.False(t, ok)
+	assert.Len(t, doc, 1)
+}
+
+func TestMatchPipeline_Ok(t *testing.T) {
+	cond := bson.D{{Key: "field", Value: 1}}
+	match := bson.D{{Key: "$match", Value: cond}}
+
+	pipeline, err := matchPipeline(nil, cond)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{match}, pipeline)
+
+	sort := bson.D{{Key: "$sort", Value: bson.D{{Key: "field", Value: 1}}}}
+	pipeline, err = matchPipeline(mongo.Pipeline{sort}, cond)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{match, sort}, pipeline)
+
+	geoNear := bson.M{"$geoNear": bson.M{"near": bson.A{0, 0}, "distanceField": "distance"}}
+	pipeline, err = matchPipeline([]bson.M{geoNear}, cond)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{geoNear, match}, pipeline)
+
+	collStats := bson.D{{Key: "$collStats", Value: bson.D{{Key: "count", Value: bson.D{}}}}}
+	pipeline, err = matchPipeline(bson.A{collStats}, cond)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{collStats}, pipeline)
+}
+
+func TestMatchPipeline_Error(t *testing.T) {
+	_, err := matchPipeline(bson.D{{Key: "$match", Value: bson.D{}}}, bson.D{})
+	assert.ErrorIs(t, err, ErrorPipelineType)
+
+	_, err = matchPipeline(bson.Raw{}, bson.D{})
+	assert.ErrorIs(t, err, ErrorPipelineType)
+}
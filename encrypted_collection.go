@@ -0,0 +1,448 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+)
+
+// EncryptedCollection transparently encrypts fields of the model in written
+// documents and filters and decrypts them in read results.
+type EncryptedCollection struct {
+	CollectionInterface
+	encryptor *Encryptor
+}
+
+type encryptedCursor struct {
+	CursorInterface
+	encryptor *Encryptor
+}
+
+type encryptedSingleResult struct {
+	SingleResultInterface
+	encryptor *Encryptor
+}
+
+func NewEncryptedCollection(collection CollectionInterface, encryptor *Encryptor) *EncryptedCollection {
+	return &EncryptedCollection{CollectionInterface: collection, encryptor: encryptor}
+}
+
+func Encryption(keys KeyProvider, model interface{}) CollectionWrapper {
+	encryptor := NewEncryptor(keys, model)
+
+	return func(_ Database, collection CollectionInterface) CollectionInterface {
+		return NewEncryptedCollection(collection, encryptor)
+	}
+}
+
+func (m *EncryptedCollection) Aggregate(
+	ctx context.Context,
+	pipeline interface{},
+	opts ...*options.AggregateOptions,
+) (CursorInterface, error) {
+	cursor, err := m.CollectionInterface.Aggregate(ctx, pipeline, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedCursor{CursorInterface: cursor, encryptor: m.encryptor}, nil
+}
+
+func (m *EncryptedCollection) CountDocuments(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.CountOptions,
+) (int64, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return m.CollectionInterface.CountDocuments(ctx, filter, opts...)
+}
+
+func (m *EncryptedCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.DeleteMany(ctx, filter, opts...)
+}
+
+func (m *EncryptedCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.DeleteOne(ctx, filter, opts...)
+}
+
+func (m *EncryptedCollection) Distinct(
+	ctx context.Context,
+	fieldName string,
+	filter interface{},
+	opts ...*options.DistinctOptions,
+) ([]interface{}, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := m.CollectionInterface.Distinct(ctx, fieldName, filter, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		if values[i], err = m.encryptor.decrypt(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// Explain explains the operation with encrypted filter values, pipelines are
+// passed as is like in Aggregate.
+func (m *EncryptedCollection) Explain(
+	ctx context.Context,
+	op ExplainOperation,
+	filter interface{},
+	opts ...ExplainOption,
+) (*ExplainResult, error) {
+	if op != ExplainAggregate {
+		var err error
+		filter, err = m.encryptor.EncryptFilter(filter)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.CollectionInterface.Explain(ctx, op, filter, opts...)
+}
+
+func (m *EncryptedCollection) Find(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (CursorInterface, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := m.CollectionInterface.Find(ctx, filter, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedCursor{CursorInterface: cursor, encryptor: m.encryptor}, nil
+}
+
+func (m *EncryptedCollection) FindOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) SingleResultInterface {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.singleResult(m.CollectionInterface.FindOne(ctx, filter, opts...))
+}
+
+func (m *EncryptedCollection) FindOneAndDelete(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) SingleResultInterface {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.singleResult(m.CollectionInterface.FindOneAndDelete(ctx, filter, opts...))
+}
+
+func (m *EncryptedCollection) FindOneAndReplace(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) SingleResultInterface {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err == nil {
+		replacement, err = m.encryptor.EncryptDocument(replacement)
+	}
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.singleResult(m.CollectionInterface.FindOneAndReplace(ctx, filter, replacement, opts...))
+}
+
+func (m *EncryptedCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err == nil {
+		update, err = m.encryptor.EncryptUpdate(update)
+	}
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.singleResult(m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, opts...))
+}
+
+func (m *EncryptedCollection) InsertMany(
+	ctx context.Context,
+	documents []interface{},
+	opts ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	docs := make([]interface{}, len(documents))
+
+	for i, document := range documents {
+		doc, err := m.encryptor.EncryptDocument(document)
+
+		if err != nil {
+			return nil, err
+		}
+
+		docs[i] = doc
+	}
+
+	return m.CollectionInterface.InsertMany(ctx, docs, opts...)
+}
+
+func (m *EncryptedCollection) InsertOne(
+	ctx context.Context,
+	document interface{},
+	opts ...*options.InsertOneOptions,
+) (*mongo.InsertOneResult, error) {
+	doc, err := m.encryptor.EncryptDocument(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.InsertOne(ctx, doc, opts...)
+}
+
+func (m *EncryptedCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err == nil {
+		replacement, err = m.encryptor.EncryptDocument(replacement)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.ReplaceOne(ctx, filter, replacement, opts...)
+}
+
+func (m *EncryptedCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err == nil {
+		update, err = m.encryptor.EncryptUpdate(update)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateMany(ctx, filter, update, opts...)
+}
+
+func (m *EncryptedCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	filter, err := m.encryptor.EncryptFilter(filter)
+
+	if err == nil {
+		update, err = m.encryptor.EncryptUpdate(update)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (m *EncryptedCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	result := make([]mongo.WriteModel, len(models))
+
+	for i, model := range models {
+		var err error
+
+		switch v := model.(type) {
+		case *mongo.InsertOneModel:
+			model := *v
+			model.Document, err = m.encryptor.EncryptDocument(v.Document)
+			result[i] = &model
+		case *mongo.UpdateOneModel:
+			model := *v
+			model.Filter, err = m.encryptor.EncryptFilter(v.Filter)
+
+			if err == nil {
+				model.Update, err = m.encryptor.EncryptUpdate(v.Update)
+			}
+
+			result[i] = &model
+		case *mongo.UpdateManyModel:
+			model := *v
+			model.Filter, err = m.encryptor.EncryptFilter(v.Filter)
+
+			if err == nil {
+				model.Update, err = m.encryptor.EncryptUpdate(v.Update)
+			}
+
+			result[i] = &model
+		case *mongo.ReplaceOneModel:
+			model := *v
+			model.Filter, err = m.encryptor.EncryptFilter(v.Filter)
+
+			if err == nil {
+				model.Replacement, err = m.encryptor.EncryptDocument(v.Replacement)
+			}
+
+			result[i] = &model
+		case *mongo.DeleteOneModel:
+			model := *v
+			model.Filter, err = m.encryptor.EncryptFilter(v.Filter)
+			result[i] = &model
+		case *mongo.DeleteManyModel:
+			model := *v
+			model.Filter, err = m.encryptor.EncryptFilter(v.Filter)
+			result[i] = &model
+		default:
+			result[i] = model
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.CollectionInterface.BulkWrite(ctx, result, opts...)
+}
+
+func (m *EncryptedCollection) singleResult(res SingleResultInterface) SingleResultInterface {
+	return &encryptedSingleResult{SingleResultInterface: res, encryptor: m.encryptor}
+}
+
+func (m *encryptedCursor) All(ctx context.Context, results interface{}) error {
+	rv := reflect.ValueOf(results)
+
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results argument must be a pointer to a slice, but was a %s", rv.Kind())
+	}
+
+	defer m.CursorInterface.Close(ctx)
+
+	slice := rv.Elem().Slice(0, 0)
+	elemType := slice.Type().Elem()
+
+	for m.CursorInterface.Next(ctx) {
+		elem := reflect.New(elemType)
+
+		if err := m.Decode(elem.Interface()); err != nil {
+			return err
+		}
+
+		slice = reflect.Append(slice, elem.Elem())
+	}
+
+	rv.Elem().Set(slice)
+	return m.CursorInterface.Err()
+}
+
+func (m *encryptedCursor) Decode(val interface{}) error {
+	var raw bson.Raw
+	err := m.CursorInterface.Decode(&raw)
+
+	if err != nil {
+		return err
+	}
+
+	raw, err = m.encryptor.DecryptDocument(raw)
+
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(raw, val)
+}
+
+func (m *encryptedSingleResult) Decode(v interface{}) error {
+	raw, err := m.DecodeBytes()
+
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(raw, v)
+}
+
+func (m *encryptedSingleResult) DecodeBytes() (bson.Raw, error) {
+	raw, err := m.SingleResultInterface.DecodeBytes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.encryptor.DecryptDocument(raw)
+}
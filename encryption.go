@@ -0,0 +1,581 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// EncryptedSubtype is the user defined BSON binary subtype of values
+	// encrypted by Encryptor.
+	EncryptedSubtype byte = 0x80
+
+	encryptionVersion       byte = 1
+	encryptionRandomized    byte = 0
+	encryptionDeterministic byte = 1
+)
+
+var (
+	ErrorEncryptedValueInvalid = errors.New("encrypted value is malformed")
+	ErrorEncryptionKeyIdLength = errors.New("encryption key id is too long")
+	ErrorEncryptedPipeline     = errors.New("update pipeline must not modify encrypted fields")
+	ErrorEncryptedFilter       = errors.New("filter must not match randomized encrypted fields")
+)
+
+// Encryptor encrypts fields of documents tagged with `mgo:"encrypt"` in the
+// model struct with AES-GCM. Fields tagged with `mgo:"encrypt,deterministic"`
+// get the same cipher text for the same value and key, so they may be used in
+// equality filters.
+type Encryptor struct {
+	keys   KeyProvider
+	fields map[string]bool
+}
+
+func NewEncryptor(keys KeyProvider, model interface{}) *Encryptor {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(model)
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t != nil && t.Kind() == reflect.Struct {
+		encryptedFields(t, "", fields)
+	}
+
+	return &Encryptor{keys: keys, fields: fields}
+}
+
+// Fields returns paths of encrypted fields mapped to deterministic mode flag.
+func (m *Encryptor) Fields() map[string]bool {
+	return m.fields
+}
+
+func (m *Encryptor) EncryptValue(val interface{}, deterministic bool) (primitive.Binary, error) {
+	id, key, err := m.keys.ActiveKey()
+
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+
+	return m.encryptValue(val, deterministic, id, key)
+}
+
+// FilterValues returns deterministic cipher texts of the value under all keys
+// starting with the active one, so equality filters match values encrypted
+// before key rotation.
+func (m *Encryptor) FilterValues(val interface{}) (bson.A, error) {
+	active, key, err := m.keys.ActiveKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := m.keys.Keys()
+
+	if err != nil {
+		return nil, err
+	}
+
+	bin, err := m.encryptValue(val, true, active, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := bson.A{bin}
+
+	for _, id := range ids {
+		if id == active {
+			continue
+		}
+
+		key, err := m.keys.Key(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		bin, err := m.encryptValue(val, true, id, key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, bin)
+	}
+
+	return result, nil
+}
+
+func (m *Encryptor) encryptValue(val interface{}, deterministic bool, id string, key []byte) (primitive.Binary, error) {
+	if len(id) > 255 {
+		return primitive.Binary{}, ErrorEncryptionKeyIdLength
+	}
+
+	plaintext, err := bson.Marshal(bson.D{{Key: "v", Value: val}})
+
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+
+	gcm, err := newGCM(key)
+
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+
+	mode := encryptionRandomized
+
+	if deterministic {
+		mode = encryptionDeterministic
+	}
+
+	header := append([]byte{encryptionVersion, mode, byte(len(id))}, id...)
+	nonce := make([]byte, gcm.NonceSize())
+
+	if deterministic {
+		mac := hmac.New(sha256.New, deriveKey(key, "nonce"))
+		mac.Write(header)
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return primitive.Binary{}, err
+	}
+
+	data := append(append([]byte{}, header...), nonce...)
+	data = gcm.Seal(data, nonce, plaintext, header)
+
+	return primitive.Binary{Subtype: EncryptedSubtype, Data: data}, nil
+}
+
+func (m *Encryptor) DecryptValue(bin primitive.Binary) (interface{}, error) {
+	data := bin.Data
+
+	if bin.Subtype != EncryptedSubtype || len(data) < 3 || data[0] != encryptionVersion {
+		return nil, ErrorEncryptedValueInvalid
+	}
+
+	headerLen := 3 + int(data[2])
+
+	if len(data) < headerLen {
+		return nil, ErrorEncryptedValueInvalid
+	}
+
+	key, err := m.keys.Key(string(data[3:headerLen]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < headerLen+gcm.NonceSize() {
+		return nil, ErrorEncryptedValueInvalid
+	}
+
+	nonce := data[headerLen : headerLen+gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, data[headerLen+gcm.NonceSize():], data[:headerLen])
+
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.D
+	err = bson.Unmarshal(plaintext, &doc)
+
+	if err != nil || len(doc) != 1 {
+		return nil, ErrorEncryptedValueInvalid
+	}
+
+	return doc[0].Value, nil
+}
+
+// EncryptDocument encrypts fields of inserted or replacing document.
+func (m *Encryptor) EncryptDocument(document interface{}) (bson.D, error) {
+	doc, err := toDocument(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.encryptDocument(doc, "")
+}
+
+// EncryptUpdate encrypts fields set by $set and $setOnInsert operators of
+// update document. Values of update pipelines are expressions which can't be
+// encrypted, so pipelines modifying encrypted fields are rejected.
+func (m *Encryptor) EncryptUpdate(update interface{}) (interface{}, error) {
+	if isPipeline(update) {
+		return update, m.checkPipeline(update)
+	}
+
+	doc, err := toDocument(update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, el := range doc {
+		if el.Key != "$set" && el.Key != "$setOnInsert" {
+			continue
+		}
+
+		fields, ok := el.Value.(bson.D)
+
+		if !ok {
+			continue
+		}
+
+		doc[i].Value, err = m.encryptDocument(fields, "")
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// EncryptFilter encrypts values compared with deterministic fields in
+// equality conditions ($eq, $ne, $in, $nin) of filter. Conditions are turned
+// to $in and $nin over cipher texts of the value under all keys. Filters on
+// randomized fields are rejected with ErrorEncryptedFilter.
+func (m *Encryptor) EncryptFilter(filter interface{}) (interface{}, error) {
+	if filter == nil {
+		return filter, nil
+	}
+
+	doc, err := toDocument(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.encryptFilter(doc)
+}
+
+// DecryptDocument decrypts all encrypted values of the raw document.
+func (m *Encryptor) DecryptDocument(raw bson.Raw) (bson.Raw, error) {
+	var doc bson.D
+	err := bson.Unmarshal(raw, &doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := m.decrypt(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.Marshal(val)
+}
+
+func (m *Encryptor) encryptDocument(doc bson.D, prefix string) (bson.D, error) {
+	var err error
+
+	for i, el := range doc {
+		path := prefix + el.Key
+
+		if deterministic, ok := m.fields[path]; ok {
+			if el.Value != nil {
+				doc[i].Value, err = m.EncryptValue(el.Value, deterministic)
+			}
+		} else if sub, ok := el.Value.(bson.D); ok && m.hasNested(path) {
+			doc[i].Value, err = m.encryptDocument(sub, path+".")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func (m *Encryptor) encryptFilter(doc bson.D) (bson.D, error) {
+	var err error
+
+	for i, el := range doc {
+		switch el.Key {
+		case "$and", "$or", "$nor":
+			conds, _ := el.Value.(bson.A)
+
+			for j, cond := range conds {
+				if sub, ok := cond.(bson.D); ok {
+					conds[j], err = m.encryptFilter(sub)
+				}
+
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			continue
+		}
+
+		deterministic, ok := m.fields[el.Key]
+
+		if !ok {
+			continue
+		}
+
+		// cipher texts of randomized fields never match, while the plain value
+		// would be sent to the server
+		if !deterministic {
+			return nil, fmt.Errorf("%w: %s", ErrorEncryptedFilter, el.Key)
+		}
+
+		cond, ok := el.Value.(bson.D)
+
+		if !ok || len(cond) == 0 || !strings.HasPrefix(cond[0].Key, "$") {
+			values, err := m.FilterValues(el.Value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			doc[i].Value = bson.D{{Key: "$in", Value: values}}
+			continue
+		}
+
+		for j, op := range cond {
+			var values bson.A
+
+			switch op.Key {
+			case "$eq", "$ne":
+				values, err = m.FilterValues(op.Value)
+			case "$in", "$nin":
+				values, err = m.filterValuesOf(op.Value)
+			default:
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			cond[j] = bson.E{Key: "$in", Value: values}
+
+			if op.Key == "$ne" || op.Key == "$nin" {
+				cond[j].Key = "$nin"
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+func (m *Encryptor) filterValuesOf(val interface{}) (bson.A, error) {
+	values, _ := val.(bson.A)
+	result := make(bson.A, 0, len(values))
+
+	for _, v := range values {
+		encrypted, err := m.FilterValues(v)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, encrypted...)
+	}
+
+	return result, nil
+}
+
+// checkPipeline rejects update pipeline stages which set or compute encrypted
+// fields. Removal of fields and inclusion projections are allowed.
+func (m *Encryptor) checkPipeline(pipeline interface{}) error {
+	for _, stage := range toPipeline(pipeline) {
+		doc, err := toDocument(stage)
+
+		if err != nil {
+			return err
+		}
+
+		for _, el := range doc {
+			switch el.Key {
+			case "$set", "$addFields", "$project":
+				fields, _ := el.Value.(bson.D)
+
+				for _, field := range fields {
+					if el.Key == "$project" && isProjectionFlag(field.Value) {
+						continue
+					}
+
+					if m.isEncrypted(field.Key) {
+						return fmt.Errorf("%w: %s %s", ErrorEncryptedPipeline, el.Key, field.Key)
+					}
+				}
+			case "$replaceRoot", "$replaceWith":
+				if len(m.fields) > 0 {
+					return fmt.Errorf("%w: %s", ErrorEncryptedPipeline, el.Key)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isEncrypted reports whether the path is encrypted field, contains encrypted
+// fields or is inside of encrypted field.
+func (m *Encryptor) isEncrypted(path string) bool {
+	if _, ok := m.fields[path]; ok || m.hasNested(path) {
+		return true
+	}
+
+	for field := range m.fields {
+		if strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isProjectionFlag(val interface{}) bool {
+	switch val.(type) {
+	case bool, int32, int64, float64:
+		return true
+	}
+
+	return false
+}
+
+func (m *Encryptor) decrypt(val interface{}) (interface{}, error) {
+	var err error
+
+	switch v := val.(type) {
+	case primitive.Binary:
+		if v.Subtype == EncryptedSubtype {
+			return m.DecryptValue(v)
+		}
+	case bson.D:
+		for i, el := range v {
+			if v[i].Value, err = m.decrypt(el.Value); err != nil {
+				return nil, err
+			}
+		}
+	case bson.A:
+		for i, el := range v {
+			if v[i], err = m.decrypt(el); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return val, nil
+}
+
+func (m *Encryptor) hasNested(path string) bool {
+	for field := range m.fields {
+		if strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+func encryptedFields(t reflect.Type, prefix string, fields map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		name, inline := bsonFieldName(sf)
+
+		if name == "-" {
+			continue
+		}
+
+		tags := strings.Split(sf.Tag.Get("mgo"), ",")
+
+		if tags[0] == "encrypt" {
+			fields[prefix+name] = len(tags) > 1 && tags[1] == "deterministic"
+			continue
+		}
+
+		ft := sf.Type
+
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() != reflect.Struct || ft == timeType {
+			continue
+		}
+
+		if inline {
+			encryptedFields(ft, prefix, fields)
+		} else {
+			encryptedFields(ft, prefix+name+".", fields)
+		}
+	}
+}
+
+// bsonFieldName returns key of struct field the same way as default struct
+// codec of the driver does.
+func bsonFieldName(sf reflect.StructField) (string, bool) {
+	tag, ok := sf.Tag.Lookup("bson")
+
+	if !ok && !strings.Contains(string(sf.Tag), ":") && len(sf.Tag) > 0 {
+		tag = string(sf.Tag)
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	inline := false
+
+	for _, part := range parts[1:] {
+		if part == "inline" {
+			inline = true
+		}
+	}
+
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+
+	return name, inline
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
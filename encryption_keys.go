@@ -0,0 +1,108 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+const (
+	EncryptionKeySize = 32
+)
+
+var (
+	ErrorEncryptionKeyNotFound = errors.New("encryption key not found")
+	ErrorEncryptionKeyInvalid  = errors.New("encryption key must be 32 bytes long")
+)
+
+// KeyProvider provides keys for field encryption. New values are encrypted
+// with active key, identifier of the key is stored with encrypted value, so
+// values encrypted with previous keys are still readable after rotation.
+// Keys lists identifiers of all keys to match deterministic values encrypted
+// with any of them.
+type KeyProvider interface {
+	ActiveKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+	Keys() ([]string, error)
+}
+
+type LocalKeyProvider struct {
+	active string
+	keys   map[string][]byte
+}
+
+type localKeyFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+func NewLocalKeyProvider(active string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	for id, key := range keys {
+		if len(key) != EncryptionKeySize {
+			return nil, fmt.Errorf("%w: %s", ErrorEncryptionKeyInvalid, id)
+		}
+	}
+
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrorEncryptionKeyNotFound, active)
+	}
+
+	return &LocalKeyProvider{active: active, keys: keys}, nil
+}
+
+// LoadLocalKeyProvider reads keys from JSON file with base64 encoded keys:
+// {"active": "key2", "keys": {"key1": "...", "key2": "..."}}.
+func LoadLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var file localKeyFile
+	err = json.Unmarshal(data, &file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+
+	for id, encoded := range file.Keys {
+		keys[id], err = base64.StdEncoding.DecodeString(encoded)
+
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+	}
+
+	return NewLocalKeyProvider(file.Active, keys)
+}
+
+func (m *LocalKeyProvider) ActiveKey() (string, []byte, error) {
+	return m.active, m.keys[m.active], nil
+}
+
+func (m *LocalKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := m.keys[id]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrorEncryptionKeyNotFound, id)
+	}
+
+	return key, nil
+}
+
+func (m *LocalKeyProvider) Keys() ([]string, error) {
+	ids := make([]string, 0, len(m.keys))
+
+	for id := range m.keys {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type EncryptedAddress struct {
+	City   string `bson:"city"`
+	Street string `bson:"street" mgo:"encrypt"`
+}
+
+type EncryptedStub struct {
+	Id      string            `bson:"_id"`
+	Name    string            `bson:"name"`
+	Ssn     string            `bson:"ssn" mgo:"encrypt,deterministic"`
+	Card    string            `bson:"card" mgo:"encrypt"`
+	Amount  float64           `mgo:"encrypt"`
+	Address *EncryptedAddress `bson:"address"`
+}
+
+func newTestKeyProvider(t *testing.T, active string) *LocalKeyProvider {
+	keys, err := NewLocalKeyProvider(active, map[string][]byte{
+		"key1": bytes.Repeat([]byte{1}, EncryptionKeySize),
+		"key2": bytes.Repeat([]byte{2}, EncryptionKeySize),
+	})
+	assert.NoError(t, err)
+	return keys
+}
+
+func TestEncryptor_Fields_Ok(t *testing.T) {
+	encryptor := NewEncryptor(nil, &EncryptedStub{})
+	assert.Equal(t, map[string]bool{
+		"ssn":            true,
+		"card":           false,
+		"amount":         false,
+		"address.street": false,
+	}, encryptor.Fields())
+}
+
+func TestEncryptor_Value_Ok(t *testing.T) {
+	encryptor := NewEncryptor(newTestKeyProvider(t, "key1"), nil)
+
+	randomized1, err := encryptor.EncryptValue("value", false)
+	assert.NoError(t, err)
+	assert.Equal(t, EncryptedSubtype, randomized1.Subtype)
+
+	randomized2, err := encryptor.EncryptValue("value", false)
+	assert.NoError(t, err)
+	assert.NotEqual(t, randomized1.Data, randomized2.Data)
+
+	deterministic1, err := encryptor.EncryptValue("value", true)
+	assert.NoError(t, err)
+
+	deterministic2, err := encryptor.EncryptValue("value", true)
+	assert.NoError(t, err)
+	assert.Equal(t, deterministic1.Data, deterministic2.Data)
+
+	for _, bin := range []primitive.Binary{randomized1, randomized2, deterministic1} {
+		val, err := encryptor.DecryptValue(bin)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+	}
+
+	val, err := NewEncryptor(newTestKeyProvider(t, "key2"), nil).DecryptValue(randomized1)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	rotated, err := NewEncryptor(newTestKeyProvider(t, "key2"), nil).EncryptValue("value", true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, deterministic1.Data, rotated.Data)
+}
+
+func TestEncryptor_Value_Error(t *testing.T) {
+	encryptor := NewEncryptor(newTestKeyProvider(t, "key1"), nil)
+
+	bin, err := encryptor.EncryptValue("value", false)
+	assert.NoError(t, err)
+
+	bin.Data[len(bin.Data)-1] ^= 1
+	_, err = encryptor.DecryptValue(bin)
+	assert.Error(t, err)
+
+	_, err = encryptor.DecryptValue(primitive.Binary{Subtype: EncryptedSubtype, Data: []byte{1}})
+	assert.Equal(t, ErrorEncryptedValueInvalid, err)
+
+	keys, err := NewLocalKeyProvider("key3", map[string][]byte{"key3": bytes.Repeat([]byte{3}, EncryptionKeySize)})
+	assert.NoError(t, err)
+
+	bin, err = NewEncryptor(keys, nil).EncryptValue("value", false)
+	assert.NoError(t, err)
+
+	_, err = encryptor.DecryptValue(bin)
+	assert.ErrorIs(t, err, ErrorEncryptionKeyNotFound)
+}
+
+func TestEncryptor_Document_Ok(t *testing.T) {
+	encryptor := NewEncryptor(newTestKeyProvider(t, "key1"), &EncryptedStub{})
+	stub := &EncryptedStub{
+		Id:      "1",
+		Name:    "name",
+		Ssn:     "123",
+		Card:    "4111",
+		Amount:  10.5,
+		Address: &EncryptedAddress{City: "city", Street: "street"},
+	}
+
+	doc, err := encryptor.EncryptDocument(stub)
+	assert.NoError(t, err)
+
+	m := doc.Map()
+	assert.Equal(t, "name", m["name"])
+	assert.IsType(t, primitive.Binary{}, m["ssn"])
+	assert.IsType(t, primitive.Binary{}, m["card"])
+	assert.IsType(t, primitive.Binary{}, m["amount"])
+	assert.Equal(t, "city", m["address"].(bson.D).Map()["city"])
+	assert.IsType(t, primitive.Binary{}, m["address"].(bson.D).Map()["street"])
+
+	raw, err := bson.Marshal(doc)
+	assert.NoError(t, err)
+
+	raw, err = encryptor.DecryptDocument(raw)
+	assert.NoError(t, err)
+
+	var result EncryptedStub
+	err = bson.Unmarshal(raw, &result)
+	assert.NoError(t, err)
+	assert.Equal(t, stub, &result)
+}
+
+func TestEncryptor_Filter_Ok(t *testing.T) {
+	encryptor := NewEncryptor(newTestKeyProvider(t, "key1"), &EncryptedStub{})
+	expected, err := encryptor.EncryptValue("123", true)
+	assert.NoError(t, err)
+
+	rotated, err := NewEncryptor(newTestKeyProvider(t, "key2"), nil).EncryptValue("123", true)
+	assert.NoError(t, err)
+
+	filter, err := encryptor.EncryptFilter(bson.M{"ssn": "123", "name": "name"})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "$in", Value: bson.A{expected, rotated}}}, filter.(bson.D).Map()["ssn"])
+	assert.Equal(t, "name", filter.(bson.D).Map()["name"])
+
+	filter, err = encryptor.EncryptFilter(bson.M{"ssn": bson.M{"$ne": "123"}})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "ssn", Value: bson.D{{Key: "$nin", Value: bson.A{expected, rotated}}}}}, filter)
+
+	filter, err = encryptor.EncryptFilter(bson.M{"$or": bson.A{bson.M{"ssn": bson.M{"$in": bson.A{"123"}}}}})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, filter.(bson.D)[0].Value.(bson.A)[0].(bson.D)[0].Value.(bson.D)[0].Value.(bson.A)[0])
+
+	update, err := encryptor.EncryptUpdate(bson.M{"$set": bson.M{"card": "4111", "address.street": "street"}})
+	assert.NoError(t, err)
+
+	set := update.(bson.D)[0].Value.(bson.D).Map()
+	assert.IsType(t, primitive.Binary{}, set["card"])
+	assert.IsType(t, primitive.Binary{}, set["address.street"])
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "name", Value: "$card"}}}},
+		{{Key: "$unset", Value: "card"}},
+		{{Key: "$project", Value: bson.D{{Key: "ssn", Value: 1}}}},
+	}
+	update, err = encryptor.EncryptUpdate(pipeline)
+	assert.NoError(t, err)
+	assert.Equal(t, pipeline, update)
+}
+
+func TestEncryptor_Update_Error(t *testing.T) {
+	encryptor := NewEncryptor(newTestKeyProvider(t, "key1"), &EncryptedStub{})
+	pipelines := []interface{}{
+		mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "card", Value: "4111"}}}}},
+		[]bson.M{{"$addFields": bson.M{"address": bson.M{"street": "street"}}}},
+		[]bson.M{{"$project": bson.M{"ssn": bson.M{"$concat": bson.A{"$name", "1"}}}}},
+		[]bson.M{{"$replaceWith": bson.M{"ssn": "123"}}},
+	}
+
+	for _, pipeline := range pipelines {
+		_, err := encryptor.EncryptUpdate(pipeline)
+		assert.ErrorIs(t, err, ErrorEncryptedPipeline)
+	}
+}
+
+func TestEncryptor_Filter_Error(t *testing.T) {
+	encryptor := NewEncryptor(newTestKeyProvider(t, "key1"), &EncryptedStub{})
+	filters := []interface{}{
+		bson.M{"card": "4111"},
+		bson.M{"address.street": bson.M{"$in": bson.A{"street"}}},
+		bson.M{"$and": bson.A{bson.M{"name": "name"}, bson.M{"amount": 10}}},
+	}
+
+	for _, filter := range filters {
+		_, err := encryptor.EncryptFilter(filter)
+		assert.ErrorIs(t, err, ErrorEncryptedFilter)
+	}
+}
+
+func TestLoadLocalKeyProvider_Ok(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, EncryptionKeySize))
+	path := filepath.Join(dir, "keys.json")
+	err = ioutil.WriteFile(path, []byte(`{"active": "key1", "keys": {"key1": "`+key+`"}}`), 0600)
+	assert.NoError(t, err)
+
+	keys, err := LoadLocalKeyProvider(path)
+	assert.NoError(t, err)
+
+	id, val, err := keys.ActiveKey()
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", id)
+	assert.Len(t, val, EncryptionKeySize)
+
+	ids, err := newTestKeyProvider(t, "key2").Keys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key1", "key2"}, ids)
+
+	_, err = NewLocalKeyProvider("key1", map[string][]byte{"key1": []byte("short")})
+	assert.ErrorIs(t, err, ErrorEncryptionKeyInvalid)
+
+	_, err = NewLocalKeyProvider("key2", map[string][]byte{})
+	assert.ErrorIs(t, err, ErrorEncryptionKeyNotFound)
+}
+
+type EncryptionTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Encryption(t *testing.T) {
+	suite.Run(t, new(EncryptionTestSuite))
+}
+
+func (suite *EncryptionTestSuite) SetupTest() {
+	opts := []Option{
+		Dsn("mongodb://localhost:27017/test"),
+		WrapCollection("stubs", Encryption(newTestKeyProvider(suite.T(), "key1"), &EncryptedStub{})),
+	}
+	db, err := New(opts...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *EncryptionTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *EncryptionTestSuite) TestEncryption_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+	stub := &EncryptedStub{Id: "1", Name: "name", Ssn: "123", Card: "4111", Amount: 10}
+
+	_, err := collection.InsertOne(ctx, stub)
+	assert.NoError(suite.T(), err)
+
+	var result EncryptedStub
+	err = collection.FindOne(ctx, bson.M{"ssn": "123"}).Decode(&result)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), stub, &result)
+
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": "1"}, bson.M{"$set": bson.M{"card": "5500"}})
+	assert.NoError(suite.T(), err)
+
+	cursor, err := collection.Find(ctx, bson.M{"ssn": bson.M{"$in": bson.A{"123", "456"}}})
+	assert.NoError(suite.T(), err)
+
+	var results []*EncryptedStub
+	err = cursor.All(ctx, &results)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 1)
+	assert.Equal(suite.T(), "5500", results[0].Card)
+
+	var raw bson.M
+	cursor, err = collection.Aggregate(ctx, []bson.M{{"$project": bson.M{"ssn": 1}}})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), cursor.Next(ctx))
+	err = cursor.Decode(&raw)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "123", raw["ssn"])
+	assert.NoError(suite.T(), cursor.Close(ctx))
+}
+
+func TestEncryptedCollection_Explain_Ok(t *testing.T) {
+	inner := &explainCollection{plan: &ExplainResult{}}
+	collection := NewEncryptedCollection(inner, NewEncryptor(newTestKeyProvider(t, "key1"), &EncryptedStub{}))
+
+	_, err := collection.Explain(context.Background(), ExplainFind, bson.M{"ssn": "123"})
+	assert.NoError(t, err)
+
+	cond := inner.filter.(bson.D).Map()["ssn"].(bson.D)
+	assert.Equal(t, "$in", cond[0].Key)
+	assert.Len(t, cond[0].Value, 2)
+}
@@ -0,0 +1,381 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	ExplainFind      ExplainOperation = "find"
+	ExplainAggregate ExplainOperation = "aggregate"
+	ExplainCount     ExplainOperation = "count"
+	ExplainUpdate    ExplainOperation = "update"
+	ExplainDelete    ExplainOperation = "delete"
+
+	ExplainQueryPlanner      = "queryPlanner"
+	ExplainExecutionStats    = "executionStats"
+	ExplainAllPlansExecution = "allPlansExecution"
+
+	StageCollectionScan = "COLLSCAN"
+	StageIndexScan      = "IXSCAN"
+)
+
+var (
+	ErrorExplainOperation = errors.New("explain operation is not supported")
+)
+
+type ExplainOperation string
+
+type ExplainOptions struct {
+	Verbosity  string
+	Projection interface{}
+	Sort       interface{}
+	Hint       interface{}
+	Skip       int64
+	Limit      int64
+	Update     interface{}
+	Multi      bool
+	Collation  *options.Collation
+}
+
+type ExplainOption func(*ExplainOptions)
+
+// ExplainVerbosity sets verbosity of explain, execution statistics are
+// available with executionStats (default) and allPlansExecution only.
+func ExplainVerbosity(verbosity string) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Verbosity = verbosity
+	}
+}
+
+func ExplainProjection(projection interface{}) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Projection = projection
+	}
+}
+
+func ExplainSort(sort interface{}) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Sort = sort
+	}
+}
+
+func ExplainHint(hint interface{}) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Hint = hint
+	}
+}
+
+func ExplainSkip(skip int64) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Skip = skip
+	}
+}
+
+func ExplainLimit(limit int64) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Limit = limit
+	}
+}
+
+// ExplainUpdateDocument sets update document or pipeline of explained update
+// operation, multi makes it update all matched documents.
+func ExplainUpdateDocument(update interface{}, multi bool) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Update = update
+		opts.Multi = multi
+	}
+}
+
+// ExplainMulti makes explained delete operation remove all matched documents.
+func ExplainMulti(multi bool) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Multi = multi
+	}
+}
+
+func ExplainCollation(collation *options.Collation) ExplainOption {
+	return func(opts *ExplainOptions) {
+		opts.Collation = collation
+	}
+}
+
+// ExplainResult is summary of the winning plan of explained operation.
+// Stages contains stages of the winning plan from the root one, execution
+// statistics are zero with queryPlanner verbosity. Raw contains the whole
+// explain reply.
+type ExplainResult struct {
+	WinningStage   string
+	Stages         []string
+	IndexNames     []string
+	CollectionScan bool
+	KeysExamined   int64
+	DocsExamined   int64
+	Returned       int64
+	ExecutionTime  time.Duration
+	Raw            bson.Raw
+}
+
+func (m *Collection) Explain(
+	ctx context.Context,
+	op ExplainOperation,
+	filter interface{},
+	options ...ExplainOption,
+) (*ExplainResult, error) {
+	opts := &ExplainOptions{Verbosity: ExplainExecutionStats}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	cmd, err := explainCommand(m.Name(), op, filter, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	explain := bson.D{{Key: "explain", Value: cmd}, {Key: "verbosity", Value: opts.Verbosity}}
+	raw, err := m.collection.Database().RunCommand(ctx, explain).DecodeBytes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseExplain(raw), nil
+}
+
+func explainCommand(collection string, op ExplainOperation, filter interface{}, opts *ExplainOptions) (bson.D, error) {
+	if op == ExplainAggregate && filter != nil && !isPipeline(filter) {
+		return nil, fmt.Errorf("%w: %T", ErrorPipelineType, filter)
+	}
+
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	var cmd bson.D
+
+	switch op {
+	case ExplainFind:
+		cmd = bson.D{{Key: "find", Value: collection}, {Key: "filter", Value: filter}}
+
+		if opts.Projection != nil {
+			cmd = append(cmd, bson.E{Key: "projection", Value: opts.Projection})
+		}
+
+		if opts.Sort != nil {
+			cmd = append(cmd, bson.E{Key: "sort", Value: opts.Sort})
+		}
+
+		if opts.Hint != nil {
+			cmd = append(cmd, bson.E{Key: "hint", Value: opts.Hint})
+		}
+
+		if opts.Skip > 0 {
+			cmd = append(cmd, bson.E{Key: "skip", Value: opts.Skip})
+		}
+
+		if opts.Limit > 0 {
+			cmd = append(cmd, bson.E{Key: "limit", Value: opts.Limit})
+		}
+	case ExplainAggregate:
+		cmd = bson.D{
+			{Key: "aggregate", Value: collection},
+			{Key: "pipeline", Value: toPipeline(filter)},
+			{Key: "cursor", Value: bson.D{}},
+		}
+
+		if opts.Hint != nil {
+			cmd = append(cmd, bson.E{Key: "hint", Value: opts.Hint})
+		}
+	case ExplainCount:
+		cmd = bson.D{{Key: "count", Value: collection}, {Key: "query", Value: filter}}
+
+		if opts.Hint != nil {
+			cmd = append(cmd, bson.E{Key: "hint", Value: opts.Hint})
+		}
+
+		if opts.Skip > 0 {
+			cmd = append(cmd, bson.E{Key: "skip", Value: opts.Skip})
+		}
+
+		if opts.Limit > 0 {
+			cmd = append(cmd, bson.E{Key: "limit", Value: opts.Limit})
+		}
+	case ExplainUpdate:
+		update := opts.Update
+
+		if update == nil {
+			update = bson.D{{Key: "$set", Value: bson.D{}}}
+		}
+
+		stmt := bson.D{{Key: "q", Value: filter}, {Key: "u", Value: update}, {Key: "multi", Value: opts.Multi}}
+
+		if opts.Hint != nil {
+			stmt = append(stmt, bson.E{Key: "hint", Value: opts.Hint})
+		}
+
+		cmd = bson.D{{Key: "update", Value: collection}, {Key: "updates", Value: bson.A{stmt}}}
+	case ExplainDelete:
+		limit := 1
+
+		if opts.Multi {
+			limit = 0
+		}
+
+		stmt := bson.D{{Key: "q", Value: filter}, {Key: "limit", Value: limit}}
+
+		if opts.Hint != nil {
+			stmt = append(stmt, bson.E{Key: "hint", Value: opts.Hint})
+		}
+
+		cmd = bson.D{{Key: "delete", Value: collection}, {Key: "deletes", Value: bson.A{stmt}}}
+	default:
+		return nil, ErrorExplainOperation
+	}
+
+	if opts.Collation != nil && op != ExplainUpdate && op != ExplainDelete {
+		cmd = append(cmd, bson.E{Key: "collation", Value: opts.Collation.ToDocument()})
+	}
+
+	return cmd, nil
+}
+
+// parseExplain builds result from explain reply. Plans of all shards are
+// walked for sharded collections, statistics of shards are summed.
+func parseExplain(raw bson.Raw) *ExplainResult {
+	result := &ExplainResult{Raw: raw}
+	result.parse(raw)
+
+	// aggregation reply of sharded collection has explain of every shard
+	// keyed by shard name
+	if shards, ok := raw.Lookup("shards").DocumentOK(); ok {
+		elements, _ := shards.Elements()
+
+		for _, el := range elements {
+			if shard, ok := el.Value().DocumentOK(); ok {
+				result.parse(shard)
+			}
+		}
+	}
+
+	return result
+}
+
+func (m *ExplainResult) parse(raw bson.Raw) {
+	planner, stats := explainSections(raw)
+
+	if planner != nil {
+		if plan, ok := planner.Lookup("winningPlan").DocumentOK(); ok {
+			m.walkPlan(plan)
+		}
+	}
+
+	if stats != nil {
+		returned, _ := stats.Lookup("nReturned").AsInt64OK()
+		keysExamined, _ := stats.Lookup("totalKeysExamined").AsInt64OK()
+		docsExamined, _ := stats.Lookup("totalDocsExamined").AsInt64OK()
+		millis, _ := stats.Lookup("executionTimeMillis").AsInt64OK()
+
+		m.Returned += returned
+		m.KeysExamined += keysExamined
+		m.DocsExamined += docsExamined
+
+		if duration := time.Duration(millis) * time.Millisecond; duration > m.ExecutionTime {
+			m.ExecutionTime = duration
+		}
+	}
+}
+
+// explainSections returns query planner and execution statistics sections of
+// explain reply, aggregation reply has them in the $cursor stage when the
+// pipeline was not pushed down to the query layer completely.
+func explainSections(raw bson.Raw) (bson.Raw, bson.Raw) {
+	if planner, ok := raw.Lookup("queryPlanner").DocumentOK(); ok {
+		stats, _ := raw.Lookup("executionStats").DocumentOK()
+		return planner, stats
+	}
+
+	stages, ok := raw.Lookup("stages").ArrayOK()
+
+	if !ok {
+		return nil, nil
+	}
+
+	values, _ := stages.Values()
+
+	for _, val := range values {
+		stage, ok := val.DocumentOK()
+
+		if !ok {
+			continue
+		}
+
+		if cursor, ok := stage.Lookup("$cursor").DocumentOK(); ok {
+			return explainSections(cursor)
+		}
+	}
+
+	return nil, nil
+}
+
+// walkPlan walks winning plan, the first walked plan sets winning stage.
+func (m *ExplainResult) walkPlan(plan bson.Raw) {
+	if queryPlan, ok := plan.Lookup("queryPlan").DocumentOK(); ok {
+		plan = queryPlan
+	}
+
+	if m.WinningStage == "" {
+		m.WinningStage, _ = plan.Lookup("stage").StringValueOK()
+	}
+
+	m.walk(plan)
+}
+
+func (m *ExplainResult) walk(stage bson.Raw) {
+	name, _ := stage.Lookup("stage").StringValueOK()
+	m.Stages = append(m.Stages, name)
+
+	switch name {
+	case StageCollectionScan:
+		m.CollectionScan = true
+	case StageIndexScan:
+		indexName, _ := stage.Lookup("indexName").StringValueOK()
+		m.IndexNames = append(m.IndexNames, indexName)
+	}
+
+	// plans of shards of sharded collection
+	if shards, ok := stage.Lookup("shards").ArrayOK(); ok {
+		values, _ := shards.Values()
+
+		for _, val := range values {
+			shard, ok := val.DocumentOK()
+
+			if !ok {
+				continue
+			}
+
+			if plan, ok := shard.Lookup("winningPlan").DocumentOK(); ok {
+				m.walkPlan(plan)
+			}
+		}
+	}
+
+	if input, ok := stage.Lookup("inputStage").DocumentOK(); ok {
+		m.walk(input)
+	}
+
+	if inputs, ok := stage.Lookup("inputStages").ArrayOK(); ok {
+		values, _ := inputs.Values()
+
+		for _, val := range values {
+			if input, ok := val.DocumentOK(); ok {
+				m.walk(input)
+			}
+		}
+	}
+}
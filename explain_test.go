@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+	"time"
+)
+
+func TestParseExplain_Ok(t *testing.T) {
+	raw, _ := bson.Marshal(bson.M{
+		"queryPlanner": bson.M{
+			"winningPlan": bson.M{
+				"stage": "FETCH",
+				"inputStage": bson.M{
+					"stage":     "IXSCAN",
+					"indexName": "field_string_1",
+				},
+			},
+		},
+		"executionStats": bson.M{
+			"nReturned":           int32(3),
+			"executionTimeMillis": int32(5),
+			"totalKeysExamined":   int32(3),
+			"totalDocsExamined":   int64(3),
+		},
+	})
+
+	result := parseExplain(raw)
+	assert.Equal(t, "FETCH", result.WinningStage)
+	assert.Equal(t, []string{"FETCH", "IXSCAN"}, result.Stages)
+	assert.Equal(t, []string{"field_string_1"}, result.IndexNames)
+	assert.False(t, result.CollectionScan)
+	assert.EqualValues(t, 3, result.Returned)
+	assert.EqualValues(t, 3, result.KeysExamined)
+	assert.EqualValues(t, 3, result.DocsExamined)
+	assert.Equal(t, 5*time.Millisecond, result.ExecutionTime)
+
+	raw, _ = bson.Marshal(bson.M{
+		"stages": bson.A{
+			bson.M{
+				"$cursor": bson.M{
+					"queryPlanner": bson.M{
+						"winningPlan": bson.M{
+							"queryPlan": bson.M{
+								"stage":       "OR",
+								"inputStages": bson.A{bson.M{"stage": "COLLSCAN"}, bson.M{"stage": "IXSCAN", "indexName": "a_1"}},
+							},
+						},
+					},
+				},
+			},
+			bson.M{"$group": bson.M{}},
+		},
+	})
+
+	result = parseExplain(raw)
+	assert.Equal(t, "OR", result.WinningStage)
+	assert.Equal(t, []string{"OR", "COLLSCAN", "IXSCAN"}, result.Stages)
+	assert.True(t, result.CollectionScan)
+}
+
+func TestParseExplain_Sharded_Ok(t *testing.T) {
+	raw, _ := bson.Marshal(bson.M{
+		"queryPlanner": bson.M{
+			"winningPlan": bson.M{
+				"stage": "SHARD_MERGE",
+				"shards": bson.A{
+					bson.M{"shardName": "shard1", "winningPlan": bson.M{"stage": "COLLSCAN"}},
+					bson.M{"shardName": "shard2", "winningPlan": bson.M{"stage": "IXSCAN", "indexName": "a_1"}},
+				},
+			},
+		},
+	})
+
+	result := parseExplain(raw)
+	assert.Equal(t, "SHARD_MERGE", result.WinningStage)
+	assert.Equal(t, []string{"SHARD_MERGE", "COLLSCAN", "IXSCAN"}, result.Stages)
+	assert.Equal(t, []string{"a_1"}, result.IndexNames)
+	assert.True(t, result.CollectionScan)
+
+	raw, _ = bson.Marshal(bson.M{
+		"shards": bson.M{
+			"shard1": bson.M{
+				"queryPlanner":   bson.M{"winningPlan": bson.M{"stage": "COLLSCAN"}},
+				"executionStats": bson.M{"nReturned": int32(2)},
+			},
+		},
+	})
+
+	result = parseExplain(raw)
+	assert.Equal(t, "COLLSCAN", result.WinningStage)
+	assert.True(t, result.CollectionScan)
+	assert.EqualValues(t, 2, result.Returned)
+}
+
+func TestParseExplain_Malformed_Ok(t *testing.T) {
+	raw, _ := bson.Marshal(bson.M{
+		"queryPlanner": bson.M{
+			"winningPlan": bson.M{
+				"stage":      int32(1),
+				"inputStage": bson.M{"stage": "IXSCAN", "indexName": bson.A{}},
+			},
+		},
+	})
+
+	result := parseExplain(raw)
+	assert.Equal(t, "", result.WinningStage)
+	assert.Equal(t, []string{"", "IXSCAN"}, result.Stages)
+	assert.Equal(t, []string{""}, result.IndexNames)
+}
+
+func TestExplainCommand_Ok(t *testing.T) {
+	cmd, err := explainCommand("stubs", ExplainFind, nil, &ExplainOptions{Sort: bson.M{"a": 1}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "find", Value: "stubs"},
+		{Key: "filter", Value: bson.D{}},
+		{Key: "sort", Value: bson.M{"a": 1}},
+		{Key: "limit", Value: int64(1)},
+	}, cmd)
+
+	cmd, err = explainCommand("stubs", ExplainDelete, bson.M{"a": 1}, &ExplainOptions{Multi: true})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "delete", Value: "stubs"},
+		{Key: "deletes", Value: bson.A{bson.D{{Key: "q", Value: bson.M{"a": 1}}, {Key: "limit", Value: 0}}}},
+	}, cmd)
+
+	cmd, err = explainCommand("stubs", ExplainAggregate, nil, &ExplainOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{}, cmd[1].Value)
+
+	_, err = explainCommand("stubs", "unknown", nil, &ExplainOptions{})
+	assert.Equal(t, ErrorExplainOperation, err)
+}
+
+func TestExplainCommand_Error(t *testing.T) {
+	_, err := explainCommand("stubs", ExplainAggregate, bson.M{"$match": bson.M{}}, &ExplainOptions{})
+	assert.ErrorIs(t, err, ErrorPipelineType)
+
+	_, err = explainCommand("stubs", ExplainAggregate, bson.D{}, &ExplainOptions{})
+	assert.ErrorIs(t, err, ErrorPipelineType)
+}
+
+type ExplainTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Explain(t *testing.T) {
+	suite.Run(t, new(ExplainTestSuite))
+}
+
+func (suite *ExplainTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	res, err := db.Collection("stubs").InsertMany(context.Background(), stubs)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+	}
+
+	assert.Len(suite.T(), res.InsertedIDs, len(stubs))
+
+	suite.db = db
+}
+
+func (suite *ExplainTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *ExplainTestSuite) TestExplain_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+	filter := bson.M{"field_string": "value1"}
+
+	result, err := collection.Explain(ctx, ExplainFind, filter)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), result.CollectionScan)
+	assert.EqualValues(suite.T(), 3, result.Returned)
+	assert.EqualValues(suite.T(), len(stubs), result.DocsExamined)
+
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"field_string": 1}})
+	assert.NoError(suite.T(), err)
+
+	for _, op := range []ExplainOperation{ExplainFind, ExplainCount, ExplainUpdate, ExplainDelete} {
+		result, err = collection.Explain(ctx, op, filter)
+		assert.NoError(suite.T(), err)
+		assert.False(suite.T(), result.CollectionScan, op)
+		assert.Equal(suite.T(), []string{"field_string_1"}, result.IndexNames, op)
+	}
+
+	result, err = collection.Explain(ctx, ExplainAggregate, bson.A{bson.M{"$match": filter}, bson.M{"$group": bson.M{"_id": "$field_float"}}})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"field_string_1"}, result.IndexNames)
+}
@@ -0,0 +1,473 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+)
+
+const (
+	ExportCanonical ExportFormat = "canonical"
+	ExportRelaxed   ExportFormat = "relaxed"
+	ExportBSON      ExportFormat = "bson"
+
+	ImportInsert ImportMode = "insert"
+	ImportUpsert ImportMode = "upsert"
+	ImportMerge  ImportMode = "merge"
+
+	DefaultExportProgressInterval = 1000
+	DefaultImportBatchSize        = 1000
+	DefaultImportBatchBytes       = 16 * 1024 * 1024
+)
+
+var (
+	ErrorExportFormat = errors.New("export format is not supported")
+	ErrorImportMode   = errors.New("import mode is not supported")
+	ErrorImportFailed = errors.New("some documents were not imported")
+)
+
+// ExportFormat is format of exported documents: Extended JSON document per
+// line in canonical or relaxed mode, or concatenated BSON documents, the
+// format of mongodump .bson files.
+type ExportFormat string
+
+// ImportMode defines how imported documents are written: insert fails for
+// existing _id, upsert replaces documents with the same _id and merge sets
+// fields of imported documents to documents with the same _id. Documents
+// without _id are always inserted.
+type ImportMode string
+
+// ProgressFunc is called with number of documents processed so far.
+type ProgressFunc func(documents int64)
+
+type ExportOptions struct {
+	Gzip             bool
+	Find             *options.FindOptions
+	Progress         ProgressFunc
+	ProgressInterval int64
+}
+
+type ExportOption func(*ExportOptions)
+
+func ExportGzip(val bool) ExportOption {
+	return func(opts *ExportOptions) {
+		opts.Gzip = val
+	}
+}
+
+// ExportFindOptions sets options of the query of exported documents, e.g.
+// sort or projection.
+func ExportFindOptions(find *options.FindOptions) ExportOption {
+	return func(opts *ExportOptions) {
+		opts.Find = find
+	}
+}
+
+// ExportProgress sets function called every progress interval of documents
+// and after the last document.
+func ExportProgress(progress ProgressFunc) ExportOption {
+	return func(opts *ExportOptions) {
+		opts.Progress = progress
+	}
+}
+
+func ExportProgressInterval(documents int64) ExportOption {
+	return func(opts *ExportOptions) {
+		opts.ProgressInterval = documents
+	}
+}
+
+type ImportOptions struct {
+	BatchSize  int
+	BatchBytes int
+	Progress   ProgressFunc
+}
+
+type ImportOption func(*ImportOptions)
+
+// ImportBatchSize sets maximal number of documents of single bulk write.
+func ImportBatchSize(size int) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.BatchSize = size
+	}
+}
+
+// ImportBatchBytes sets approximate maximal size of documents of single bulk
+// write.
+func ImportBatchBytes(bytes int) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.BatchBytes = bytes
+	}
+}
+
+// ImportProgress sets function called after every bulk write.
+func ImportProgress(progress ProgressFunc) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.Progress = progress
+	}
+}
+
+// ImportError is failure of the imported document at the index of the input.
+type ImportError struct {
+	Index int64
+	Err   error
+}
+
+type ImportResult struct {
+	Documents int64
+	Inserted  int64
+	Matched   int64
+	Modified  int64
+	Upserted  int64
+	Errors    []*ImportError
+}
+
+func (m *ImportError) Error() string {
+	return fmt.Sprintf("document %d: %v", m.Index, m.Err)
+}
+
+func (m *ImportError) Unwrap() error {
+	return m.Err
+}
+
+// Export writes documents of the collection matched by the filter to the
+// writer in the given format and returns number of exported documents.
+func Export(
+	ctx context.Context,
+	collection CollectionInterface,
+	filter interface{},
+	w io.Writer,
+	format ExportFormat,
+	options ...ExportOption,
+) (int64, error) {
+	if format != ExportCanonical && format != ExportRelaxed && format != ExportBSON {
+		return 0, fmt.Errorf("%w: %s", ErrorExportFormat, format)
+	}
+
+	opts := &ExportOptions{ProgressInterval: DefaultExportProgressInterval}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	var gz *gzip.Writer
+
+	if opts.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	out := bufio.NewWriter(w)
+	count, err := exportDocuments(ctx, collection, filter, out, format, opts)
+
+	if err != nil {
+		return count, err
+	}
+
+	err = out.Flush()
+
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+
+	if err != nil {
+		return count, err
+	}
+
+	if opts.Progress != nil {
+		opts.Progress(count)
+	}
+
+	return count, nil
+}
+
+func exportDocuments(
+	ctx context.Context,
+	collection CollectionInterface,
+	filter interface{},
+	w io.Writer,
+	format ExportFormat,
+	opts *ExportOptions,
+) (int64, error) {
+	var findOpts []*options.FindOptions
+
+	if opts.Find != nil {
+		findOpts = append(findOpts, opts.Find)
+	}
+
+	cursor, err := collection.Find(ctx, filter, findOpts...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer cursor.Close(ctx)
+
+	var count int64
+
+	for cursor.Next(ctx) {
+		var raw bson.Raw
+		err = cursor.Decode(&raw)
+
+		if err != nil {
+			return count, err
+		}
+
+		if format != ExportBSON {
+			raw, err = bson.MarshalExtJSON(raw, format == ExportCanonical, false)
+
+			if err != nil {
+				return count, err
+			}
+
+			raw = append(raw, '\n')
+		}
+
+		_, err = w.Write(raw)
+
+		if err != nil {
+			return count, err
+		}
+
+		count++
+
+		if opts.Progress != nil && opts.ProgressInterval > 0 && count%opts.ProgressInterval == 0 {
+			opts.Progress(count)
+		}
+	}
+
+	return count, cursor.Err()
+}
+
+// Import writes documents read from the reader to the collection with
+// unordered bulk writes. Format of the input is detected, gzip compressed
+// input is decompressed. Failures of single documents do not stop the
+// import, ErrorImportFailed is returned with the result when any document
+// failed. Errors of reading the input and errors of bulk writes other than
+// write errors abort the import, documents read before are written or
+// reported in result errors.
+func Import(
+	ctx context.Context,
+	collection CollectionInterface,
+	r io.Reader,
+	mode ImportMode,
+	options ...ImportOption,
+) (*ImportResult, error) {
+	if mode != ImportInsert && mode != ImportUpsert && mode != ImportMerge {
+		return nil, fmt.Errorf("%w: %s", ErrorImportMode, mode)
+	}
+
+	opts := &ImportOptions{
+		BatchSize:  DefaultImportBatchSize,
+		BatchBytes: DefaultImportBatchBytes,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	reader, err := newImportReader(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	var batch []mongo.WriteModel
+	var indexes []int64
+	batchBytes := 0
+
+	for {
+		doc, size, err := reader.next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			importErr, ok := err.(*ImportError)
+
+			if !ok {
+				if len(batch) > 0 {
+					if batchErr := importBatch(ctx, collection, batch, indexes, result, opts); batchErr != nil {
+						return result, batchErr
+					}
+				}
+
+				return result, err
+			}
+
+			importErr.Index = result.Documents
+			result.Errors = append(result.Errors, importErr)
+			result.Documents++
+			continue
+		}
+
+		if len(batch) > 0 && (len(batch) >= opts.BatchSize || batchBytes+size > opts.BatchBytes) {
+			if err := importBatch(ctx, collection, batch, indexes, result, opts); err != nil {
+				return result, err
+			}
+
+			batch = nil
+			indexes = nil
+			batchBytes = 0
+		}
+
+		batch = append(batch, importModel(doc, mode))
+		indexes = append(indexes, result.Documents)
+		batchBytes += size
+		result.Documents++
+	}
+
+	if len(batch) > 0 {
+		if err := importBatch(ctx, collection, batch, indexes, result, opts); err != nil {
+			return result, err
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("%w: %d of %d", ErrorImportFailed, len(result.Errors), result.Documents)
+	}
+
+	return result, nil
+}
+
+func importModel(doc bson.D, mode ImportMode) mongo.WriteModel {
+	id, ok := lookup(doc, "_id")
+
+	if !ok || mode == ImportInsert {
+		return mongo.NewInsertOneModel().SetDocument(doc)
+	}
+
+	filter := bson.D{{Key: "_id", Value: id}}
+
+	if mode == ImportUpsert {
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true)
+	}
+
+	update := bson.D{{Key: "$setOnInsert", Value: filter}}
+
+	if fields := unset(doc, "_id"); len(fields) > 0 {
+		update = bson.D{{Key: "$set", Value: fields}}
+	}
+
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
+}
+
+// importBatch writes the batch and reports failed documents in result errors.
+// Error is returned when the bulk write failed not because of write errors,
+// e.g. the context is done or the connection is lost.
+func importBatch(
+	ctx context.Context,
+	collection CollectionInterface,
+	batch []mongo.WriteModel,
+	indexes []int64,
+	result *ImportResult,
+	opts *ImportOptions,
+) error {
+	res, err := collection.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false))
+
+	if res != nil {
+		result.Inserted += res.InsertedCount
+		result.Matched += res.MatchedCount
+		result.Modified += res.ModifiedCount
+		result.Upserted += res.UpsertedCount
+	}
+
+	for i, err := range bulkWriteErrors(err, len(batch)) {
+		if err != nil {
+			result.Errors = append(result.Errors, &ImportError{Index: indexes[i], Err: err})
+		}
+	}
+
+	if opts.Progress != nil {
+		opts.Progress(result.Documents)
+	}
+
+	if _, ok := err.(mongo.BulkWriteException); err != nil && !ok {
+		return err
+	}
+
+	return nil
+}
+
+// importReader reads documents of Extended JSON lines or concatenated BSON
+// documents.
+type importReader struct {
+	r    *bufio.Reader
+	bson bool
+}
+
+func newImportReader(r io.Reader) (*importReader, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(2)
+
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bufio.NewReader(gz)
+	}
+
+	// size of BSON document is little-endian int32 below 16MB, so the fourth
+	// byte is never a character of JSON
+	head, _ := reader.Peek(4)
+
+	return &importReader{r: reader, bson: len(head) == 4 && head[3] <= 0x01}, nil
+}
+
+// next returns the next document and its size, malformed JSON lines are
+// returned as ImportError to be skipped.
+func (m *importReader) next() (bson.D, int, error) {
+	if m.bson {
+		raw, err := bson.NewFromIOReader(m.r)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var doc bson.D
+		err = bson.Unmarshal(raw, &doc)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return doc, len(raw), nil
+	}
+
+	for {
+		line, err := m.r.ReadBytes('\n')
+
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, 0, err
+		}
+
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+
+		var doc bson.D
+		err = bson.UnmarshalExtJSON(line, false, &doc)
+
+		if err != nil {
+			return nil, 0, &ImportError{Err: err}
+		}
+
+		return doc, len(line), nil
+	}
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"testing"
+)
+
+type exportCollection struct {
+	CollectionInterface
+	docs []bson.Raw
+}
+
+func (m *exportCollection) Find(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.FindOptions,
+) (CursorInterface, error) {
+	return &documentsCursor{docs: m.docs}, nil
+}
+
+func newExportCollection(docs ...interface{}) *exportCollection {
+	collection := &exportCollection{}
+
+	for _, doc := range docs {
+		raw, _ := bson.Marshal(doc)
+		collection.docs = append(collection.docs, raw)
+	}
+
+	return collection
+}
+
+func TestExport_Ok(t *testing.T) {
+	ctx := context.Background()
+	collection := newExportCollection(
+		bson.D{{Key: "_id", Value: int32(1)}, {Key: "field", Value: int64(2)}},
+		bson.D{{Key: "_id", Value: int32(2)}, {Key: "field", Value: 1.5}},
+	)
+
+	buf := &bytes.Buffer{}
+	count, err := Export(ctx, collection, nil, buf, ExportRelaxed)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+	assert.Equal(t, "{\"_id\":1,\"field\":2}\n{\"_id\":2,\"field\":1.5}\n", buf.String())
+
+	buf.Reset()
+	_, err = Export(ctx, collection, nil, buf, ExportCanonical)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `{"_id":{"$numberInt":"1"},"field":{"$numberLong":"2"}}`)
+
+	var progress []int64
+	buf.Reset()
+	_, err = Export(ctx, collection, nil, buf, ExportBSON, ExportGzip(true), ExportProgressInterval(1), ExportProgress(func(n int64) {
+		progress = append(progress, n)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 2}, progress)
+
+	reader, err := newImportReader(buf)
+	assert.NoError(t, err)
+	assert.True(t, reader.bson)
+
+	doc, _, err := reader.next()
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "_id", Value: int32(1)}, {Key: "field", Value: int64(2)}}, doc)
+
+	_, err = Export(ctx, collection, nil, buf, "csv")
+	assert.ErrorIs(t, err, ErrorExportFormat)
+}
+
+func TestImport_Ok(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{}
+	input := "{\"_id\":1,\"field\":\"value\"}\n\n{\"field\":\"value\"}\n{\"_id\":{\"$oid\":\"5f1b0c8e9d1e8a0001a1b2c1\"}}\n"
+
+	var progress []int64
+	res, err := Import(ctx, collection, bytes.NewBufferString(input), ImportMerge, ImportBatchSize(2), ImportProgress(func(n int64) {
+		progress = append(progress, n)
+	}))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, res.Documents)
+	assert.Equal(t, []int{2, 1}, collection.sizes())
+	assert.Equal(t, []int64{2, 3}, progress)
+
+	update, ok := collection.batches[0][0].(*mongo.UpdateOneModel)
+	assert.True(t, ok)
+	assert.Equal(t, bson.D{{Key: "_id", Value: int32(1)}}, update.Filter)
+	assert.Equal(t, bson.D{{Key: "$set", Value: bson.D{{Key: "field", Value: "value"}}}}, update.Update)
+	assert.True(t, *update.Upsert)
+
+	_, ok = collection.batches[0][1].(*mongo.InsertOneModel)
+	assert.True(t, ok)
+
+	update, ok = collection.batches[1][0].(*mongo.UpdateOneModel)
+	assert.True(t, ok)
+	assert.Equal(t, "$setOnInsert", update.Update.(bson.D)[0].Key)
+
+	collection = &bulkWriteCollection{}
+	_, err = Import(ctx, collection, bytes.NewBufferString(input), ImportUpsert)
+	assert.NoError(t, err)
+	_, ok = collection.batches[0][0].(*mongo.ReplaceOneModel)
+	assert.True(t, ok)
+
+	_, err = Import(ctx, collection, bytes.NewBufferString(input), "replace")
+	assert.ErrorIs(t, err, ErrorImportMode)
+}
+
+func TestImport_Error(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{
+		err: mongo.BulkWriteException{
+			WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}}},
+		},
+	}
+	input := "{\"_id\":1}\n{\"_id\":\n{\"_id\":2}\n{\"_id\":3}\n"
+
+	res, err := Import(ctx, collection, bytes.NewBufferString(input), ImportInsert)
+	assert.ErrorIs(t, err, ErrorImportFailed)
+	assert.EqualValues(t, 4, res.Documents)
+	assert.Len(t, res.Errors, 2)
+	assert.EqualValues(t, 1, res.Errors[0].Index)
+	assert.EqualValues(t, 2, res.Errors[1].Index)
+
+	var writeErr mongo.BulkWriteError
+	assert.True(t, errors.As(res.Errors[1], &writeErr))
+	assert.Equal(t, 11000, writeErr.Code)
+}
+
+func TestImport_TruncatedInput_Error(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{}
+
+	var input []byte
+
+	for i := 1; i <= 3; i++ {
+		raw, err := bson.Marshal(bson.D{{Key: "_id", Value: int32(i)}})
+		assert.NoError(t, err)
+		input = append(input, raw...)
+	}
+
+	res, err := Import(ctx, collection, bytes.NewReader(input[:len(input)-2]), ImportInsert, ImportBatchSize(10))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.EqualValues(t, 2, res.Documents)
+	assert.Equal(t, []int{2}, collection.sizes())
+}
+
+func TestImport_BulkWrite_Error(t *testing.T) {
+	ctx := context.Background()
+	collection := &bulkWriteCollection{err: context.Canceled}
+	input := "{\"_id\":1}\n{\"_id\":2}\n{\"_id\":3}\n"
+
+	res, err := Import(ctx, collection, bytes.NewBufferString(input), ImportInsert, ImportBatchSize(2))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.EqualValues(t, 2, res.Documents)
+	assert.Len(t, res.Errors, 2)
+	assert.Equal(t, []int{2}, collection.sizes())
+}
+
+type ExportTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Export(t *testing.T) {
+	suite.Run(t, new(ExportTestSuite))
+}
+
+func (suite *ExportTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	res, err := db.Collection("stubs").InsertMany(context.Background(), stubs)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+	}
+
+	assert.Len(suite.T(), res.InsertedIDs, len(stubs))
+
+	suite.db = db
+}
+
+func (suite *ExportTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *ExportTestSuite) TestExport_Import_Ok() {
+	ctx := context.Background()
+
+	for _, format := range []ExportFormat{ExportCanonical, ExportRelaxed, ExportBSON} {
+		buf := &bytes.Buffer{}
+		count, err := Export(ctx, suite.db.Collection("stubs"), bson.M{"field_string": "value1"}, buf, format, ExportGzip(true))
+		assert.NoError(suite.T(), err)
+		assert.EqualValues(suite.T(), 3, count)
+
+		data := buf.Bytes()
+		target := suite.db.Collection("stubs_" + string(format))
+
+		res, err := Import(ctx, target, bytes.NewReader(data), ImportInsert)
+		assert.NoError(suite.T(), err)
+		assert.EqualValues(suite.T(), 3, res.Inserted)
+
+		res, err = Import(ctx, target, bytes.NewReader(data), ImportInsert)
+		assert.ErrorIs(suite.T(), err, ErrorImportFailed)
+		assert.Len(suite.T(), res.Errors, 3)
+
+		_, err = target.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"field_float": 0, "extra": true}})
+		assert.NoError(suite.T(), err)
+
+		res, err = Import(ctx, target, bytes.NewReader(data), ImportMerge)
+		assert.NoError(suite.T(), err)
+		assert.EqualValues(suite.T(), 3, res.Modified)
+
+		count, err = target.CountDocuments(ctx, bson.M{"extra": true, "field_float": bson.M{"$gt": 0}})
+		assert.NoError(suite.T(), err)
+		assert.EqualValues(suite.T(), 3, count)
+
+		res, err = Import(ctx, target, bytes.NewReader(data), ImportUpsert)
+		assert.NoError(suite.T(), err)
+		assert.EqualValues(suite.T(), 3, res.Matched)
+
+		count, err = target.CountDocuments(ctx, bson.M{"extra": true})
+		assert.NoError(suite.T(), err)
+		assert.EqualValues(suite.T(), 0, count)
+	}
+}
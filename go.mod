@@ -3,7 +3,8 @@ module github.com/sidmal/mgo-wrapper
 go 1.16
 
 require (
-	github.com/sidmal/dsn-parser v1.0.0 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
-	go.mongodb.org/mongo-driver v1.5.2 // indirect
+	github.com/sidmal/dsn-parser v1.0.0
+	github.com/stretchr/testify v1.7.0
+	go.mongodb.org/mongo-driver v1.5.2
+	gopkg.in/yaml.v3 v3.0.1
 )
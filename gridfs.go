@@ -0,0 +1,201 @@
+package database
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"time"
+)
+
+type BucketInterface interface {
+	OpenUploadStream(filename string, opts ...*options.UploadOptions) (UploadStreamInterface, error)
+	OpenUploadStreamWithID(fileID interface{}, filename string, opts ...*options.UploadOptions) (UploadStreamInterface, error)
+	UploadFromStream(filename string, source io.Reader, opts ...*options.UploadOptions) (primitive.ObjectID, error)
+	UploadFromStreamWithID(fileID interface{}, filename string, source io.Reader, opts ...*options.UploadOptions) error
+	OpenDownloadStream(fileID interface{}) (DownloadStreamInterface, error)
+	OpenDownloadStreamByName(filename string, opts ...*options.NameOptions) (DownloadStreamInterface, error)
+	DownloadToStream(fileID interface{}, stream io.Writer) (int64, error)
+	DownloadToStreamByName(filename string, stream io.Writer, opts ...*options.NameOptions) (int64, error)
+	Delete(fileID interface{}) error
+	Find(filter interface{}, opts ...*options.GridFSFindOptions) (CursorInterface, error)
+	Rename(fileID interface{}, newFilename string) error
+	Drop() error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+type UploadStreamInterface interface {
+	io.WriteCloser
+	Abort() error
+	FileID() interface{}
+	SetWriteDeadline(t time.Time) error
+}
+
+type DownloadStreamInterface interface {
+	io.ReadCloser
+	GetFile() *gridfs.File
+	SetReadDeadline(t time.Time) error
+	Skip(skip int64) (int64, error)
+}
+
+type Bucket struct {
+	bucket *gridfs.Bucket
+}
+
+type UploadStream struct {
+	stream *gridfs.UploadStream
+}
+
+type DownloadStream struct {
+	stream *gridfs.DownloadStream
+}
+
+func (m *Bucket) OpenUploadStream(
+	filename string,
+	opts ...*options.UploadOptions,
+) (UploadStreamInterface, error) {
+	stream, err := m.bucket.OpenUploadStream(filename, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadStream{stream: stream}, nil
+}
+
+func (m *Bucket) OpenUploadStreamWithID(
+	fileID interface{},
+	filename string,
+	opts ...*options.UploadOptions,
+) (UploadStreamInterface, error) {
+	stream, err := m.bucket.OpenUploadStreamWithID(fileID, filename, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadStream{stream: stream}, nil
+}
+
+func (m *Bucket) UploadFromStream(
+	filename string,
+	source io.Reader,
+	opts ...*options.UploadOptions,
+) (primitive.ObjectID, error) {
+	return m.bucket.UploadFromStream(filename, source, opts...)
+}
+
+func (m *Bucket) UploadFromStreamWithID(
+	fileID interface{},
+	filename string,
+	source io.Reader,
+	opts ...*options.UploadOptions,
+) error {
+	return m.bucket.UploadFromStreamWithID(fileID, filename, source, opts...)
+}
+
+func (m *Bucket) OpenDownloadStream(fileID interface{}) (DownloadStreamInterface, error) {
+	stream, err := m.bucket.OpenDownloadStream(fileID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DownloadStream{stream: stream}, nil
+}
+
+func (m *Bucket) OpenDownloadStreamByName(
+	filename string,
+	opts ...*options.NameOptions,
+) (DownloadStreamInterface, error) {
+	stream, err := m.bucket.OpenDownloadStreamByName(filename, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DownloadStream{stream: stream}, nil
+}
+
+func (m *Bucket) DownloadToStream(fileID interface{}, stream io.Writer) (int64, error) {
+	return m.bucket.DownloadToStream(fileID, stream)
+}
+
+func (m *Bucket) DownloadToStreamByName(
+	filename string,
+	stream io.Writer,
+	opts ...*options.NameOptions,
+) (int64, error) {
+	return m.bucket.DownloadToStreamByName(filename, stream, opts...)
+}
+
+func (m *Bucket) Delete(fileID interface{}) error {
+	return m.bucket.Delete(fileID)
+}
+
+func (m *Bucket) Find(filter interface{}, opts ...*options.GridFSFindOptions) (CursorInterface, error) {
+	cursor, err := m.bucket.Find(filter, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cursor{cursor: cursor}, nil
+}
+
+func (m *Bucket) Rename(fileID interface{}, newFilename string) error {
+	return m.bucket.Rename(fileID, newFilename)
+}
+
+func (m *Bucket) Drop() error {
+	return m.bucket.Drop()
+}
+
+func (m *Bucket) SetReadDeadline(t time.Time) error {
+	return m.bucket.SetReadDeadline(t)
+}
+
+func (m *Bucket) SetWriteDeadline(t time.Time) error {
+	return m.bucket.SetWriteDeadline(t)
+}
+
+func (m *UploadStream) Write(p []byte) (int, error) {
+	return m.stream.Write(p)
+}
+
+func (m *UploadStream) Close() error {
+	return m.stream.Close()
+}
+
+func (m *UploadStream) Abort() error {
+	return m.stream.Abort()
+}
+
+func (m *UploadStream) FileID() interface{} {
+	return m.stream.FileID
+}
+
+func (m *UploadStream) SetWriteDeadline(t time.Time) error {
+	return m.stream.SetWriteDeadline(t)
+}
+
+func (m *DownloadStream) Read(p []byte) (int, error) {
+	return m.stream.Read(p)
+}
+
+func (m *DownloadStream) Close() error {
+	return m.stream.Close()
+}
+
+func (m *DownloadStream) GetFile() *gridfs.File {
+	return m.stream.GetFile()
+}
+
+func (m *DownloadStream) SetReadDeadline(t time.Time) error {
+	return m.stream.SetReadDeadline(t)
+}
+
+func (m *DownloadStream) Skip(skip int64) (int64, error) {
+	return m.stream.Skip(skip)
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io/ioutil"
+	"testing"
+)
+
+type GridFSTestSuite struct {
+	suite.Suite
+	db     Database
+	bucket BucketInterface
+}
+
+func Test_GridFS(t *testing.T) {
+	suite.Run(t, new(GridFSTestSuite))
+}
+
+func (suite *GridFSTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	bucket, err := db.GridFS("invoices")
+
+	if err != nil {
+		assert.FailNow(suite.T(), "bucket init failed", "%v", err)
+	}
+
+	suite.db = db
+	suite.bucket = bucket
+}
+
+func (suite *GridFSTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *GridFSTestSuite) TestGridFS_UploadFromStream_Ok() {
+	content := []byte("invoice content")
+	opts := options.GridFSUpload().SetMetadata(bson.M{"customer": "customer1"})
+
+	id, err := suite.bucket.UploadFromStream("invoice.pdf", bytes.NewReader(content), opts)
+	assert.NoError(suite.T(), err)
+
+	buf := new(bytes.Buffer)
+	n, err := suite.bucket.DownloadToStream(id, buf)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), len(content), n)
+	assert.Equal(suite.T(), content, buf.Bytes())
+
+	cursor, err := suite.bucket.Find(bson.M{"metadata.customer": "customer1"})
+	assert.NoError(suite.T(), err)
+
+	var files []bson.M
+	err = cursor.All(context.Background(), &files)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), files, 1)
+	assert.Equal(suite.T(), "invoice.pdf", files[0]["filename"])
+
+	err = suite.bucket.Rename(id, "renamed.pdf")
+	assert.NoError(suite.T(), err)
+
+	buf.Reset()
+	_, err = suite.bucket.DownloadToStreamByName("renamed.pdf", buf)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), content, buf.Bytes())
+
+	err = suite.bucket.Delete(id)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.bucket.OpenDownloadStream(id)
+	assert.Equal(suite.T(), gridfs.ErrFileNotFound, err)
+}
+
+func (suite *GridFSTestSuite) TestGridFS_OpenUploadStream_Ok() {
+	content := []byte("image content")
+
+	upload, err := suite.bucket.OpenUploadStream("image.png")
+	assert.NoError(suite.T(), err)
+
+	_, err = upload.Write(content)
+	assert.NoError(suite.T(), err)
+
+	err = upload.Close()
+	assert.NoError(suite.T(), err)
+
+	download, err := suite.bucket.OpenDownloadStream(upload.FileID())
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), len(content), download.GetFile().Length)
+
+	data, err := ioutil.ReadAll(download)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), content, data)
+
+	err = download.Close()
+	assert.NoError(suite.T(), err)
+
+	err = suite.bucket.Drop()
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.bucket.OpenDownloadStream(upload.FileID())
+	assert.Equal(suite.T(), gridfs.ErrFileNotFound, err)
+}
+
+func (suite *GridFSTestSuite) TestGridFS_DefaultName_Ok() {
+	bucket, err := suite.db.GridFS("")
+	assert.NoError(suite.T(), err)
+
+	_, err = bucket.UploadFromStream("default.txt", bytes.NewReader([]byte("content")))
+	assert.NoError(suite.T(), err)
+
+	count, err := suite.db.Collection("fs.files").CountDocuments(context.Background(), bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+}
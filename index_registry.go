@@ -0,0 +1,564 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIndexName = "_id_"
+)
+
+var (
+	ErrorIndexTag       = errors.New("index tag is invalid")
+	ErrorIndexDuplicate = errors.New("index with same name already registered")
+)
+
+// IndexedModel is implemented by models declaring indexes which can not be
+// declared by field tags, e.g. compound, partial or collated indexes. The
+// method is called on zero value of the model.
+type IndexedModel interface {
+	Indexes() []mongo.IndexModel
+}
+
+// IndexConflict is existing index with the same name or key as the declared
+// one but different key or options.
+type IndexConflict struct {
+	Declared mongo.IndexModel
+	Existing bson.D
+	Reason   string
+}
+
+// IndexReport is difference between declared and existing indexes of the
+// collection. Extra are existing indexes which are not declared, the default
+// _id index is never reported.
+type IndexReport struct {
+	Collection  string
+	Missing     []mongo.IndexModel
+	Extra       []bson.D
+	Conflicting []*IndexConflict
+}
+
+type IndexSyncOptions struct {
+	DropExtra          bool
+	ReplaceConflicting bool
+}
+
+type IndexSyncOption func(*IndexSyncOptions)
+
+// IndexSyncDropExtra drops existing indexes which are not declared.
+func IndexSyncDropExtra(val bool) IndexSyncOption {
+	return func(opts *IndexSyncOptions) {
+		opts.DropExtra = val
+	}
+}
+
+// IndexSyncReplaceConflicting drops conflicting indexes and creates them as
+// declared. Indexes are rebuilt, so it may take long on large collections.
+func IndexSyncReplaceConflicting(val bool) IndexSyncOption {
+	return func(opts *IndexSyncOptions) {
+		opts.ReplaceConflicting = val
+	}
+}
+
+// IndexRegistry keeps indexes declared by models of collections and
+// synchronizes them with indexes of the database.
+type IndexRegistry struct {
+	mx          sync.Mutex
+	collections []string
+	indexes     map[string][]mongo.IndexModel
+}
+
+func (m *IndexConflict) String() string {
+	return fmt.Sprintf("%s: %s", indexModelName(m.Declared), m.Reason)
+}
+
+// Empty reports whether declared and existing indexes are the same.
+func (m *IndexReport) Empty() bool {
+	return len(m.Missing) == 0 && len(m.Extra) == 0 && len(m.Conflicting) == 0
+}
+
+func NewIndexRegistry() *IndexRegistry {
+	return &IndexRegistry{indexes: make(map[string][]mongo.IndexModel)}
+}
+
+// Register adds indexes of the model to indexes of the collection.
+func (m *IndexRegistry) Register(collection string, model interface{}) error {
+	indexes, err := ModelIndexes(model)
+
+	if err != nil {
+		return err
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	names := make(map[string]bool)
+
+	for _, index := range m.indexes[collection] {
+		names[indexModelName(index)] = true
+	}
+
+	for _, index := range indexes {
+		name := indexModelName(index)
+
+		if names[name] {
+			return fmt.Errorf("%w: %s.%s", ErrorIndexDuplicate, collection, name)
+		}
+
+		names[name] = true
+	}
+
+	if _, ok := m.indexes[collection]; !ok {
+		m.collections = append(m.collections, collection)
+	}
+
+	m.indexes[collection] = append(m.indexes[collection], indexes...)
+	return nil
+}
+
+// Indexes returns declared indexes of the collection.
+func (m *IndexRegistry) Indexes(collection string) []mongo.IndexModel {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return append([]mongo.IndexModel{}, m.indexes[collection]...)
+}
+
+// Diff compares declared indexes with indexes of collections of the
+// database without changing them.
+func (m *IndexRegistry) Diff(ctx context.Context, db Database) ([]*IndexReport, error) {
+	m.mx.Lock()
+	collections := append([]string{}, m.collections...)
+	m.mx.Unlock()
+
+	reports := make([]*IndexReport, 0, len(collections))
+
+	for _, name := range collections {
+		existing, err := listIndexes(ctx, db.Collection(name))
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		reports = append(reports, diffIndexes(name, m.Indexes(name), existing))
+	}
+
+	return reports, nil
+}
+
+// Sync creates missing indexes of collections of the database, drops extra
+// and replaces conflicting indexes when enabled by options. Returned reports
+// describe indexes before synchronization.
+func (m *IndexRegistry) Sync(ctx context.Context, db Database, options ...IndexSyncOption) ([]*IndexReport, error) {
+	opts := &IndexSyncOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	reports, err := m.Diff(ctx, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, report := range reports {
+		err = syncIndexes(ctx, db.Collection(report.Collection), report, opts)
+
+		if err != nil {
+			return reports, fmt.Errorf("%s: %w", report.Collection, err)
+		}
+	}
+
+	return reports, nil
+}
+
+// ModelIndexes returns indexes declared by the model with `mgo:"index"` tags
+// of fields and by Indexes method of IndexedModel. Options of the tag follow
+// the index keyword, e.g. `mgo:"index,unique,desc"`:
+//
+//	unique, sparse   set the index option
+//	desc             descending order of the key
+//	text, hashed,    index type of the key
+//	2dsphere
+//	ttl=24h          documents expire after the duration since the field time
+//	name=email       name of the index instead of generated one
+func ModelIndexes(model interface{}) ([]mongo.IndexModel, error) {
+	t := reflect.TypeOf(model)
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: model must be struct", ErrorIndexTag)
+	}
+
+	var indexes []mongo.IndexModel
+	err := taggedIndexes(t, "", &indexes, make(map[reflect.Type]bool))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if declared, ok := reflect.New(t).Interface().(IndexedModel); ok {
+		indexes = append(indexes, declared.Indexes()...)
+	}
+
+	return indexes, nil
+}
+
+// taggedIndexes collects tagged indexes of the struct and its nested structs,
+// types being visited are skipped to stop on recursive models.
+func taggedIndexes(t reflect.Type, prefix string, indexes *[]mongo.IndexModel, visiting map[reflect.Type]bool) error {
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		name, inline := bsonFieldName(sf)
+
+		if name == "-" {
+			continue
+		}
+
+		tags := strings.Split(sf.Tag.Get("mgo"), ",")
+
+		if tags[0] == "index" {
+			index, err := tagIndex(prefix+name, tags[1:])
+
+			if err != nil {
+				return fmt.Errorf("%s: %w", sf.Name, err)
+			}
+
+			*indexes = append(*indexes, index)
+		}
+
+		ft := sf.Type
+
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() != reflect.Struct || ft == timeType || visiting[ft] {
+			continue
+		}
+
+		if !inline {
+			name = prefix + name + "."
+		} else {
+			name = prefix
+		}
+
+		if err := taggedIndexes(ft, name, indexes, visiting); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func tagIndex(field string, tags []string) (mongo.IndexModel, error) {
+	var direction interface{} = 1
+	opts := options.Index()
+
+	for _, tag := range tags {
+		key, val := tag, ""
+
+		if i := strings.IndexByte(tag, '='); i >= 0 {
+			key, val = tag[:i], tag[i+1:]
+		}
+
+		switch key {
+		case "unique":
+			opts.SetUnique(true)
+		case "sparse":
+			opts.SetSparse(true)
+		case "desc":
+			direction = -1
+		case "text", "hashed", "2dsphere":
+			direction = key
+		case "ttl":
+			ttl, err := time.ParseDuration(val)
+
+			if err != nil || ttl < 0 {
+				return mongo.IndexModel{}, fmt.Errorf("%w: ttl %q", ErrorIndexTag, val)
+			}
+
+			opts.SetExpireAfterSeconds(int32(ttl / time.Second))
+		case "name":
+			if val == "" {
+				return mongo.IndexModel{}, fmt.Errorf("%w: name is empty", ErrorIndexTag)
+			}
+
+			opts.SetName(val)
+		default:
+			return mongo.IndexModel{}, fmt.Errorf("%w: %s", ErrorIndexTag, tag)
+		}
+	}
+
+	return mongo.IndexModel{Keys: bson.D{{Key: field, Value: direction}}, Options: opts}, nil
+}
+
+func listIndexes(ctx context.Context, collection CollectionInterface) ([]bson.D, error) {
+	cursor, err := collection.Indexes().List(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []bson.D
+	err = cursor.All(ctx, &indexes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return indexes, nil
+}
+
+func syncIndexes(ctx context.Context, collection CollectionInterface, report *IndexReport, opts *IndexSyncOptions) error {
+	view := collection.Indexes()
+	create := append([]mongo.IndexModel{}, report.Missing...)
+
+	if opts.DropExtra {
+		for _, index := range report.Extra {
+			name, _ := lookup(index, "name")
+
+			if _, err := view.DropOne(ctx, fmt.Sprint(name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.ReplaceConflicting {
+		for _, conflict := range report.Conflicting {
+			name, _ := lookup(conflict.Existing, "name")
+
+			if _, err := view.DropOne(ctx, fmt.Sprint(name)); err != nil {
+				return err
+			}
+
+			create = append(create, conflict.Declared)
+		}
+	}
+
+	if len(create) == 0 {
+		return nil
+	}
+
+	_, err := view.CreateMany(ctx, create)
+	return err
+}
+
+func diffIndexes(collection string, declared []mongo.IndexModel, existing []bson.D) *IndexReport {
+	report := &IndexReport{Collection: collection}
+	matched := make(map[int]bool)
+
+	for _, index := range declared {
+		name := indexModelName(index)
+		keys, _ := toDocument(index.Keys)
+		found := -1
+
+		for i, spec := range existing {
+			if val, _ := lookup(spec, "name"); val == name {
+				found = i
+				break
+			}
+		}
+
+		if found < 0 {
+			for i, spec := range existing {
+				if !matched[i] && sameIndexValue(existingIndexKey(spec), keys) {
+					found = i
+					break
+				}
+			}
+		}
+
+		if found < 0 {
+			report.Missing = append(report.Missing, index)
+			continue
+		}
+
+		matched[found] = true
+
+		if reason := indexConflict(name, keys, index.Options, existing[found]); reason != "" {
+			report.Conflicting = append(report.Conflicting, &IndexConflict{
+				Declared: index,
+				Existing: existing[found],
+				Reason:   reason,
+			})
+		}
+	}
+
+	for i, spec := range existing {
+		if name, _ := lookup(spec, "name"); !matched[i] && name != defaultIndexName {
+			report.Extra = append(report.Extra, spec)
+		}
+	}
+
+	sort.Slice(report.Extra, func(i, j int) bool {
+		a, _ := lookup(report.Extra[i], "name")
+		b, _ := lookup(report.Extra[j], "name")
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	})
+
+	return report
+}
+
+// indexConflict returns description of difference between declared and
+// existing index or empty string when they are the same.
+func indexConflict(name string, keys bson.D, opts *options.IndexOptions, existing bson.D) string {
+	if opts == nil {
+		opts = options.Index()
+	}
+
+	var diffs []string
+
+	if val, _ := lookup(existing, "name"); val != name {
+		diffs = append(diffs, fmt.Sprintf("name %v, declared %s", val, name))
+	}
+
+	if key := existingIndexKey(existing); !sameIndexValue(key, keys) {
+		diffs = append(diffs, fmt.Sprintf("key %v, declared %v", key, keys))
+	}
+
+	declared := map[string]interface{}{
+		"unique":                  opts.Unique != nil && *opts.Unique,
+		"sparse":                  opts.Sparse != nil && *opts.Sparse,
+		"expireAfterSeconds":      nil,
+		"partialFilterExpression": nil,
+	}
+
+	if opts.ExpireAfterSeconds != nil {
+		declared["expireAfterSeconds"] = *opts.ExpireAfterSeconds
+	}
+
+	if opts.PartialFilterExpression != nil {
+		declared["partialFilterExpression"] = opts.PartialFilterExpression
+	}
+
+	for _, option := range []string{"unique", "sparse", "expireAfterSeconds", "partialFilterExpression"} {
+		val, ok := lookup(existing, option)
+
+		if !ok && (option == "unique" || option == "sparse") {
+			val = false
+		}
+
+		if !sameIndexValue(val, declared[option]) {
+			diffs = append(diffs, fmt.Sprintf("%s %v, declared %v", option, val, declared[option]))
+		}
+	}
+
+	return strings.Join(diffs, "; ")
+}
+
+// existingIndexKey returns key of the existing index as it was declared, text
+// indexes are stored with internal _fts and _ftsx keys and indexed fields in
+// weights.
+func existingIndexKey(spec bson.D) bson.D {
+	val, _ := lookup(spec, "key")
+	key, _ := val.(bson.D)
+
+	if _, ok := lookup(key, "_fts"); !ok {
+		return key
+	}
+
+	val, _ = lookup(spec, "weights")
+	weights, _ := val.(bson.D)
+	result := make(bson.D, 0, len(key)+len(weights))
+
+	for _, el := range key {
+		switch el.Key {
+		case "_fts":
+			for _, weight := range weights {
+				result = append(result, bson.E{Key: weight.Key, Value: "text"})
+			}
+		case "_ftsx":
+		default:
+			result = append(result, el)
+		}
+	}
+
+	return result
+}
+
+// sameIndexValue compares values of index specifications, numbers are
+// compared regardless of their types as the server may store them converted.
+func sameIndexValue(a, b interface{}) bool {
+	return reflect.DeepEqual(indexValue(a), indexValue(b))
+}
+
+func indexValue(val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+
+	doc, err := toDocument(bson.D{{Key: "v", Value: val}})
+
+	if err != nil {
+		return val
+	}
+
+	return numbersToFloat(doc[0].Value)
+}
+
+func numbersToFloat(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case bson.D:
+		doc := make(bson.D, len(v))
+
+		for i, el := range v {
+			doc[i] = bson.E{Key: el.Key, Value: numbersToFloat(el.Value)}
+		}
+
+		return doc
+	case bson.A:
+		arr := make(bson.A, len(v))
+
+		for i, el := range v {
+			arr[i] = numbersToFloat(el)
+		}
+
+		return arr
+	}
+
+	return val
+}
+
+// indexModelName returns name of the index the same way as the driver
+// generates it, e.g. "email_1_created_at_-1".
+func indexModelName(index mongo.IndexModel) string {
+	if index.Options != nil && index.Options.Name != nil {
+		return *index.Options.Name
+	}
+
+	keys, _ := toDocument(index.Keys)
+	parts := make([]string, 0, len(keys)*2)
+
+	for _, el := range keys {
+		parts = append(parts, el.Key, fmt.Sprint(el.Value))
+	}
+
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type indexedAddress struct {
+	City string `bson:"city" mgo:"index"`
+}
+
+type indexedStub struct {
+	Email     string            `bson:"email" mgo:"index,unique"`
+	Score     float64           `bson:"score" mgo:"index,desc,name=score"`
+	Bio       string            `bson:"bio" mgo:"index,text"`
+	CreatedAt time.Time         `bson:"created_at" mgo:"index,ttl=24h"`
+	Addresses []*indexedAddress `bson:"addresses"`
+	Tenant    string            `bson:"tenant"`
+}
+
+type indexedParent struct {
+	Name  string        `bson:"name" mgo:"index"`
+	Child *indexedChild `bson:"child"`
+}
+
+type indexedChild struct {
+	Code   string         `bson:"code" mgo:"index"`
+	Parent *indexedParent `bson:"parent"`
+}
+
+func (m *indexedStub) Indexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "score", Value: -1}},
+			Options: options.Index().SetPartialFilterExpression(bson.D{
+				{Key: "score", Value: bson.D{{Key: "$gt", Value: 0}}},
+			}),
+		},
+	}
+}
+
+func TestModelIndexes_Ok(t *testing.T) {
+	indexes, err := ModelIndexes(&indexedStub{})
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 6)
+
+	names := make([]string, 0, len(indexes))
+
+	for _, index := range indexes {
+		names = append(names, indexModelName(index))
+	}
+
+	assert.Equal(t, []string{"email_1", "score", "bio_text", "created_at_1", "addresses.city_1", "tenant_1_score_-1"}, names)
+	assert.True(t, *indexes[0].Options.Unique)
+	assert.Equal(t, bson.D{{Key: "score", Value: -1}}, indexes[1].Keys)
+	assert.EqualValues(t, 86400, *indexes[3].Options.ExpireAfterSeconds)
+}
+
+func TestModelIndexes_Recursive_Ok(t *testing.T) {
+	indexes, err := ModelIndexes(&indexedParent{})
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 2)
+	assert.Equal(t, "name_1", indexModelName(indexes[0]))
+	assert.Equal(t, "child.code_1", indexModelName(indexes[1]))
+}
+
+func TestModelIndexes_Error(t *testing.T) {
+	_, err := ModelIndexes(&struct {
+		Field string `mgo:"index,ttl=day"`
+	}{})
+	assert.ErrorIs(t, err, ErrorIndexTag)
+
+	_, err = ModelIndexes(&struct {
+		Field string `mgo:"index,clustered"`
+	}{})
+	assert.ErrorIs(t, err, ErrorIndexTag)
+
+	_, err = ModelIndexes("stub")
+	assert.ErrorIs(t, err, ErrorIndexTag)
+}
+
+func TestIndexRegistry_Register_Error(t *testing.T) {
+	registry := NewIndexRegistry()
+	assert.NoError(t, registry.Register("stubs", &indexedStub{}))
+
+	err := registry.Register("stubs", &struct {
+		Email string `bson:"email" mgo:"index"`
+	}{})
+	assert.ErrorIs(t, err, ErrorIndexDuplicate)
+	assert.Len(t, registry.Indexes("stubs"), 6)
+}
+
+func TestDiffIndexes_Ok(t *testing.T) {
+	declared, err := ModelIndexes(&indexedStub{})
+	assert.NoError(t, err)
+
+	existing := []bson.D{
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}},
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "score", Value: 1.0}}}, {Key: "name", Value: "score"}},
+		{
+			{Key: "v", Value: int32(2)},
+			{Key: "key", Value: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}}},
+			{Key: "name", Value: "bio_text"},
+			{Key: "weights", Value: bson.D{{Key: "bio", Value: int32(1)}}},
+		},
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "created_at", Value: int32(1)}}}, {Key: "name", Value: "created"}},
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "legacy", Value: int32(1)}}}, {Key: "name", Value: "legacy_1"}},
+	}
+
+	report := diffIndexes("stubs", declared, existing)
+	assert.False(t, report.Empty())
+	assert.Len(t, report.Missing, 2)
+	assert.Equal(t, "addresses.city_1", indexModelName(report.Missing[0]))
+	assert.Equal(t, "tenant_1_score_-1", indexModelName(report.Missing[1]))
+	assert.Equal(t, []bson.D{existing[5]}, report.Extra)
+	assert.Len(t, report.Conflicting, 3)
+	assert.Equal(t, "email_1: unique false, declared true", report.Conflicting[0].String())
+	assert.Equal(t, "score: key [{score 1}], declared [{score -1}]", report.Conflicting[1].String())
+	assert.Equal(
+		t,
+		"created_at_1: name created, declared created_at_1; expireAfterSeconds <nil>, declared 86400",
+		report.Conflicting[2].String(),
+	)
+}
+
+type IndexRegistryTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_IndexRegistry(t *testing.T) {
+	suite.Run(t, new(IndexRegistryTestSuite))
+}
+
+func (suite *IndexRegistryTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *IndexRegistryTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *IndexRegistryTestSuite) TestIndexRegistry_Sync_Ok() {
+	ctx := context.Background()
+	registry := NewIndexRegistry()
+	assert.NoError(suite.T(), registry.Register("stubs", &indexedStub{}))
+
+	_, err := suite.db.Collection("stubs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "legacy", Value: 1}}},
+	})
+	assert.NoError(suite.T(), err)
+
+	reports, err := registry.Sync(ctx, suite.db)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), reports, 1)
+	assert.Len(suite.T(), reports[0].Missing, 5)
+	assert.Len(suite.T(), reports[0].Extra, 1)
+	assert.Len(suite.T(), reports[0].Conflicting, 1)
+
+	reports, err = registry.Sync(ctx, suite.db, IndexSyncDropExtra(true), IndexSyncReplaceConflicting(true))
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), reports[0].Missing)
+	assert.Len(suite.T(), reports[0].Extra, 1)
+	assert.Len(suite.T(), reports[0].Conflicting, 1)
+
+	reports, err = registry.Diff(ctx, suite.db)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), reports[0].Empty())
+}
@@ -0,0 +1,493 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	ValidationLevelStrict   = "strict"
+	ValidationLevelModerate = "moderate"
+	ValidationLevelOff      = "off"
+	ValidationActionError   = "error"
+	ValidationActionWarn    = "warn"
+)
+
+var (
+	ErrorSchemaModel = errors.New("schema model must be struct")
+	ErrorSchemaTag   = errors.New("schema tag is invalid")
+)
+
+var (
+	schemaTypes = map[reflect.Type]string{
+		timeType:                                 "date",
+		reflect.TypeOf(primitive.DateTime(0)):    "date",
+		reflect.TypeOf(primitive.ObjectID{}):     "objectId",
+		reflect.TypeOf(primitive.Decimal128{}):   "decimal",
+		reflect.TypeOf(primitive.Binary{}):       "binData",
+		reflect.TypeOf(primitive.Timestamp{}):    "timestamp",
+		reflect.TypeOf(primitive.Regex{}):        "regex",
+		reflect.TypeOf(primitive.JavaScript("")): "javascript",
+		reflect.TypeOf(primitive.MinKey{}):       "minKey",
+		reflect.TypeOf(primitive.MaxKey{}):       "maxKey",
+		reflect.TypeOf([]byte(nil)):              "binData",
+		reflect.TypeOf(bson.D{}):                 "object",
+		reflect.TypeOf(bson.Raw{}):               "object",
+		reflect.TypeOf(bson.A{}):                 "array",
+	}
+	marshalerType      = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+	valueMarshalerType = reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()
+)
+
+type SchemaOptions struct {
+	ValidationLevel      string
+	ValidationAction     string
+	AdditionalProperties bool
+}
+
+type SchemaOption func(*SchemaOptions)
+
+// SchemaValidationLevel sets validation level of the applied schema, server
+// default is used when empty.
+func SchemaValidationLevel(level string) SchemaOption {
+	return func(opts *SchemaOptions) {
+		opts.ValidationLevel = level
+	}
+}
+
+// SchemaValidationAction sets validation action of the applied schema,
+// server default is used when empty.
+func SchemaValidationAction(action string) SchemaOption {
+	return func(opts *SchemaOptions) {
+		opts.ValidationAction = action
+	}
+}
+
+// SchemaAdditionalProperties allows fields of documents which are not
+// declared by structs.
+func SchemaAdditionalProperties(val bool) SchemaOption {
+	return func(opts *SchemaOptions) {
+		opts.AdditionalProperties = val
+	}
+}
+
+// JSONSchema generates $jsonSchema document of the model struct. Fields are
+// named by bson tags, fields with omitempty are optional, pointers, slices
+// and maps are nullable. Fields tagged with `mgo:"encrypt"` are binary. The
+// schema tag adds constraints to the field, e.g. `schema:"enum=draft|done"`:
+//
+//	enum=a|b|c       allowed values of the field
+//	min=1, max=10    bounds of numbers, lengths of strings or arrays
+//	pattern=^[a-z]+$ regular expression of strings
+//	description=text description of the field
+//	required         field is required even with omitempty
+//	optional         field is optional without omitempty
+//
+// Values of pattern and description may contain commas, so they take the rest
+// of the tag and must be the last part of it.
+func JSONSchema(model interface{}, options ...SchemaOption) (bson.D, error) {
+	opts := &SchemaOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	t := reflect.TypeOf(model)
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct || schemaTypes[t] != "" {
+		return nil, ErrorSchemaModel
+	}
+
+	g := &schemaGenerator{opts: opts, visiting: make(map[reflect.Type]bool)}
+	schema, err := g.object(t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// _id is added by the server to every document, so it must be allowed
+	// when additional properties are not
+	if val, _ := lookup(schema, "additionalProperties"); val == false {
+		val, _ = lookup(schema, "properties")
+		properties, _ := val.(bson.D)
+
+		if _, ok := lookup(properties, "_id"); !ok {
+			schema = set(schema, "properties", append(bson.D{{Key: "_id", Value: bson.D{}}}, properties...))
+		}
+	}
+
+	return schema, nil
+}
+
+// ApplyJSONSchema sets $jsonSchema validator generated from the model to the
+// collection, the collection is created when it does not exist.
+func ApplyJSONSchema(ctx context.Context, db Database, collection string, model interface{}, options ...SchemaOption) error {
+	opts := &SchemaOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	schema, err := JSONSchema(model, options...)
+
+	if err != nil {
+		return err
+	}
+
+	validator := bson.D{{Key: "$jsonSchema", Value: schema}}
+	names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: collection}})
+
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		return db.CreateCollection(
+			ctx,
+			collection,
+			CreateCollectionValidator(validator, opts.ValidationLevel, opts.ValidationAction),
+		)
+	}
+
+	return db.CollMod(ctx, collection, CollModValidator(validator, opts.ValidationLevel, opts.ValidationAction))
+}
+
+type schemaGenerator struct {
+	opts     *SchemaOptions
+	visiting map[reflect.Type]bool
+}
+
+func (m *schemaGenerator) object(t reflect.Type) (bson.D, error) {
+	schema := bson.D{{Key: "bsonType", Value: "object"}}
+
+	// recursive types are validated down to the first repetition
+	if m.visiting[t] {
+		return schema, nil
+	}
+
+	m.visiting[t] = true
+	defer delete(m.visiting, t)
+
+	properties := bson.D{}
+	var required bson.A
+	additional, err := m.fields(t, &properties, &required)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(properties) > 0 {
+		schema = append(schema, bson.E{Key: "properties", Value: properties})
+	}
+
+	if len(required) > 0 {
+		schema = append(schema, bson.E{Key: "required", Value: required})
+	}
+
+	if !m.opts.AdditionalProperties && !additional {
+		schema = append(schema, bson.E{Key: "additionalProperties", Value: false})
+	}
+
+	return schema, nil
+}
+
+// fields adds properties of struct fields, additional reports whether the
+// struct has inline map holding fields which are not declared.
+func (m *schemaGenerator) fields(t reflect.Type, properties *bson.D, required *bson.A) (additional bool, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		name, inline := bsonFieldName(sf)
+
+		if name == "-" {
+			continue
+		}
+
+		if inline {
+			ft := sf.Type
+
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() != reflect.Struct {
+				additional = true
+				continue
+			}
+
+			inlined, err := m.fields(ft, properties, required)
+
+			if err != nil {
+				return false, err
+			}
+
+			additional = additional || inlined
+			continue
+		}
+
+		var schema bson.D
+
+		if strings.Split(sf.Tag.Get("mgo"), ",")[0] == "encrypt" {
+			schema = bson.D{{Key: "bsonType", Value: "binData"}}
+		} else if schema, err = m.value(sf.Type); err != nil {
+			return false, fmt.Errorf("%s: %w", sf.Name, err)
+		}
+
+		optional := strings.Contains(sf.Tag.Get("bson"), ",omitempty")
+		schema, optional, err = schemaTag(schema, sf, optional)
+
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", sf.Name, err)
+		}
+
+		*properties = append(*properties, bson.E{Key: name, Value: schema})
+
+		if !optional {
+			*required = append(*required, name)
+		}
+	}
+
+	return additional, nil
+}
+
+func (m *schemaGenerator) value(t reflect.Type) (bson.D, error) {
+	nullable := false
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	if bsonType, ok := schemaTypes[t]; ok {
+		return schemaType(bsonType, nullable || t.Kind() == reflect.Slice), nil
+	}
+
+	// custom marshalers may encode values of any type
+	if t.Implements(marshalerType) || t.Implements(valueMarshalerType) ||
+		reflect.PtrTo(t).Implements(marshalerType) || reflect.PtrTo(t).Implements(valueMarshalerType) {
+		return bson.D{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return schemaType("string", nullable), nil
+	case reflect.Bool:
+		return schemaType("bool", nullable), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return schemaType("int", nullable), nil
+	case reflect.Int:
+		// int is encoded as int32 when it fits
+		return schemaType(bson.A{"int", "long"}, nullable), nil
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return schemaType("long", nullable), nil
+	case reflect.Float32, reflect.Float64:
+		return schemaType("double", nullable), nil
+	case reflect.Interface:
+		return bson.D{}, nil
+	case reflect.Struct:
+		schema, err := m.object(t)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if nullable {
+			schema[0].Value = bson.A{"object", "null"}
+		}
+
+		return schema, nil
+	case reflect.Slice, reflect.Array:
+		items, err := m.value(t.Elem())
+
+		if err != nil {
+			return nil, err
+		}
+
+		schema := schemaType("array", nullable || t.Kind() == reflect.Slice)
+
+		if len(items) > 0 {
+			schema = append(schema, bson.E{Key: "items", Value: items})
+		}
+
+		return schema, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: map key must be string", ErrorSchemaModel)
+		}
+
+		values, err := m.value(t.Elem())
+
+		if err != nil {
+			return nil, err
+		}
+
+		schema := schemaType("object", true)
+
+		if len(values) > 0 {
+			schema = append(schema, bson.E{Key: "additionalProperties", Value: values})
+		}
+
+		return schema, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s is not supported", ErrorSchemaModel, t)
+}
+
+func schemaType(bsonType interface{}, nullable bool) bson.D {
+	if !nullable {
+		return bson.D{{Key: "bsonType", Value: bsonType}}
+	}
+
+	types, ok := bsonType.(bson.A)
+
+	if !ok {
+		types = bson.A{bsonType}
+	}
+
+	return bson.D{{Key: "bsonType", Value: append(types, "null")}}
+}
+
+// schemaTag adds constraints of the schema tag to the field schema.
+func schemaTag(schema bson.D, sf reflect.StructField, optional bool) (bson.D, bool, error) {
+	tag, ok := sf.Tag.Lookup("schema")
+
+	if !ok {
+		return schema, optional, nil
+	}
+
+	ft := sf.Type
+
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+
+	kind := ft.Kind()
+
+	for tag != "" {
+		part := tag
+		tag = ""
+
+		// pattern and description take the rest of the tag with commas
+		if !strings.HasPrefix(part, "pattern=") && !strings.HasPrefix(part, "description=") {
+			if i := strings.IndexByte(part, ','); i >= 0 {
+				part, tag = part[:i], part[i+1:]
+			}
+		}
+
+		key, val := part, ""
+
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			key, val = part[:i], part[i+1:]
+		}
+
+		switch key {
+		case "required":
+			optional = false
+		case "optional":
+			optional = true
+		case "description":
+			schema = append(schema, bson.E{Key: "description", Value: val})
+		case "pattern":
+			schema = append(schema, bson.E{Key: "pattern", Value: val})
+		case "enum":
+			values := bson.A{}
+
+			for _, s := range strings.Split(val, "|") {
+				v, err := schemaTagValue(kind, s)
+
+				if err != nil {
+					return nil, false, err
+				}
+
+				values = append(values, v)
+			}
+
+			schema = append(schema, bson.E{Key: "enum", Value: values})
+		case "min", "max":
+			bound, err := schemaBound(kind, key, val)
+
+			if err != nil {
+				return nil, false, err
+			}
+
+			schema = append(schema, bound)
+		default:
+			return nil, false, fmt.Errorf("%w: %s", ErrorSchemaTag, part)
+		}
+	}
+
+	return schema, optional, nil
+}
+
+// schemaBound returns minimum or maximum of numbers, or bound of length of
+// strings and arrays.
+func schemaBound(kind reflect.Kind, key, val string) (bson.E, error) {
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.ParseInt(val, 10, 64)
+
+		if err != nil || n < 0 {
+			return bson.E{}, fmt.Errorf("%w: %s=%s", ErrorSchemaTag, key, val)
+		}
+
+		suffix := map[reflect.Kind]string{
+			reflect.String: "Length",
+			reflect.Slice:  "Items",
+			reflect.Array:  "Items",
+			reflect.Map:    "Properties",
+		}[kind]
+
+		return bson.E{Key: key + suffix, Value: n}, nil
+	}
+
+	v, err := schemaTagValue(kind, val)
+
+	if err != nil {
+		return bson.E{}, err
+	}
+
+	if key == "min" {
+		return bson.E{Key: "minimum", Value: v}, nil
+	}
+
+	return bson.E{Key: "maximum", Value: v}, nil
+}
+
+func schemaTagValue(kind reflect.Kind, val string) (interface{}, error) {
+	var v interface{}
+	var err error
+
+	switch kind {
+	case reflect.String:
+		return val, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err = strconv.ParseInt(val, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		v, err = strconv.ParseFloat(val, 64)
+	case reflect.Bool:
+		v, err = strconv.ParseBool(val)
+	default:
+		return nil, fmt.Errorf("%w: %s value is not supported", ErrorSchemaTag, kind)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorSchemaTag, val)
+	}
+
+	return v, nil
+}
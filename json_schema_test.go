@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+	"time"
+)
+
+type schemaBase struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+type schemaItem struct {
+	Sku string `bson:"sku" schema:"pattern=^[A-Z0-9]+$"`
+	Qty int32  `bson:"qty" schema:"min=1"`
+}
+
+type schemaStub struct {
+	schemaBase `bson:",inline"`
+	Status     string            `bson:"status" schema:"enum=draft|paid,description=order status"`
+	Total      float64           `bson:"total" schema:"min=0,max=1000"`
+	Note       *string           `bson:"note,omitempty"`
+	Items      []*schemaItem     `bson:"items" schema:"max=10"`
+	Tags       map[string]string `bson:"tags,omitempty"`
+	Card       string            `bson:"card" mgo:"encrypt"`
+	Count      int               `bson:"count" schema:"optional"`
+	Extra      interface{}       `bson:"extra,omitempty" schema:"required"`
+	Parent     *schemaStub       `bson:"parent,omitempty"`
+	Internal   string            `bson:"-"`
+}
+
+func TestJSONSchema_Ok(t *testing.T) {
+	schema, err := JSONSchema(&schemaStub{})
+	assert.NoError(t, err)
+
+	item := bson.D{
+		{Key: "bsonType", Value: bson.A{"object", "null"}},
+		{Key: "properties", Value: bson.D{
+			{Key: "sku", Value: bson.D{{Key: "bsonType", Value: "string"}, {Key: "pattern", Value: "^[A-Z0-9]+$"}}},
+			{Key: "qty", Value: bson.D{{Key: "bsonType", Value: "int"}, {Key: "minimum", Value: int64(1)}}},
+		}},
+		{Key: "required", Value: bson.A{"sku", "qty"}},
+		{Key: "additionalProperties", Value: false},
+	}
+
+	assert.Equal(t, bson.D{
+		{Key: "bsonType", Value: "object"},
+		{Key: "properties", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "bsonType", Value: "objectId"}}},
+			{Key: "created_at", Value: bson.D{{Key: "bsonType", Value: "date"}}},
+			{Key: "status", Value: bson.D{
+				{Key: "bsonType", Value: "string"},
+				{Key: "enum", Value: bson.A{"draft", "paid"}},
+				{Key: "description", Value: "order status"},
+			}},
+			{Key: "total", Value: bson.D{
+				{Key: "bsonType", Value: "double"},
+				{Key: "minimum", Value: 0.0},
+				{Key: "maximum", Value: 1000.0},
+			}},
+			{Key: "note", Value: bson.D{{Key: "bsonType", Value: bson.A{"string", "null"}}}},
+			{Key: "items", Value: bson.D{
+				{Key: "bsonType", Value: bson.A{"array", "null"}},
+				{Key: "items", Value: item},
+				{Key: "maxItems", Value: int64(10)},
+			}},
+			{Key: "tags", Value: bson.D{
+				{Key: "bsonType", Value: bson.A{"object", "null"}},
+				{Key: "additionalProperties", Value: bson.D{{Key: "bsonType", Value: "string"}}},
+			}},
+			{Key: "card", Value: bson.D{{Key: "bsonType", Value: "binData"}}},
+			{Key: "count", Value: bson.D{{Key: "bsonType", Value: bson.A{"int", "long"}}}},
+			{Key: "extra", Value: bson.D{}},
+			{Key: "parent", Value: bson.D{{Key: "bsonType", Value: bson.A{"object", "null"}}}},
+		}},
+		{Key: "required", Value: bson.A{"created_at", "status", "total", "items", "card", "extra"}},
+		{Key: "additionalProperties", Value: false},
+	}, schema)
+}
+
+func TestJSONSchema_Id_Ok(t *testing.T) {
+	schema, err := JSONSchema(&Stub{})
+	assert.NoError(t, err)
+
+	properties, _ := lookup(schema, "properties")
+	assert.Equal(t, "_id", properties.(bson.D)[0].Key)
+
+	schema, err = JSONSchema(&Stub{}, SchemaAdditionalProperties(true))
+	assert.NoError(t, err)
+
+	properties, _ = lookup(schema, "properties")
+	assert.Len(t, properties, 2)
+	assert.Equal(t, "field_string", properties.(bson.D)[0].Key)
+}
+
+func TestJSONSchema_TagCommas_Ok(t *testing.T) {
+	schema, err := JSONSchema(&struct {
+		Code string `bson:"code" schema:"required,pattern=^[a-z]{1,3}$"`
+		Note string `bson:"note,omitempty" schema:"max=20,description=a, b"`
+	}{})
+	assert.NoError(t, err)
+
+	properties, _ := lookup(schema, "properties")
+	code, _ := lookup(properties.(bson.D), "code")
+	pattern, _ := lookup(code.(bson.D), "pattern")
+	assert.Equal(t, "^[a-z]{1,3}$", pattern)
+
+	note, _ := lookup(properties.(bson.D), "note")
+	description, _ := lookup(note.(bson.D), "description")
+	assert.Equal(t, "a, b", description)
+
+	required, _ := lookup(schema, "required")
+	assert.Equal(t, bson.A{"code"}, required)
+}
+
+func TestJSONSchema_Error(t *testing.T) {
+	_, err := JSONSchema(time.Time{})
+	assert.ErrorIs(t, err, ErrorSchemaModel)
+
+	_, err = JSONSchema(&struct {
+		Values map[int]string
+	}{})
+	assert.ErrorIs(t, err, ErrorSchemaModel)
+
+	_, err = JSONSchema(&struct {
+		Count int `schema:"enum=one|two"`
+	}{})
+	assert.ErrorIs(t, err, ErrorSchemaTag)
+
+	_, err = JSONSchema(&struct {
+		Name string `schema:"unique"`
+	}{})
+	assert.ErrorIs(t, err, ErrorSchemaTag)
+}
+
+type JSONSchemaTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_JSONSchema(t *testing.T) {
+	suite.Run(t, new(JSONSchemaTestSuite))
+}
+
+func (suite *JSONSchemaTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *JSONSchemaTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *JSONSchemaTestSuite) TestApplyJSONSchema_Ok() {
+	ctx := context.Background()
+	opts := []SchemaOption{SchemaValidationLevel(ValidationLevelStrict), SchemaValidationAction(ValidationActionError)}
+
+	err := ApplyJSONSchema(ctx, suite.db, "stubs", &Stub{}, opts...)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.db.Collection("stubs").InsertMany(ctx, stubs)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.db.Collection("stubs").InsertOne(ctx, bson.M{"field_string": 1})
+	assert.Error(suite.T(), err)
+
+	err = ApplyJSONSchema(ctx, suite.db, "stubs", &schemaItem{}, opts...)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.db.Collection("stubs").InsertOne(ctx, bson.M{"sku": "SKU1", "qty": int32(1)})
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.db.Collection("stubs").InsertOne(ctx, bson.M{"sku": "sku", "qty": int32(1)})
+
+	var writeErr mongo.WriteException
+	assert.ErrorAs(suite.T(), err, &writeErr)
+}
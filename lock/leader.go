@@ -0,0 +1,71 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type LeaderCallbacks struct {
+	// OnStartedLeading is called when leadership is acquired. Passed context is
+	// cancelled as soon as leadership is lost or election is stopped.
+	OnStartedLeading func(ctx context.Context, token int64)
+	OnStoppedLeading func()
+	// OnError is called with failures of lock acquisition and release other
+	// than the lock held by another owner, the election is retried after
+	// them.
+	OnError func(err error)
+}
+
+// Elect campaigns for leadership on the named lock until ctx is done. Lost
+// leadership is campaigned for again after the retry interval.
+func (l *Locker) Elect(ctx context.Context, name string, callbacks LeaderCallbacks) error {
+	for {
+		lock, err := l.Acquire(ctx, name)
+
+		if err == nil {
+			l.lead(ctx, lock, callbacks)
+		} else if !errors.Is(err, ErrorLockHeld) && ctx.Err() == nil {
+			callbacks.error(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.opts.RetryInterval):
+		}
+	}
+}
+
+func (l *Locker) lead(ctx context.Context, lock *Lock, callbacks LeaderCallbacks) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if callbacks.OnStartedLeading != nil {
+		go callbacks.OnStartedLeading(leaderCtx, lock.Token())
+	}
+
+	select {
+	case <-ctx.Done():
+		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), l.opts.RenewInterval)
+		err := lock.Release(releaseCtx)
+		releaseCancel()
+
+		if err != nil && !errors.Is(err, ErrorLockNotOwned) {
+			callbacks.error(err)
+		}
+	case <-lock.Lost():
+	}
+
+	cancel()
+
+	if callbacks.OnStoppedLeading != nil {
+		callbacks.OnStoppedLeading()
+	}
+}
+
+func (m *LeaderCallbacks) error(err error) {
+	if m.OnError != nil {
+		m.OnError(err)
+	}
+}
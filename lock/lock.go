@@ -0,0 +1,261 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+var (
+	ErrorLockHeld     = errors.New("lock is held by another owner")
+	ErrorLockNotOwned = errors.New("lock is not owned anymore")
+)
+
+type Locker struct {
+	collection database.CollectionInterface
+	opts       *Options
+}
+
+type Lock struct {
+	locker *Locker
+	name   string
+	lease  string
+	token  int64
+
+	mx        sync.Mutex
+	expiresAt time.Time
+	lost      chan struct{}
+	lostOnce  sync.Once
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+type fence struct {
+	Token int64 `bson:"token"`
+}
+
+func New(db database.Database, options ...Option) *Locker {
+	opts := &Options{
+		Collection:    DefaultCollection,
+		Owner:         primitive.NewObjectID().Hex(),
+		TTL:           DefaultTTL,
+		RetryInterval: DefaultRetryInterval,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	if opts.RenewInterval <= 0 {
+		opts.RenewInterval = opts.TTL / 3
+	}
+
+	// ticker of renewal panics on zero interval
+	if opts.RenewInterval <= 0 {
+		opts.RenewInterval = opts.TTL
+	}
+
+	return &Locker{
+		collection: db.Collection(opts.Collection),
+		opts:       opts,
+	}
+}
+
+// EnsureIndexes creates TTL index which removes expired leases from the
+// locks collection.
+func (l *Locker) EnsureIndexes(ctx context.Context) error {
+	model := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err := l.collection.Indexes().CreateOne(ctx, model)
+	return err
+}
+
+// Acquire takes the named lock if it is free or its lease is expired and
+// starts background lease renewal. ErrorLockHeld is returned if the lock is
+// held by someone else.
+func (l *Locker) Acquire(ctx context.Context, name string) (*Lock, error) {
+	now := time.Now().UTC()
+	lease := primitive.NewObjectID().Hex()
+	expiresAt := now.Add(l.opts.TTL)
+
+	filter := bson.M{"_id": name, "expires_at": bson.M{"$lt": now}}
+	update := bson.M{
+		"$set": bson.M{
+			"owner":       l.opts.Owner,
+			"lease":       lease,
+			"acquired_at": now,
+			"expires_at":  expiresAt,
+		},
+	}
+	err := l.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetUpsert(true)).Err()
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrorLockHeld
+		}
+
+		return nil, err
+	}
+
+	lock := &Lock{
+		locker:    l,
+		name:      name,
+		lease:     lease,
+		expiresAt: expiresAt,
+		lost:      make(chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	lock.token, err = l.nextToken(ctx, name)
+
+	var res *mongo.UpdateResult
+
+	if err == nil {
+		res, err = l.collection.UpdateOne(ctx, lock.filter(), bson.M{"$set": bson.M{"token": lock.token}})
+	}
+
+	if err != nil {
+		_, _ = l.collection.DeleteOne(ctx, lock.filter())
+		return nil, err
+	}
+
+	// lease expired before the token was stored and the lock could be taken
+	// by another owner with a lower token
+	if res.MatchedCount == 0 {
+		return nil, ErrorLockHeld
+	}
+
+	go lock.renew()
+
+	return lock, nil
+}
+
+// Fence documents keep the last issued fencing token outside of the lease
+// document, so tokens are still increasing after TTL index removes a lease.
+func (l *Locker) nextToken(ctx context.Context, name string) (int64, error) {
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+	filter := bson.M{"_id": bson.D{{Key: "fence", Value: name}}}
+	update := bson.M{"$inc": bson.M{"token": int64(1)}}
+
+	var result fence
+	err := l.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Token, nil
+}
+
+func (m *Lock) Name() string {
+	return m.name
+}
+
+// Token returns fencing token of the lock. Tokens issued for the same lock
+// name are strictly increasing, so storage guarded by the lock can reject
+// writes made with a stale token.
+func (m *Lock) Token() int64 {
+	return m.token
+}
+
+// Lost returns channel which is closed when the lease could not be renewed
+// before its expiration or the lock was released.
+func (m *Lock) Lost() <-chan struct{} {
+	return m.lost
+}
+
+func (m *Lock) ExpiresAt() time.Time {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return m.expiresAt
+}
+
+func (m *Lock) Refresh(ctx context.Context) error {
+	expiresAt := time.Now().UTC().Add(m.locker.opts.TTL)
+	res, err := m.locker.collection.UpdateOne(ctx, m.filter(), bson.M{"$set": bson.M{"expires_at": expiresAt}})
+
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		m.markLost()
+		return ErrorLockNotOwned
+	}
+
+	m.mx.Lock()
+	m.expiresAt = expiresAt
+	m.mx.Unlock()
+
+	return nil
+}
+
+func (m *Lock) Release(ctx context.Context) error {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+
+	res, err := m.locker.collection.DeleteOne(ctx, m.filter())
+	m.markLost()
+
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrorLockNotOwned
+	}
+
+	return nil
+}
+
+func (m *Lock) renew() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.locker.opts.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-m.lost:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), m.locker.opts.RenewInterval)
+			err := m.Refresh(ctx)
+			cancel()
+
+			if err != nil && time.Now().After(m.ExpiresAt()) {
+				m.markLost()
+			}
+		}
+	}
+}
+
+func (m *Lock) markLost() {
+	m.lostOnce.Do(func() {
+		close(m.lost)
+	})
+}
+
+func (m *Lock) filter() bson.M {
+	return bson.M{"_id": m.name, "lease": m.lease}
+}
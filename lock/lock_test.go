@@ -0,0 +1,280 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	database "github.com/sidmal/mgo-wrapper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+var errorStub = errors.New("stub error")
+
+type failingDatabase struct {
+	database.Database
+}
+
+type failingCollection struct {
+	database.CollectionInterface
+}
+
+type failingResult struct{}
+
+func (m *failingDatabase) Collection(_ string) database.CollectionInterface {
+	return &failingCollection{}
+}
+
+func (m *failingCollection) FindOneAndUpdate(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.FindOneAndUpdateOptions,
+) database.SingleResultInterface {
+	return &failingResult{}
+}
+
+func (m *failingResult) Decode(_ interface{}) error {
+	return errorStub
+}
+
+func (m *failingResult) DecodeBytes() (bson.Raw, error) {
+	return nil, errorStub
+}
+
+func (m *failingResult) Err() error {
+	return errorStub
+}
+
+type expiringCollection struct {
+	database.CollectionInterface
+}
+
+type expiringResult struct{}
+
+func (m *expiringCollection) FindOneAndUpdate(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.FindOneAndUpdateOptions,
+) database.SingleResultInterface {
+	return &expiringResult{}
+}
+
+// UpdateOne matches nothing as the lease is taken by another owner between
+// the upsert and storing of the token.
+func (m *expiringCollection) UpdateOne(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	return &mongo.UpdateResult{}, nil
+}
+
+func (m *expiringResult) Decode(_ interface{}) error {
+	return nil
+}
+
+func (m *expiringResult) DecodeBytes() (bson.Raw, error) {
+	return nil, nil
+}
+
+func (m *expiringResult) Err() error {
+	return nil
+}
+
+func TestNew_TTL_Ok(t *testing.T) {
+	locker := New(&failingDatabase{}, TTL(0))
+	assert.Equal(t, DefaultTTL, locker.opts.TTL)
+	assert.Equal(t, DefaultTTL/3, locker.opts.RenewInterval)
+
+	locker = New(&failingDatabase{}, TTL(2))
+	assert.EqualValues(t, 2, locker.opts.RenewInterval)
+}
+
+func TestLocker_Elect_Error(t *testing.T) {
+	locker := New(&failingDatabase{}, RetryInterval(time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	var errs []error
+
+	err := locker.Elect(ctx, "leader", LeaderCallbacks{
+		OnError: func(err error) {
+			errs = append(errs, err)
+			cancel()
+		},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], errorStub)
+}
+
+func TestLocker_Acquire_ExpiredLease_Error(t *testing.T) {
+	locker := &Locker{collection: &expiringCollection{}, opts: &Options{TTL: DefaultTTL}}
+
+	lock, err := locker.Acquire(context.Background(), "job")
+	assert.Equal(t, ErrorLockHeld, err)
+	assert.Nil(t, lock)
+}
+
+type LockTestSuite struct {
+	suite.Suite
+	db database.Database
+}
+
+func Test_Lock(t *testing.T) {
+	suite.Run(t, new(LockTestSuite))
+}
+
+func (suite *LockTestSuite) SetupTest() {
+	db, err := database.New([]database.Option{database.Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *LockTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *LockTestSuite) TestLock_Acquire_Ok() {
+	ctx := context.Background()
+	locker1 := New(suite.db, Owner("owner1"))
+	locker2 := New(suite.db, Owner("owner2"))
+
+	err := locker1.EnsureIndexes(ctx)
+	assert.NoError(suite.T(), err)
+
+	lock, err := locker1.Acquire(ctx, "job")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "job", lock.Name())
+	assert.EqualValues(suite.T(), 1, lock.Token())
+
+	_, err = locker2.Acquire(ctx, "job")
+	assert.Equal(suite.T(), ErrorLockHeld, err)
+
+	_, err = locker1.Acquire(ctx, "job")
+	assert.Equal(suite.T(), ErrorLockHeld, err)
+
+	err = lock.Release(ctx)
+	assert.NoError(suite.T(), err)
+
+	select {
+	case <-lock.Lost():
+	default:
+		assert.Fail(suite.T(), "released lock must be marked as lost")
+	}
+
+	lock, err = locker2.Acquire(ctx, "job")
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 2, lock.Token())
+
+	err = lock.Release(ctx)
+	assert.NoError(suite.T(), err)
+
+	err = lock.Release(ctx)
+	assert.Equal(suite.T(), ErrorLockNotOwned, err)
+}
+
+func (suite *LockTestSuite) TestLock_ExpiredLease_Ok() {
+	ctx := context.Background()
+	locker1 := New(suite.db, Owner("owner1"), TTL(100*time.Millisecond), RenewInterval(time.Hour))
+	locker2 := New(suite.db, Owner("owner2"))
+
+	lock1, err := locker1.Acquire(ctx, "job")
+	assert.NoError(suite.T(), err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	lock2, err := locker2.Acquire(ctx, "job")
+	assert.NoError(suite.T(), err)
+	assert.Greater(suite.T(), lock2.Token(), lock1.Token())
+
+	err = lock1.Refresh(ctx)
+	assert.Equal(suite.T(), ErrorLockNotOwned, err)
+
+	select {
+	case <-lock1.Lost():
+	case <-time.After(time.Second):
+		assert.Fail(suite.T(), "expired lock must be marked as lost")
+	}
+}
+
+func (suite *LockTestSuite) TestLock_Renew_Ok() {
+	ctx := context.Background()
+	locker := New(suite.db, TTL(300*time.Millisecond), RenewInterval(50*time.Millisecond))
+
+	lock, err := locker.Acquire(ctx, "job")
+	assert.NoError(suite.T(), err)
+
+	expiresAt := lock.ExpiresAt()
+	time.Sleep(500 * time.Millisecond)
+	assert.True(suite.T(), lock.ExpiresAt().After(expiresAt))
+
+	count, err := suite.db.Collection(DefaultCollection).CountDocuments(ctx, bson.M{"_id": "job", "expires_at": bson.M{"$gt": time.Now()}})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+
+	err = lock.Release(ctx)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *LockTestSuite) TestLock_Elect_Ok() {
+	ctx, cancel := context.WithCancel(context.Background())
+	locker := New(suite.db, TTL(time.Second), RetryInterval(50*time.Millisecond))
+	started := make(chan int64, 1)
+	stopped := make(chan struct{}, 1)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- locker.Elect(ctx, "leader", LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context, token int64) {
+				started <- token
+			},
+			OnStoppedLeading: func() {
+				stopped <- struct{}{}
+			},
+		})
+	}()
+
+	select {
+	case token := <-started:
+		assert.EqualValues(suite.T(), 1, token)
+	case <-time.After(time.Second):
+		assert.FailNow(suite.T(), "leadership was not acquired")
+	}
+
+	_, err := New(suite.db).Acquire(context.Background(), "leader")
+	assert.Equal(suite.T(), ErrorLockHeld, err)
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		assert.FailNow(suite.T(), "leadership was not released")
+	}
+
+	assert.Equal(suite.T(), context.Canceled, <-done)
+
+	count, err := suite.db.Collection(DefaultCollection).CountDocuments(context.Background(), bson.M{"_id": "leader"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, count)
+}
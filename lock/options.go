@@ -0,0 +1,53 @@
+package lock
+
+import (
+	"time"
+)
+
+const (
+	DefaultCollection    = "locks"
+	DefaultTTL           = 30 * time.Second
+	DefaultRetryInterval = 5 * time.Second
+)
+
+type Options struct {
+	Collection    string
+	Owner         string
+	TTL           time.Duration
+	RenewInterval time.Duration
+	RetryInterval time.Duration
+}
+
+type Option func(*Options)
+
+func Collection(name string) Option {
+	return func(opts *Options) {
+		opts.Collection = name
+	}
+}
+
+func Owner(owner string) Option {
+	return func(opts *Options) {
+		opts.Owner = owner
+	}
+}
+
+// TTL sets lifetime of the lease, DefaultTTL is used when it is not
+// positive.
+func TTL(ttl time.Duration) Option {
+	return func(opts *Options) {
+		opts.TTL = ttl
+	}
+}
+
+func RenewInterval(interval time.Duration) Option {
+	return func(opts *Options) {
+		opts.RenewInterval = interval
+	}
+}
+
+func RetryInterval(interval time.Duration) Option {
+	return func(opts *Options) {
+		opts.RetryInterval = interval
+	}
+}
@@ -0,0 +1,394 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+)
+
+const (
+	lockId = "lock"
+)
+
+var (
+	ErrorMigrationLocked       = errors.New("migrations are locked by another runner")
+	ErrorMigrationLockLost     = errors.New("migrations lock is lost")
+	ErrorMigrationExists       = errors.New("migration with same version already registered")
+	ErrorMigrationInvalid      = errors.New("migration version must be greater than zero and up function must be set")
+	ErrorMigrationNotFound     = errors.New("applied migration not registered")
+	ErrorMigrationIrreversible = errors.New("migration has no down function")
+)
+
+type Func func(ctx context.Context, db database.Database) error
+
+type Migration struct {
+	Version     uint64
+	Description string
+	Up          Func
+	Down        Func
+}
+
+type Status struct {
+	Version     uint64
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+type record struct {
+	Version     int64     `bson:"version"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+type Migrator struct {
+	db         database.Database
+	opts       *Options
+	migrations []*Migration
+}
+
+func New(db database.Database, options ...Option) *Migrator {
+	opts := &Options{
+		Collection: DefaultCollection,
+		LockTTL:    DefaultLockTTL,
+		Owner:      primitive.NewObjectID().Hex(),
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = DefaultLockTTL
+	}
+
+	return &Migrator{db: db, opts: opts}
+}
+
+func (m *Migrator) Register(version uint64, description string, up, down Func) error {
+	if version == 0 || up == nil {
+		return ErrorMigrationInvalid
+	}
+
+	for _, v := range m.migrations {
+		if v.Version == version {
+			return fmt.Errorf("%w: %d", ErrorMigrationExists, version)
+		}
+	}
+
+	m.migrations = append(m.migrations, &Migration{
+		Version:     version,
+		Description: description,
+		Up:          up,
+		Down:        down,
+	})
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	return nil
+}
+
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// Up applies all registered migrations which are not applied yet in ascending
+// version order and returns the applied (or, in dry-run mode, pending) ones.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var result []*Migration
+
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if !m.opts.DryRun {
+				if err = migration.Up(ctx, m.db); err != nil {
+					return fmt.Errorf("migration %d up: %w", migration.Version, err)
+				}
+
+				rec := &record{
+					Version:     int64(migration.Version),
+					Description: migration.Description,
+					AppliedAt:   time.Now().UTC(),
+				}
+				_, err = m.collection().InsertOne(ctx, rec)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			result = append(result, migration)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Down rolls back the last n applied migrations in descending version order
+// and returns the rolled back (or, in dry-run mode, planned) ones.
+func (m *Migrator) Down(ctx context.Context, n int) ([]*Migration, error) {
+	var result []*Migration
+
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		versions := make([]uint64, 0, len(applied))
+
+		for version := range applied {
+			versions = append(versions, version)
+		}
+
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+
+		if n < 0 {
+			n = 0
+		}
+
+		if n < len(versions) {
+			versions = versions[:n]
+		}
+
+		for _, version := range versions {
+			migration := m.find(version)
+
+			if migration == nil {
+				return fmt.Errorf("%w: %d", ErrorMigrationNotFound, version)
+			}
+
+			if migration.Down == nil {
+				return fmt.Errorf("%w: %d", ErrorMigrationIrreversible, version)
+			}
+
+			if !m.opts.DryRun {
+				if err = migration.Down(ctx, m.db); err != nil {
+					return fmt.Errorf("migration %d down: %w", migration.Version, err)
+				}
+
+				_, err = m.collection().DeleteOne(ctx, bson.M{"version": int64(version)})
+
+				if err != nil {
+					return err
+				}
+			}
+
+			result = append(result, migration)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Status, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		status := &Status{
+			Version:     migration.Version,
+			Description: migration.Description,
+		}
+
+		if rec, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = rec.AppliedAt
+		}
+
+		result = append(result, status)
+	}
+
+	return result, nil
+}
+
+// Pending returns registered migrations which are not applied yet without
+// taking the lock or executing anything.
+func (m *Migrator) Pending(ctx context.Context) ([]*Migration, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Migration
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			result = append(result, migration)
+		}
+	}
+
+	return result, nil
+}
+
+func (m *Migrator) collection() database.CollectionInterface {
+	return m.db.Collection(m.opts.Collection)
+}
+
+func (m *Migrator) find(version uint64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[uint64]*record, error) {
+	cursor, err := m.collection().Find(ctx, bson.M{"version": bson.M{"$exists": true}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*record
+	err = cursor.All(ctx, &records)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint64]*record, len(records))
+
+	for _, rec := range records {
+		result[uint64(rec.Version)] = rec
+	}
+
+	return result, nil
+}
+
+// withLock runs fn holding the lock, the lease is renewed while fn runs. The
+// context of fn is cancelled and ErrorMigrationLockLost is returned when the
+// lease is lost.
+func (m *Migrator) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	expiresAt := time.Now().Add(m.opts.LockTTL)
+
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	lost := make(chan error, 1)
+
+	go func() {
+		lost <- m.renew(lockCtx, cancel, expiresAt)
+	}()
+
+	err := fn(lockCtx)
+	cancel()
+
+	if lostErr := <-lost; lostErr != nil {
+		return lostErr
+	}
+
+	if unlockErr := m.unlock(ctx); err == nil {
+		err = unlockErr
+	}
+
+	return err
+}
+
+// renew extends the lease until ctx is done. Lease taken by another runner or
+// expired because of failed renewals cancels ctx.
+func (m *Migrator) renew(ctx context.Context, cancel context.CancelFunc, expiresAt time.Time) error {
+	interval := m.opts.LockTTL / 3
+
+	// ticker panics on zero interval
+	if interval <= 0 {
+		interval = m.opts.LockTTL
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			next := time.Now().UTC().Add(m.opts.LockTTL)
+			filter := bson.M{"_id": lockId, "owner": m.opts.Owner}
+			res, err := m.collection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"expires_at": next}})
+
+			if err == nil && res.MatchedCount > 0 {
+				expiresAt = next
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			if err == nil || time.Now().After(expiresAt) {
+				cancel()
+				return ErrorMigrationLockLost
+			}
+		}
+	}
+}
+
+func (m *Migrator) lock(ctx context.Context) error {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": lockId,
+		"$or": []bson.M{
+			{"owner": m.opts.Owner},
+			{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"owner":      m.opts.Owner,
+			"locked_at":  now,
+			"expires_at": now.Add(m.opts.LockTTL),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true)
+	err := m.collection().FindOneAndUpdate(ctx, filter, update, opts).Err()
+
+	if err == nil || err == mongo.ErrNoDocuments {
+		return nil
+	}
+
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrorMigrationLocked
+	}
+
+	return err
+}
+
+func (m *Migrator) unlock(ctx context.Context) error {
+	_, err := m.collection().DeleteOne(ctx, bson.M{"_id": lockId, "owner": m.opts.Owner})
+	return err
+}
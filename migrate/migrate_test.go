@@ -0,0 +1,290 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	database "github.com/sidmal/mgo-wrapper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type stealingDatabase struct {
+	database.Database
+}
+
+type stealingCollection struct {
+	database.CollectionInterface
+}
+
+type stealingResult struct{}
+
+func (m *stealingDatabase) Collection(_ string) database.CollectionInterface {
+	return &stealingCollection{}
+}
+
+func (m *stealingCollection) FindOneAndUpdate(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.FindOneAndUpdateOptions,
+) database.SingleResultInterface {
+	return &stealingResult{}
+}
+
+func (m *stealingCollection) UpdateOne(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	return &mongo.UpdateResult{}, nil
+}
+
+func (m *stealingCollection) DeleteOne(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	return &mongo.DeleteResult{}, nil
+}
+
+func (m *stealingResult) Decode(_ interface{}) error {
+	return nil
+}
+
+func (m *stealingResult) DecodeBytes() (bson.Raw, error) {
+	return nil, nil
+}
+
+func (m *stealingResult) Err() error {
+	return nil
+}
+
+func TestNew_LockTTL_Ok(t *testing.T) {
+	m := New(nil, LockTTL(0))
+	assert.Equal(t, DefaultLockTTL, m.opts.LockTTL)
+
+	m = New(nil, LockTTL(-time.Second))
+	assert.Equal(t, DefaultLockTTL, m.opts.LockTTL)
+}
+
+func TestMigrator_LockLost_Error(t *testing.T) {
+	m := New(&stealingDatabase{}, LockTTL(30*time.Millisecond))
+	err := m.withLock(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, ErrorMigrationLockLost)
+}
+
+type MigrateTestSuite struct {
+	suite.Suite
+	db database.Database
+}
+
+func Test_Migrate(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
+
+func (suite *MigrateTestSuite) SetupTest() {
+	db, err := database.New([]database.Option{database.Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *MigrateTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *MigrateTestSuite) newMigrator(opts ...Option) *Migrator {
+	m := New(suite.db, opts...)
+	err := m.Register(1, "insert stub", func(ctx context.Context, db database.Database) error {
+		_, err := db.Collection("stubs").InsertOne(ctx, bson.M{"_id": 1})
+		return err
+	}, func(ctx context.Context, db database.Database) error {
+		_, err := db.Collection("stubs").DeleteOne(ctx, bson.M{"_id": 1})
+		return err
+	})
+	assert.NoError(suite.T(), err)
+	err = m.Register(2, "rename field", func(ctx context.Context, db database.Database) error {
+		_, err := db.Collection("stubs").UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"migrated": true}})
+		return err
+	}, func(ctx context.Context, db database.Database) error {
+		_, err := db.Collection("stubs").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"migrated": ""}})
+		return err
+	})
+	assert.NoError(suite.T(), err)
+
+	return m
+}
+
+func (suite *MigrateTestSuite) TestMigrate_Up_Ok() {
+	ctx := context.Background()
+	m := suite.newMigrator()
+
+	applied, err := m.Up(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), applied, 2)
+
+	count, err := suite.db.Collection("stubs").CountDocuments(ctx, bson.M{"migrated": true})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, count)
+
+	applied, err = m.Up(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), applied)
+
+	status, err := m.Status(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), status, 2)
+
+	for _, v := range status {
+		assert.True(suite.T(), v.Applied)
+		assert.False(suite.T(), v.AppliedAt.IsZero())
+	}
+}
+
+func (suite *MigrateTestSuite) TestMigrate_Down_Ok() {
+	ctx := context.Background()
+	m := suite.newMigrator()
+
+	_, err := m.Up(ctx)
+	assert.NoError(suite.T(), err)
+
+	reverted, err := m.Down(ctx, 1)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), reverted, 1)
+	assert.EqualValues(suite.T(), 2, reverted[0].Version)
+
+	pending, err := m.Pending(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), pending, 1)
+	assert.EqualValues(suite.T(), 2, pending[0].Version)
+
+	count, err := suite.db.Collection("stubs").CountDocuments(ctx, bson.M{"migrated": true})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, count)
+}
+
+func (suite *MigrateTestSuite) TestMigrate_DryRun_Ok() {
+	ctx := context.Background()
+	m := suite.newMigrator(DryRun(true))
+
+	planned, err := m.Up(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), planned, 2)
+
+	count, err := suite.db.Collection("stubs").CountDocuments(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, count)
+
+	pending, err := m.Pending(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), pending, 2)
+}
+
+func (suite *MigrateTestSuite) TestMigrate_Locked_Error() {
+	ctx := context.Background()
+	m1 := suite.newMigrator(Owner("runner1"))
+	m2 := suite.newMigrator(Owner("runner2"))
+
+	err := m1.lock(ctx)
+	assert.NoError(suite.T(), err)
+
+	_, err = m2.Up(ctx)
+	assert.Equal(suite.T(), ErrorMigrationLocked, err)
+
+	err = m1.unlock(ctx)
+	assert.NoError(suite.T(), err)
+
+	applied, err := m2.Up(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), applied, 2)
+}
+
+func (suite *MigrateTestSuite) TestMigrate_ExpiredLock_Ok() {
+	ctx := context.Background()
+	m1 := suite.newMigrator(Owner("runner1"))
+	m2 := suite.newMigrator(Owner("runner2"))
+
+	err := m1.lock(ctx)
+	assert.NoError(suite.T(), err)
+
+	_, err = m1.collection().UpdateOne(ctx, bson.M{"_id": lockId}, bson.M{"$set": bson.M{"expires_at": time.Now().Add(-time.Second)}})
+	assert.NoError(suite.T(), err)
+
+	_, err = m2.Up(ctx)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *MigrateTestSuite) TestMigrate_Renew_Ok() {
+	ctx := context.Background()
+	m1 := New(suite.db, Owner("runner1"), LockTTL(300*time.Millisecond))
+	m2 := New(suite.db, Owner("runner2"))
+	var lockErr error
+
+	err := m1.Register(1, "slow", func(ctx context.Context, db database.Database) error {
+		time.Sleep(600 * time.Millisecond)
+		lockErr = m2.lock(ctx)
+		return nil
+	}, nil)
+	assert.NoError(suite.T(), err)
+
+	applied, err := m1.Up(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), applied, 1)
+	assert.Equal(suite.T(), ErrorMigrationLocked, lockErr)
+}
+
+func (suite *MigrateTestSuite) TestMigrate_UpFailed_Error() {
+	ctx := context.Background()
+	m := New(suite.db)
+	err := m.Register(1, "broken", func(ctx context.Context, db database.Database) error {
+		return mongo.ErrNilDocument
+	}, nil)
+	assert.NoError(suite.T(), err)
+
+	_, err = m.Up(ctx)
+	assert.True(suite.T(), errors.Is(err, mongo.ErrNilDocument))
+
+	status, err := m.Status(ctx)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), status[0].Applied)
+}
+
+func TestRegister_Error(t *testing.T) {
+	up := func(ctx context.Context, db database.Database) error {
+		return nil
+	}
+	m := New(nil)
+
+	assert.Equal(t, ErrorMigrationInvalid, m.Register(0, "zero version", up, nil))
+	assert.Equal(t, ErrorMigrationInvalid, m.Register(1, "without up", nil, nil))
+	assert.NoError(t, m.Register(2, "second", up, nil))
+	assert.NoError(t, m.Register(1, "first", up, nil))
+	assert.True(t, errors.Is(m.Register(2, "duplicate", up, nil), ErrorMigrationExists))
+
+	migrations := m.Migrations()
+	assert.Len(t, migrations, 2)
+	assert.EqualValues(t, 1, migrations[0].Version)
+	assert.EqualValues(t, 2, migrations[1].Version)
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"time"
+)
+
+const (
+	DefaultCollection = "schema_migrations"
+	DefaultLockTTL    = 10 * time.Minute
+)
+
+type Options struct {
+	Collection string
+	LockTTL    time.Duration
+	Owner      string
+	DryRun     bool
+}
+
+type Option func(*Options)
+
+func Collection(name string) Option {
+	return func(opts *Options) {
+		opts.Collection = name
+	}
+}
+
+// LockTTL sets lifetime of the migrations lock lease, the lease is renewed
+// while migrations run. DefaultLockTTL is used when it is not positive.
+func LockTTL(ttl time.Duration) Option {
+	return func(opts *Options) {
+		opts.LockTTL = ttl
+	}
+}
+
+func Owner(owner string) Option {
+	return func(opts *Options) {
+		opts.Owner = owner
+	}
+}
+
+func DryRun(val bool) Option {
+	return func(opts *Options) {
+		opts.DryRun = val
+	}
+}
@@ -10,10 +10,16 @@ type Options struct {
 	Mode     string
 	ModeOpts []readpref.Option
 	Context  context.Context
+	Wrappers map[string][]CollectionWrapper
 }
 
 type Option func(*Options)
 
+// CollectionWrapper decorates collection of the database. Wrappers are called
+// once per collection under the database lock, so they must not call
+// Database.Collection themselves.
+type CollectionWrapper func(db Database, collection CollectionInterface) CollectionInterface
+
 func Dsn(dsn string) Option {
 	return func(opts *Options) {
 		opts.Dsn = dsn
@@ -37,3 +43,13 @@ func Context(ctx context.Context) Option {
 		opts.Context = ctx
 	}
 }
+
+func WrapCollection(name string, wrappers ...CollectionWrapper) Option {
+	return func(opts *Options) {
+		if opts.Wrappers == nil {
+			opts.Wrappers = make(map[string][]CollectionWrapper)
+		}
+
+		opts.Wrappers[name] = append(opts.Wrappers[name], wrappers...)
+	}
+}
@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Accumulator is output field of $group or $bucket stage computed by the
+// accumulator operator.
+type Accumulator struct {
+	Name       string
+	Operator   string
+	Expression interface{}
+}
+
+// Projection is field of $project stage, included, excluded or computed by
+// the expression.
+type Projection struct {
+	Name       string
+	Expression interface{}
+}
+
+// Assignment is field of $addFields stage set to value of the expression.
+type Assignment struct {
+	Name       string
+	Expression interface{}
+}
+
+// SortField is field of $sort stage with direction, 1 for ascending and -1
+// for descending.
+type SortField struct {
+	Name      string
+	Direction int
+}
+
+func (m *Accumulator) element() bson.E {
+	return bson.E{Key: m.Name, Value: bson.D{{Key: m.Operator, Value: m.Expression}}}
+}
+
+// Field returns field path expression of the field, e.g. Field("user.name")
+// is "$user.name".
+func Field(path string) string {
+	return "$" + path
+}
+
+// Variable returns expression of the variable, e.g. Variable("ROOT") is
+// "$$ROOT".
+func Variable(name string) string {
+	return "$$" + name
+}
+
+// Literal returns value without parsing it as expression, e.g. strings
+// starting with $.
+func Literal(val interface{}) bson.D {
+	return operator("$literal", val)
+}
+
+func Eq(a, b interface{}) bson.D {
+	return operator("$eq", bson.A{a, b})
+}
+
+func Ne(a, b interface{}) bson.D {
+	return operator("$ne", bson.A{a, b})
+}
+
+func Gt(a, b interface{}) bson.D {
+	return operator("$gt", bson.A{a, b})
+}
+
+func Gte(a, b interface{}) bson.D {
+	return operator("$gte", bson.A{a, b})
+}
+
+func Lt(a, b interface{}) bson.D {
+	return operator("$lt", bson.A{a, b})
+}
+
+func Lte(a, b interface{}) bson.D {
+	return operator("$lte", bson.A{a, b})
+}
+
+func In(val, array interface{}) bson.D {
+	return operator("$in", bson.A{val, array})
+}
+
+func And(expressions ...interface{}) bson.D {
+	return operator("$and", bson.A(expressions))
+}
+
+func Or(expressions ...interface{}) bson.D {
+	return operator("$or", bson.A(expressions))
+}
+
+func Not(expression interface{}) bson.D {
+	return operator("$not", bson.A{expression})
+}
+
+func Add(expressions ...interface{}) bson.D {
+	return operator("$add", bson.A(expressions))
+}
+
+func Subtract(a, b interface{}) bson.D {
+	return operator("$subtract", bson.A{a, b})
+}
+
+func Multiply(expressions ...interface{}) bson.D {
+	return operator("$multiply", bson.A(expressions))
+}
+
+func Divide(a, b interface{}) bson.D {
+	return operator("$divide", bson.A{a, b})
+}
+
+func Concat(expressions ...interface{}) bson.D {
+	return operator("$concat", bson.A(expressions))
+}
+
+func Size(array interface{}) bson.D {
+	return operator("$size", array)
+}
+
+func Cond(condition, then, otherwise interface{}) bson.D {
+	return operator("$cond", bson.D{{Key: "if", Value: condition}, {Key: "then", Value: then}, {Key: "else", Value: otherwise}})
+}
+
+func IfNull(expression, replacement interface{}) bson.D {
+	return operator("$ifNull", bson.A{expression, replacement})
+}
+
+// Expr returns $match filter of the aggregation expression.
+func Expr(expression interface{}) bson.D {
+	return operator("$expr", expression)
+}
+
+func Sum(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$sum", Expression: expression}
+}
+
+// Count counts documents of the group.
+func Count(name string) Accumulator {
+	return Sum(name, 1)
+}
+
+func Avg(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$avg", Expression: expression}
+}
+
+func Min(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$min", Expression: expression}
+}
+
+func Max(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$max", Expression: expression}
+}
+
+func First(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$first", Expression: expression}
+}
+
+func Last(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$last", Expression: expression}
+}
+
+func Push(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$push", Expression: expression}
+}
+
+func AddToSet(name string, expression interface{}) Accumulator {
+	return Accumulator{Name: name, Operator: "$addToSet", Expression: expression}
+}
+
+func Include(name string) Projection {
+	return Projection{Name: name, Expression: 1}
+}
+
+func Exclude(name string) Projection {
+	return Projection{Name: name, Expression: 0}
+}
+
+// Compute returns projection of the field computed by the expression.
+func Compute(name string, expression interface{}) Projection {
+	return Projection{Name: name, Expression: expression}
+}
+
+func Set(name string, expression interface{}) Assignment {
+	return Assignment{Name: name, Expression: expression}
+}
+
+func Asc(name string) SortField {
+	return SortField{Name: name, Direction: 1}
+}
+
+func Desc(name string) SortField {
+	return SortField{Name: name, Direction: -1}
+}
+
+func operator(name string, val interface{}) bson.D {
+	return bson.D{{Key: name, Value: val}}
+}
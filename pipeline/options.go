@@ -0,0 +1,78 @@
+package pipeline
+
+const (
+	WhenMatchedReplace      = "replace"
+	WhenMatchedKeepExisting = "keepExisting"
+	WhenMatchedMerge        = "merge"
+	WhenMatchedFail         = "fail"
+	WhenNotMatchedInsert    = "insert"
+	WhenNotMatchedDiscard   = "discard"
+	WhenNotMatchedFail      = "fail"
+)
+
+type UnwindOptions struct {
+	IncludeArrayIndex          string
+	PreserveNullAndEmptyArrays bool
+}
+
+type UnwindOption func(*UnwindOptions)
+
+// UnwindIncludeArrayIndex sets field to store array index of the element.
+func UnwindIncludeArrayIndex(field string) UnwindOption {
+	return func(opts *UnwindOptions) {
+		opts.IncludeArrayIndex = field
+	}
+}
+
+// UnwindPreserve keeps documents with missing, null or empty array field.
+func UnwindPreserve(val bool) UnwindOption {
+	return func(opts *UnwindOptions) {
+		opts.PreserveNullAndEmptyArrays = val
+	}
+}
+
+type MergeOptions struct {
+	Database       string
+	On             []string
+	Let            interface{}
+	WhenMatched    interface{}
+	WhenNotMatched string
+}
+
+type MergeOption func(*MergeOptions)
+
+// MergeDatabase sets database of the target collection, defaults to database
+// of the aggregated collection.
+func MergeDatabase(name string) MergeOption {
+	return func(opts *MergeOptions) {
+		opts.Database = name
+	}
+}
+
+// MergeOn sets fields identifying documents, the target collection must have
+// unique index on them. Defaults to _id.
+func MergeOn(fields ...string) MergeOption {
+	return func(opts *MergeOptions) {
+		opts.On = fields
+	}
+}
+
+func MergeLet(let interface{}) MergeOption {
+	return func(opts *MergeOptions) {
+		opts.Let = let
+	}
+}
+
+// MergeWhenMatched sets action for matched documents, one of WhenMatched
+// constants or pipeline updating the matched document.
+func MergeWhenMatched(action interface{}) MergeOption {
+	return func(opts *MergeOptions) {
+		opts.WhenMatched = action
+	}
+}
+
+func MergeWhenNotMatched(action string) MergeOption {
+	return func(opts *MergeOptions) {
+		opts.WhenNotMatched = action
+	}
+}
@@ -0,0 +1,439 @@
+// Package pipeline builds aggregation pipelines from typed stages and
+// validates their order before execution.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	database "github.com/sidmal/mgo-wrapper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrorStageInvalid = errors.New("aggregation stage is invalid")
+	ErrorStageOrder   = errors.New("aggregation stage is not allowed at this position")
+)
+
+var (
+	// stages which must be the last stage of the pipeline
+	lastStages = map[string]bool{"$out": true, "$merge": true}
+	// stages which must be the first stage of the pipeline
+	firstStages = map[string]bool{
+		"$geoNear":      true,
+		"$collStats":    true,
+		"$indexStats":   true,
+		"$changeStream": true,
+		"$documents":    true,
+	}
+	// stages which are not allowed in sub-pipelines of $facet
+	facetStages = map[string]bool{
+		"$out":          true,
+		"$merge":        true,
+		"$facet":        true,
+		"$geoNear":      true,
+		"$collStats":    true,
+		"$indexStats":   true,
+		"$changeStream": true,
+	}
+	// stages which are not allowed in sub-pipelines of $lookup
+	lookupStages = map[string]bool{"$out": true, "$merge": true}
+	// stages which are allowed in whenMatched pipeline of $merge
+	mergeStages = map[string]bool{
+		"$addFields":   true,
+		"$set":         true,
+		"$project":     true,
+		"$unset":       true,
+		"$replaceRoot": true,
+		"$replaceWith": true,
+	}
+)
+
+// Builder builds pipeline stage by stage, the first invalid stage is
+// reported by Build.
+type Builder struct {
+	stages []bson.D
+	err    error
+}
+
+func New() *Builder {
+	return &Builder{}
+}
+
+// Stage appends stage not covered by the builder, e.g. $sample or $geoNear.
+// The stage must be single element document and is validated by its name.
+func (m *Builder) Stage(stage bson.D) *Builder {
+	if len(stage) != 1 || !strings.HasPrefix(stage[0].Key, "$") {
+		return m.fail(fmt.Errorf("%w: stage must be single operator document", ErrorStageInvalid))
+	}
+
+	m.stages = append(m.stages, stage)
+	return m
+}
+
+// Match appends $match stage of the query filter, use Expr for aggregation
+// expressions.
+func (m *Builder) Match(filter interface{}) *Builder {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	return m.append("$match", filter)
+}
+
+// Group appends $group stage with the group key expression, nil key groups
+// all documents.
+func (m *Builder) Group(id interface{}, accumulators ...Accumulator) *Builder {
+	group := bson.D{{Key: "_id", Value: id}}
+
+	for i := range accumulators {
+		if err := checkField("$group", accumulators[i].Name); err != nil {
+			return m.fail(err)
+		}
+
+		if accumulators[i].Name == "_id" {
+			return m.fail(fmt.Errorf("%w: $group output field can not be _id", ErrorStageInvalid))
+		}
+
+		group = append(group, accumulators[i].element())
+	}
+
+	return m.append("$group", group)
+}
+
+func (m *Builder) Project(fields ...Projection) *Builder {
+	if len(fields) == 0 {
+		return m.fail(fmt.Errorf("%w: $project requires fields", ErrorStageInvalid))
+	}
+
+	project := make(bson.D, 0, len(fields))
+
+	for _, field := range fields {
+		if field.Name == "" {
+			return m.fail(fmt.Errorf("%w: $project field name is empty", ErrorStageInvalid))
+		}
+
+		project = append(project, bson.E{Key: field.Name, Value: field.Expression})
+	}
+
+	return m.append("$project", project)
+}
+
+func (m *Builder) AddFields(fields ...Assignment) *Builder {
+	if len(fields) == 0 {
+		return m.fail(fmt.Errorf("%w: $addFields requires fields", ErrorStageInvalid))
+	}
+
+	addFields := make(bson.D, 0, len(fields))
+
+	for _, field := range fields {
+		if field.Name == "" || strings.HasPrefix(field.Name, "$") {
+			return m.fail(fmt.Errorf("%w: $addFields field name %q", ErrorStageInvalid, field.Name))
+		}
+
+		addFields = append(addFields, bson.E{Key: field.Name, Value: field.Expression})
+	}
+
+	return m.append("$addFields", addFields)
+}
+
+func (m *Builder) Sort(fields ...SortField) *Builder {
+	if len(fields) == 0 {
+		return m.fail(fmt.Errorf("%w: $sort requires fields", ErrorStageInvalid))
+	}
+
+	order := make(bson.D, 0, len(fields))
+
+	for _, field := range fields {
+		if field.Name == "" || (field.Direction != 1 && field.Direction != -1) {
+			return m.fail(fmt.Errorf("%w: $sort field %q direction %d", ErrorStageInvalid, field.Name, field.Direction))
+		}
+
+		order = append(order, bson.E{Key: field.Name, Value: field.Direction})
+	}
+
+	return m.append("$sort", order)
+}
+
+func (m *Builder) Limit(n int64) *Builder {
+	if n <= 0 {
+		return m.fail(fmt.Errorf("%w: $limit must be positive", ErrorStageInvalid))
+	}
+
+	return m.append("$limit", n)
+}
+
+func (m *Builder) Skip(n int64) *Builder {
+	if n < 0 {
+		return m.fail(fmt.Errorf("%w: $skip must not be negative", ErrorStageInvalid))
+	}
+
+	return m.append("$skip", n)
+}
+
+// Unwind appends $unwind stage of the array field, given without $ prefix.
+func (m *Builder) Unwind(path string, options ...UnwindOption) *Builder {
+	if path == "" {
+		return m.fail(fmt.Errorf("%w: $unwind path is empty", ErrorStageInvalid))
+	}
+
+	opts := &UnwindOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	unwind := bson.D{{Key: "path", Value: Field(path)}}
+
+	if opts.IncludeArrayIndex != "" {
+		unwind = append(unwind, bson.E{Key: "includeArrayIndex", Value: opts.IncludeArrayIndex})
+	}
+
+	if opts.PreserveNullAndEmptyArrays {
+		unwind = append(unwind, bson.E{Key: "preserveNullAndEmptyArrays", Value: true})
+	}
+
+	return m.append("$unwind", unwind)
+}
+
+// Lookup appends $lookup stage joining documents of the collection by
+// equality of the local and foreign fields.
+func (m *Builder) Lookup(from, localField, foreignField, as string) *Builder {
+	if from == "" || localField == "" || foreignField == "" || as == "" {
+		return m.fail(fmt.Errorf("%w: $lookup requires from, localField, foreignField and as", ErrorStageInvalid))
+	}
+
+	return m.append("$lookup", bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	})
+}
+
+// LookupPipeline appends $lookup stage joining documents of the collection
+// matched by the sub-pipeline with variables of the let document.
+func (m *Builder) LookupPipeline(from string, let interface{}, pipeline *Builder, as string) *Builder {
+	if from == "" || as == "" || pipeline == nil {
+		return m.fail(fmt.Errorf("%w: $lookup requires from, pipeline and as", ErrorStageInvalid))
+	}
+
+	stages, err := pipeline.build(lookupStages, "$lookup")
+
+	if err != nil {
+		return m.fail(err)
+	}
+
+	lookup := bson.D{{Key: "from", Value: from}}
+
+	if let != nil {
+		lookup = append(lookup, bson.E{Key: "let", Value: let})
+	}
+
+	return m.append("$lookup", append(lookup, bson.E{Key: "pipeline", Value: stages}, bson.E{Key: "as", Value: as}))
+}
+
+// Facet appends $facet stage with sub-pipelines by output field names.
+func (m *Builder) Facet(facets map[string]*Builder) *Builder {
+	if len(facets) == 0 {
+		return m.fail(fmt.Errorf("%w: $facet requires sub-pipelines", ErrorStageInvalid))
+	}
+
+	names := make([]string, 0, len(facets))
+
+	for name := range facets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	facet := make(bson.D, 0, len(facets))
+
+	for _, name := range names {
+		if err := checkField("$facet", name); err != nil {
+			return m.fail(err)
+		}
+
+		if facets[name] == nil {
+			return m.fail(fmt.Errorf("%w: $facet %s sub-pipeline is nil", ErrorStageInvalid, name))
+		}
+
+		stages, err := facets[name].build(facetStages, "$facet")
+
+		if err != nil {
+			return m.fail(fmt.Errorf("%s: %w", name, err))
+		}
+
+		facet = append(facet, bson.E{Key: name, Value: stages})
+	}
+
+	return m.append("$facet", facet)
+}
+
+// Bucket appends $bucket stage grouping documents by the expression into
+// buckets of the sorted boundaries. Documents out of boundaries are grouped
+// into the default bucket, nil default makes such documents an error.
+func (m *Builder) Bucket(
+	groupBy interface{},
+	boundaries []interface{},
+	defaultBucket interface{},
+	accumulators ...Accumulator,
+) *Builder {
+	if groupBy == nil || len(boundaries) < 2 {
+		return m.fail(fmt.Errorf("%w: $bucket requires groupBy and at least two boundaries", ErrorStageInvalid))
+	}
+
+	bucket := bson.D{{Key: "groupBy", Value: groupBy}, {Key: "boundaries", Value: boundaries}}
+
+	if defaultBucket != nil {
+		bucket = append(bucket, bson.E{Key: "default", Value: defaultBucket})
+	}
+
+	if len(accumulators) > 0 {
+		output := make(bson.D, 0, len(accumulators))
+
+		for i := range accumulators {
+			if err := checkField("$bucket", accumulators[i].Name); err != nil {
+				return m.fail(err)
+			}
+
+			output = append(output, accumulators[i].element())
+		}
+
+		bucket = append(bucket, bson.E{Key: "output", Value: output})
+	}
+
+	return m.append("$bucket", bucket)
+}
+
+// Merge appends $merge stage writing results to the collection, it must be
+// the last stage.
+func (m *Builder) Merge(into string, options ...MergeOption) *Builder {
+	if into == "" {
+		return m.fail(fmt.Errorf("%w: $merge collection is empty", ErrorStageInvalid))
+	}
+
+	opts := &MergeOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	var target interface{} = into
+
+	if opts.Database != "" {
+		target = bson.D{{Key: "db", Value: opts.Database}, {Key: "coll", Value: into}}
+	}
+
+	merge := bson.D{{Key: "into", Value: target}}
+
+	if len(opts.On) == 1 {
+		merge = append(merge, bson.E{Key: "on", Value: opts.On[0]})
+	} else if len(opts.On) > 1 {
+		merge = append(merge, bson.E{Key: "on", Value: opts.On})
+	}
+
+	if opts.Let != nil {
+		merge = append(merge, bson.E{Key: "let", Value: opts.Let})
+	}
+
+	if opts.WhenMatched != nil {
+		if builder, ok := opts.WhenMatched.(*Builder); ok {
+			stages, err := builder.build(nil, "$merge")
+
+			if err != nil {
+				return m.fail(err)
+			}
+
+			opts.WhenMatched = stages
+		}
+
+		merge = append(merge, bson.E{Key: "whenMatched", Value: opts.WhenMatched})
+	}
+
+	if opts.WhenNotMatched != "" {
+		merge = append(merge, bson.E{Key: "whenNotMatched", Value: opts.WhenNotMatched})
+	}
+
+	return m.append("$merge", merge)
+}
+
+// Out appends $out stage replacing the collection with results, it must be
+// the last stage.
+func (m *Builder) Out(collection string) *Builder {
+	if collection == "" {
+		return m.fail(fmt.Errorf("%w: $out collection is empty", ErrorStageInvalid))
+	}
+
+	return m.append("$out", collection)
+}
+
+// Build validates order of stages and returns the pipeline.
+func (m *Builder) Build() (mongo.Pipeline, error) {
+	return m.build(nil, "")
+}
+
+// Aggregate builds the pipeline and runs it on the collection.
+func (m *Builder) Aggregate(
+	ctx context.Context,
+	collection database.CollectionInterface,
+	opts ...*options.AggregateOptions,
+) (database.CursorInterface, error) {
+	stages, err := m.Build()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collection.Aggregate(ctx, stages, opts...)
+}
+
+// build validates stages of the pipeline, forbidden are stages not allowed
+// in the sub-pipeline of the parent stage.
+func (m *Builder) build(forbidden map[string]bool, parent string) (mongo.Pipeline, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	for i, stage := range m.stages {
+		name := stage[0].Key
+
+		if forbidden[name] || (parent == "$merge" && !mergeStages[name]) {
+			return nil, fmt.Errorf("%w: %s in %s", ErrorStageOrder, name, parent)
+		}
+
+		if lastStages[name] && (parent != "" || i != len(m.stages)-1) {
+			return nil, fmt.Errorf("%w: %s must be the last stage", ErrorStageOrder, name)
+		}
+
+		if firstStages[name] && i != 0 {
+			return nil, fmt.Errorf("%w: %s must be the first stage", ErrorStageOrder, name)
+		}
+	}
+
+	return append(mongo.Pipeline{}, m.stages...), nil
+}
+
+func (m *Builder) append(name string, val interface{}) *Builder {
+	m.stages = append(m.stages, bson.D{{Key: name, Value: val}})
+	return m
+}
+
+func (m *Builder) fail(err error) *Builder {
+	if m.err == nil {
+		m.err = fmt.Errorf("stage %d: %w", len(m.stages), err)
+	}
+
+	return m
+}
+
+func checkField(stage, name string) error {
+	if name == "" || strings.ContainsAny(name, ".$") {
+		return fmt.Errorf("%w: %s output field %q", ErrorStageInvalid, stage, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"context"
+	database "github.com/sidmal/mgo-wrapper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestBuilder_Build_Ok(t *testing.T) {
+	pipeline, err := New().
+		Match(bson.M{"status": "paid"}).
+		Unwind("items", UnwindPreserve(true)).
+		Group(Field("items.sku"), Sum("total", Multiply(Field("items.price"), Field("items.qty"))), Count("orders")).
+		Sort(Desc("total"), Asc("_id")).
+		Skip(10).
+		Limit(5).
+		Merge("report", MergeOn("_id"), MergeWhenMatched(WhenMatchedReplace)).
+		Build()
+
+	assert.NoError(t, err)
+	assert.Equal(t, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": "paid"}}},
+		{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$items"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$items.sku"},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$multiply", Value: bson.A{"$items.price", "$items.qty"}}}}}},
+			{Key: "orders", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "total", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$skip", Value: int64(10)}},
+		{{Key: "$limit", Value: int64(5)}},
+		{{Key: "$merge", Value: bson.D{{Key: "into", Value: "report"}, {Key: "on", Value: "_id"}, {Key: "whenMatched", Value: "replace"}}}},
+	}, pipeline)
+}
+
+func TestBuilder_Facet_Ok(t *testing.T) {
+	pipeline, err := New().
+		Facet(map[string]*Builder{
+			"top":   New().Sort(Desc("score")).Limit(3),
+			"count": New().Group(nil, Count("n")),
+		}).
+		Build()
+
+	assert.NoError(t, err)
+	assert.Len(t, pipeline, 1)
+
+	facet := pipeline[0][0].Value.(bson.D)
+	assert.Equal(t, "count", facet[0].Key)
+	assert.Equal(t, "top", facet[1].Key)
+}
+
+func TestBuilder_LookupPipeline_Ok(t *testing.T) {
+	pipeline, err := New().
+		LookupPipeline(
+			"orders",
+			bson.D{{Key: "user", Value: Field("_id")}},
+			New().Match(Expr(Eq(Field("user_id"), Variable("user")))).Project(Include("total"), Exclude("_id")),
+			"orders",
+		).
+		Out("users_orders").
+		Build()
+
+	assert.NoError(t, err)
+	assert.Len(t, pipeline, 2)
+	assert.Equal(t, "$lookup", pipeline[0][0].Key)
+	assert.Equal(t, bson.D{{Key: "$out", Value: "users_orders"}}, pipeline[1])
+}
+
+func TestBuilder_Build_Order_Error(t *testing.T) {
+	_, err := New().Out("report").Match(bson.M{}).Build()
+	assert.ErrorIs(t, err, ErrorStageOrder)
+
+	_, err = New().Match(bson.M{}).Stage(bson.D{{Key: "$geoNear", Value: bson.M{}}}).Build()
+	assert.ErrorIs(t, err, ErrorStageOrder)
+
+	_, err = New().Facet(map[string]*Builder{"out": New().Out("report")}).Build()
+	assert.ErrorIs(t, err, ErrorStageOrder)
+
+	_, err = New().LookupPipeline("orders", nil, New().Merge("report"), "orders").Build()
+	assert.ErrorIs(t, err, ErrorStageOrder)
+
+	_, err = New().Merge("report", MergeWhenMatched(New().Group(nil))).Build()
+	assert.ErrorIs(t, err, ErrorStageOrder)
+}
+
+func TestBuilder_Build_Invalid_Error(t *testing.T) {
+	_, err := New().Match(bson.M{}).Limit(0).Skip(1).Build()
+	assert.ErrorIs(t, err, ErrorStageInvalid)
+	assert.Contains(t, err.Error(), "stage 1")
+
+	_, err = New().Group(nil, Sum("_id", 1)).Build()
+	assert.ErrorIs(t, err, ErrorStageInvalid)
+
+	_, err = New().Sort(SortField{Name: "total", Direction: 2}).Build()
+	assert.ErrorIs(t, err, ErrorStageInvalid)
+
+	_, err = New().Bucket(Field("price"), []interface{}{0}, nil).Build()
+	assert.ErrorIs(t, err, ErrorStageInvalid)
+
+	_, err = New().Stage(bson.D{{Key: "sample", Value: bson.M{"size": 1}}}).Build()
+	assert.ErrorIs(t, err, ErrorStageInvalid)
+}
+
+type PipelineTestSuite struct {
+	suite.Suite
+	db database.Database
+}
+
+func Test_Pipeline(t *testing.T) {
+	suite.Run(t, new(PipelineTestSuite))
+}
+
+func (suite *PipelineTestSuite) SetupTest() {
+	db, err := database.New([]database.Option{database.Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *PipelineTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *PipelineTestSuite) TestBuilder_Aggregate_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+	_, err := collection.InsertMany(ctx, []interface{}{
+		bson.M{"field_string": "value1", "field_float": 1.5},
+		bson.M{"field_string": "value1", "field_float": 2.5},
+		bson.M{"field_string": "value2", "field_float": 10.0},
+	})
+	assert.NoError(suite.T(), err)
+
+	cursor, err := New().
+		Group(Field("field_string"), Sum("total", Field("field_float")), Count("count")).
+		Bucket(Field("total"), []interface{}{0, 5, 100}, "other", Push("groups", Field("_id"))).
+		Aggregate(ctx, collection)
+	assert.NoError(suite.T(), err)
+
+	var result []bson.M
+	assert.NoError(suite.T(), cursor.All(ctx, &result))
+	assert.Len(suite.T(), result, 2)
+	assert.Equal(suite.T(), bson.A{"value1"}, result[0]["groups"])
+	assert.Equal(suite.T(), bson.A{"value2"}, result[1]["groups"])
+}
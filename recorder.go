@@ -0,0 +1,512 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// Recording is single operation of the fixture written by Recorder. Args
+// contains named arguments of the operation with maps converted to documents
+// with sorted keys and options merged into single document without unset
+// fields. Result is empty for operations without result, Error contains
+// message of the returned error.
+type Recording struct {
+	Collection string        `bson:"collection"`
+	Method     string        `bson:"method"`
+	Args       bson.RawValue `bson:"args"`
+	Result     bson.RawValue `bson:"result"`
+	Error      string        `bson:"error"`
+}
+
+// Recorder wraps database and writes every collection operation and command
+// with its result to the fixture, one canonical Extended JSON document per
+// line, to be served by Replay in tests.
+//
+// Cursors of Find and Aggregate are read completely before they are returned,
+// so Recorder is meant for producing fixtures and not for production use.
+type Recorder struct {
+	Database
+	mx  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// updateResult has fields of mongo.UpdateResult, which is not decoded from
+// the document it is encoded to.
+type updateResult struct {
+	MatchedCount  int64       `bson:"matchedCount"`
+	ModifiedCount int64       `bson:"modifiedCount"`
+	UpsertedCount int64       `bson:"upsertedCount"`
+	UpsertedID    interface{} `bson:"upsertedId,omitempty"`
+}
+
+type recordingCollection struct {
+	CollectionInterface
+	recorder *Recorder
+}
+
+func NewRecorder(db Database, w io.Writer) *Recorder {
+	return &Recorder{Database: db, w: w}
+}
+
+// Err returns the first error of writing the fixture.
+func (m *Recorder) Err() error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return m.err
+}
+
+func (m *Recorder) Collection(name string) CollectionInterface {
+	return &recordingCollection{CollectionInterface: m.Database.Collection(name), recorder: m}
+}
+
+func (m *Recorder) RunCommand(
+	ctx context.Context,
+	cmd interface{},
+	opts ...*options.RunCmdOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "command", Value: normalizeDocument(cmd)},
+		{Key: "options", Value: recordOptions(options.MergeRunCmdOptions(opts...))},
+	}
+	res := m.Database.RunCommand(ctx, cmd, opts...)
+	raw, err := res.DecodeBytes()
+	m.record("", "RunCommand", args, raw, err)
+
+	return res
+}
+
+func (m *Recorder) record(collection, method string, args bson.D, result interface{}, err error) {
+	rec := bson.D{
+		{Key: "collection", Value: collection},
+		{Key: "method", Value: method},
+		{Key: "args", Value: args},
+	}
+
+	if !isNil(result) {
+		rec = append(rec, bson.E{Key: "result", Value: result})
+	}
+
+	if err != nil {
+		rec = append(rec, bson.E{Key: "error", Value: err.Error()})
+	}
+
+	line, err := bson.MarshalExtJSON(rec, true, false)
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if m.err != nil {
+		return
+	}
+
+	if err == nil {
+		_, err = m.w.Write(append(line, '\n'))
+	}
+
+	m.err = err
+}
+
+func (m *recordingCollection) Aggregate(
+	ctx context.Context,
+	pipeline interface{},
+	opts ...*options.AggregateOptions,
+) (CursorInterface, error) {
+	args := bson.D{
+		{Key: "pipeline", Value: normalizeDocument(pipeline)},
+		{Key: "options", Value: recordOptions(options.MergeAggregateOptions(opts...))},
+	}
+	cursor, err := m.CollectionInterface.Aggregate(ctx, pipeline, opts...)
+
+	return m.cursor(ctx, "Aggregate", args, cursor, err)
+}
+
+func (m *recordingCollection) CountDocuments(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.CountOptions,
+) (int64, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeCountOptions(opts...))},
+	}
+	count, err := m.CollectionInterface.CountDocuments(ctx, filter, opts...)
+	m.record("CountDocuments", args, count, err)
+
+	return count, err
+}
+
+func (m *recordingCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeDeleteOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.DeleteMany(ctx, filter, opts...)
+	m.record("DeleteMany", args, res, err)
+
+	return res, err
+}
+
+func (m *recordingCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeDeleteOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.DeleteOne(ctx, filter, opts...)
+	m.record("DeleteOne", args, res, err)
+
+	return res, err
+}
+
+func (m *recordingCollection) Distinct(
+	ctx context.Context,
+	fieldName string,
+	filter interface{},
+	opts ...*options.DistinctOptions,
+) ([]interface{}, error) {
+	args := bson.D{
+		{Key: "fieldName", Value: fieldName},
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeDistinctOptions(opts...))},
+	}
+	values, err := m.CollectionInterface.Distinct(ctx, fieldName, filter, opts...)
+	m.record("Distinct", args, bson.A(values), err)
+
+	return values, err
+}
+
+func (m *recordingCollection) Find(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (CursorInterface, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeFindOptions(opts...))},
+	}
+	cursor, err := m.CollectionInterface.Find(ctx, filter, opts...)
+
+	return m.cursor(ctx, "Find", args, cursor, err)
+}
+
+func (m *recordingCollection) FindOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneOptions(opts...))},
+	}
+	res := m.CollectionInterface.FindOne(ctx, filter, opts...)
+
+	return m.singleResult("FindOne", args, res)
+}
+
+func (m *recordingCollection) FindOneAndDelete(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneAndDeleteOptions(opts...))},
+	}
+	res := m.CollectionInterface.FindOneAndDelete(ctx, filter, opts...)
+
+	return m.singleResult("FindOneAndDelete", args, res)
+}
+
+func (m *recordingCollection) FindOneAndReplace(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "replacement", Value: normalizeDocument(replacement)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneAndReplaceOptions(opts...))},
+	}
+	res := m.CollectionInterface.FindOneAndReplace(ctx, filter, replacement, opts...)
+
+	return m.singleResult("FindOneAndReplace", args, res)
+}
+
+func (m *recordingCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "update", Value: normalizeDocument(update)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneAndUpdateOptions(opts...))},
+	}
+	res := m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, opts...)
+
+	return m.singleResult("FindOneAndUpdate", args, res)
+}
+
+func (m *recordingCollection) InsertMany(
+	ctx context.Context,
+	documents []interface{},
+	opts ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	args := bson.D{
+		{Key: "documents", Value: normalizeDocument(documents)},
+		{Key: "options", Value: recordOptions(options.MergeInsertManyOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.InsertMany(ctx, documents, opts...)
+	m.record("InsertMany", args, res, err)
+
+	return res, err
+}
+
+func (m *recordingCollection) InsertOne(
+	ctx context.Context,
+	document interface{},
+	opts ...*options.InsertOneOptions,
+) (*mongo.InsertOneResult, error) {
+	args := bson.D{
+		{Key: "document", Value: normalizeDocument(document)},
+		{Key: "options", Value: recordOptions(options.MergeInsertOneOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.InsertOne(ctx, document, opts...)
+	m.record("InsertOne", args, res, err)
+
+	return res, err
+}
+
+func (m *recordingCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "replacement", Value: normalizeDocument(replacement)},
+		{Key: "options", Value: recordOptions(options.MergeReplaceOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.ReplaceOne(ctx, filter, replacement, opts...)
+	m.record("ReplaceOne", args, (*updateResult)(res), err)
+
+	return res, err
+}
+
+func (m *recordingCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "update", Value: normalizeDocument(update)},
+		{Key: "options", Value: recordOptions(options.MergeUpdateOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.UpdateMany(ctx, filter, update, opts...)
+	m.record("UpdateMany", args, (*updateResult)(res), err)
+
+	return res, err
+}
+
+func (m *recordingCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "update", Value: normalizeDocument(update)},
+		{Key: "options", Value: recordOptions(options.MergeUpdateOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.UpdateOne(ctx, filter, update, opts...)
+	m.record("UpdateOne", args, (*updateResult)(res), err)
+
+	return res, err
+}
+
+func (m *recordingCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	args := bson.D{
+		{Key: "models", Value: recordModels(models)},
+		{Key: "options", Value: recordOptions(options.MergeBulkWriteOptions(opts...))},
+	}
+	res, err := m.CollectionInterface.BulkWrite(ctx, models, opts...)
+	m.record("BulkWrite", args, res, err)
+
+	return res, err
+}
+
+func (m *recordingCollection) Drop(ctx context.Context) error {
+	err := m.CollectionInterface.Drop(ctx)
+	m.record("Drop", bson.D{}, nil, err)
+
+	return err
+}
+
+func (m *recordingCollection) EstimatedDocumentCount(
+	ctx context.Context,
+	opts ...*options.EstimatedDocumentCountOptions,
+) (int64, error) {
+	args := bson.D{
+		{Key: "options", Value: recordOptions(options.MergeEstimatedDocumentCountOptions(opts...))},
+	}
+	count, err := m.CollectionInterface.EstimatedDocumentCount(ctx, opts...)
+	m.record("EstimatedDocumentCount", args, count, err)
+
+	return count, err
+}
+
+func (m *recordingCollection) Explain(
+	ctx context.Context,
+	op ExplainOperation,
+	filter interface{},
+	opts ...ExplainOption,
+) (*ExplainResult, error) {
+	args := explainArgs(op, filter, opts)
+	res, err := m.CollectionInterface.Explain(ctx, op, filter, opts...)
+
+	var raw bson.Raw
+
+	if res != nil {
+		raw = res.Raw
+	}
+
+	m.record("Explain", args, raw, err)
+
+	return res, err
+}
+
+func (m *recordingCollection) record(method string, args bson.D, result interface{}, err error) {
+	m.recorder.record(m.Name(), method, args, result, err)
+}
+
+func (m *recordingCollection) cursor(
+	ctx context.Context,
+	method string,
+	args bson.D,
+	cursor CursorInterface,
+	err error,
+) (CursorInterface, error) {
+	if err != nil {
+		m.record(method, args, nil, err)
+		return nil, err
+	}
+
+	var docs []bson.Raw
+	err = cursor.All(ctx, &docs)
+	m.record(method, args, docs, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &documentsCursor{docs: docs}, nil
+}
+
+func (m *recordingCollection) singleResult(method string, args bson.D, res SingleResultInterface) SingleResultInterface {
+	raw, err := res.DecodeBytes()
+	m.record(method, args, raw, err)
+
+	return res
+}
+
+// recordOptions converts options or write model to document without unset
+// fields with maps converted to documents with sorted keys.
+func recordOptions(opts interface{}) interface{} {
+	rv := reflect.ValueOf(opts)
+
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return normalizeDocument(opts)
+	}
+
+	cp := reflect.New(rv.Elem().Type())
+	cp.Elem().Set(rv.Elem())
+
+	for i := 0; i < cp.Elem().NumField(); i++ {
+		field := cp.Elem().Field(i)
+
+		if field.Kind() == reflect.Interface && field.CanSet() && !field.IsNil() {
+			field.Set(reflect.ValueOf(normalizeDocument(field.Interface())))
+		}
+	}
+
+	doc, err := toDocument(cp.Interface())
+
+	if err != nil {
+		// the error is returned by Recorder.Err when the recording is marshaled
+		return cp.Interface()
+	}
+
+	result := bson.D{}
+
+	for _, el := range doc {
+		if el.Value != nil {
+			result = append(result, el)
+		}
+	}
+
+	return result
+}
+
+func isNil(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(val)
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+
+	return false
+}
+
+func recordModels(models []mongo.WriteModel) bson.A {
+	result := make(bson.A, len(models))
+
+	for i, model := range models {
+		name := reflect.Indirect(reflect.ValueOf(model)).Type().Name()
+		result[i] = bson.D{{Key: name, Value: recordOptions(model)}}
+	}
+
+	return result
+}
+
+func explainArgs(op ExplainOperation, filter interface{}, options []ExplainOption) bson.D {
+	opts := &ExplainOptions{}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return bson.D{
+		{Key: "operation", Value: string(op)},
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(opts)},
+	}
+}
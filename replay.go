@@ -0,0 +1,693 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrorReplayNotRecorded  = errors.New("call is not recorded")
+	ErrorReplayMismatch     = errors.New("call does not match recording")
+	ErrorReplayNotSupported = errors.New("operation is not supported by replay")
+
+	ErrorCursorNotPositioned = errors.New("cursor is not positioned on document")
+)
+
+// Replay is database serving results of operations recorded by Recorder.
+// Every call consumes the first unused recording of the same collection,
+// method and arguments, so repeated calls are served in the recorded order.
+// Call without matching recording fails with ErrorReplayMismatch and diff
+// against the next unused recording of the same method or with
+// ErrorReplayNotRecorded when there is none.
+//
+// Recorded errors are replayed by message only, except mongo.ErrNoDocuments.
+// Indexes returns zero index view which must not be used, database methods
+// other than RunCommand fail with ErrorReplayNotSupported.
+type Replay struct {
+	mx         sync.Mutex
+	recordings []*Recording
+	used       []bool
+}
+
+type replayCollection struct {
+	replay *Replay
+	name   string
+}
+
+// documentsCursor iterates over documents read in advance.
+type documentsCursor struct {
+	docs []bson.Raw
+	pos  int
+}
+
+func NewReplay(r io.Reader) (*Replay, error) {
+	replay := &Replay{}
+	reader := bufio.NewReader(r)
+
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			rec := &Recording{}
+
+			if err := bson.UnmarshalExtJSON(data, true, rec); err != nil {
+				return nil, fmt.Errorf("fixture line %d: %w", line, err)
+			}
+
+			replay.recordings = append(replay.recordings, rec)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	replay.used = make([]bool, len(replay.recordings))
+	return replay, nil
+}
+
+// OpenReplay reads recordings from the fixture file.
+func OpenReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return NewReplay(file)
+}
+
+// Unused returns recordings which were not served yet.
+func (m *Replay) Unused() []*Recording {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	var result []*Recording
+
+	for i, rec := range m.recordings {
+		if !m.used[i] {
+			result = append(result, rec)
+		}
+	}
+
+	return result
+}
+
+func (m *Replay) Close() error {
+	return nil
+}
+
+func (m *Replay) Ping(_ context.Context) error {
+	return nil
+}
+
+func (m *Replay) StartSession(_ ...*options.SessionOptions) (mongo.Session, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) Drop() error {
+	return ErrorReplayNotSupported
+}
+
+func (m *Replay) Collection(name string) CollectionInterface {
+	return &replayCollection{replay: m, name: name}
+}
+
+func (m *Replay) GridFS(_ string) (BucketInterface, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) ListCollectionNames(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.ListCollectionsOptions,
+) ([]string, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) ListCollections(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.ListCollectionsOptions,
+) ([]*CollectionSpecification, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) CreateCollection(_ context.Context, _ string, _ ...CreateCollectionOption) error {
+	return ErrorReplayNotSupported
+}
+
+func (m *Replay) CreateView(_ context.Context, _, _ string, _ interface{}, _ *options.Collation) error {
+	return ErrorReplayNotSupported
+}
+
+func (m *Replay) RenameCollection(_ context.Context, _, _ string, _ bool) error {
+	return ErrorReplayNotSupported
+}
+
+func (m *Replay) RunCommand(
+	_ context.Context,
+	cmd interface{},
+	opts ...*options.RunCmdOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "command", Value: normalizeDocument(cmd)},
+		{Key: "options", Value: recordOptions(options.MergeRunCmdOptions(opts...))},
+	}
+
+	return m.singleResult("", "RunCommand", args)
+}
+
+func (m *Replay) RunCommandCursor(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.RunCmdOptions,
+) (CursorInterface, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) BuildInfo(_ context.Context) (*BuildInfo, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) ServerVersion(_ context.Context) (string, error) {
+	return "", ErrorReplayNotSupported
+}
+
+func (m *Replay) DbStats(_ context.Context) (*DbStats, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) CollStats(_ context.Context, _ string) (*CollStats, error) {
+	return nil, ErrorReplayNotSupported
+}
+
+func (m *Replay) CollMod(_ context.Context, _ string, _ ...CollModOption) error {
+	return ErrorReplayNotSupported
+}
+
+// call finds recording matching the call, decodes its result to the result
+// argument and returns recorded error.
+func (m *Replay) call(collection, method string, args bson.D, result interface{}) error {
+	data, err := bson.Marshal(bson.D{{Key: "args", Value: args}})
+
+	if err != nil {
+		return err
+	}
+
+	actual := bson.Raw(data).Lookup("args")
+	rec, err := m.match(collection, method, actual)
+
+	if err != nil {
+		return err
+	}
+
+	if result != nil && rec.Result.Type != 0 {
+		if err := rec.Result.Unmarshal(result); err != nil {
+			return err
+		}
+	}
+
+	switch rec.Error {
+	case "":
+		return nil
+	case mongo.ErrNoDocuments.Error():
+		return mongo.ErrNoDocuments
+	}
+
+	return errors.New(rec.Error)
+}
+
+func (m *Replay) match(collection, method string, actual bson.RawValue) (*Recording, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	var candidate *Recording
+
+	for i, rec := range m.recordings {
+		if m.used[i] || rec.Collection != collection || rec.Method != method {
+			continue
+		}
+
+		if bytes.Equal(rec.Args.Value, actual.Value) {
+			m.used[i] = true
+			return rec, nil
+		}
+
+		if candidate == nil {
+			candidate = rec
+		}
+	}
+
+	call := strings.TrimPrefix(collection+"."+method, ".")
+
+	if candidate == nil {
+		return nil, fmt.Errorf("%w: %s", ErrorReplayNotRecorded, call)
+	}
+
+	diff := diffValues("args", candidate.Args, actual)
+
+	if len(diff) == 0 {
+		diff = []string{"order of fields differs"}
+	}
+
+	return nil, fmt.Errorf("%w: %s\n%s", ErrorReplayMismatch, call, strings.Join(diff, "\n"))
+}
+
+func (m *Replay) singleResult(collection, method string, args bson.D) SingleResultInterface {
+	var raw bson.Raw
+	err := m.call(collection, method, args, &raw)
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return &cachedSingleResult{raw: raw}
+}
+
+func (m *Replay) cursor(collection, method string, args bson.D) (CursorInterface, error) {
+	var docs []bson.Raw
+	err := m.call(collection, method, args, &docs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &documentsCursor{docs: docs}, nil
+}
+
+func (m *replayCollection) Aggregate(
+	_ context.Context,
+	pipeline interface{},
+	opts ...*options.AggregateOptions,
+) (CursorInterface, error) {
+	args := bson.D{
+		{Key: "pipeline", Value: normalizeDocument(pipeline)},
+		{Key: "options", Value: recordOptions(options.MergeAggregateOptions(opts...))},
+	}
+
+	return m.replay.cursor(m.name, "Aggregate", args)
+}
+
+func (m *replayCollection) CountDocuments(
+	_ context.Context,
+	filter interface{},
+	opts ...*options.CountOptions,
+) (int64, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeCountOptions(opts...))},
+	}
+
+	var count int64
+	err := m.replay.call(m.name, "CountDocuments", args, &count)
+
+	return count, err
+}
+
+func (m *replayCollection) DeleteMany(
+	_ context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeDeleteOptions(opts...))},
+	}
+
+	res := &mongo.DeleteResult{}
+	err := m.replay.call(m.name, "DeleteMany", args, res)
+
+	return res, err
+}
+
+func (m *replayCollection) DeleteOne(
+	_ context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeDeleteOptions(opts...))},
+	}
+
+	res := &mongo.DeleteResult{}
+	err := m.replay.call(m.name, "DeleteOne", args, res)
+
+	return res, err
+}
+
+func (m *replayCollection) Distinct(
+	_ context.Context,
+	fieldName string,
+	filter interface{},
+	opts ...*options.DistinctOptions,
+) ([]interface{}, error) {
+	args := bson.D{
+		{Key: "fieldName", Value: fieldName},
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeDistinctOptions(opts...))},
+	}
+
+	var values []interface{}
+	err := m.replay.call(m.name, "Distinct", args, &values)
+
+	return values, err
+}
+
+func (m *replayCollection) Find(
+	_ context.Context,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (CursorInterface, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeFindOptions(opts...))},
+	}
+
+	return m.replay.cursor(m.name, "Find", args)
+}
+
+func (m *replayCollection) FindOne(
+	_ context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneOptions(opts...))},
+	}
+
+	return m.replay.singleResult(m.name, "FindOne", args)
+}
+
+func (m *replayCollection) FindOneAndDelete(
+	_ context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneAndDeleteOptions(opts...))},
+	}
+
+	return m.replay.singleResult(m.name, "FindOneAndDelete", args)
+}
+
+func (m *replayCollection) FindOneAndReplace(
+	_ context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "replacement", Value: normalizeDocument(replacement)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneAndReplaceOptions(opts...))},
+	}
+
+	return m.replay.singleResult(m.name, "FindOneAndReplace", args)
+}
+
+func (m *replayCollection) FindOneAndUpdate(
+	_ context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "update", Value: normalizeDocument(update)},
+		{Key: "options", Value: recordOptions(options.MergeFindOneAndUpdateOptions(opts...))},
+	}
+
+	return m.replay.singleResult(m.name, "FindOneAndUpdate", args)
+}
+
+func (m *replayCollection) InsertMany(
+	_ context.Context,
+	documents []interface{},
+	opts ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	args := bson.D{
+		{Key: "documents", Value: normalizeDocument(documents)},
+		{Key: "options", Value: recordOptions(options.MergeInsertManyOptions(opts...))},
+	}
+
+	res := &mongo.InsertManyResult{}
+	err := m.replay.call(m.name, "InsertMany", args, res)
+
+	return res, err
+}
+
+func (m *replayCollection) InsertOne(
+	_ context.Context,
+	document interface{},
+	opts ...*options.InsertOneOptions,
+) (*mongo.InsertOneResult, error) {
+	args := bson.D{
+		{Key: "document", Value: normalizeDocument(document)},
+		{Key: "options", Value: recordOptions(options.MergeInsertOneOptions(opts...))},
+	}
+
+	res := &mongo.InsertOneResult{}
+	err := m.replay.call(m.name, "InsertOne", args, res)
+
+	return res, err
+}
+
+func (m *replayCollection) ReplaceOne(
+	_ context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "replacement", Value: normalizeDocument(replacement)},
+		{Key: "options", Value: recordOptions(options.MergeReplaceOptions(opts...))},
+	}
+
+	res := &updateResult{}
+	err := m.replay.call(m.name, "ReplaceOne", args, res)
+
+	return (*mongo.UpdateResult)(res), err
+}
+
+func (m *replayCollection) UpdateMany(
+	_ context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "update", Value: normalizeDocument(update)},
+		{Key: "options", Value: recordOptions(options.MergeUpdateOptions(opts...))},
+	}
+
+	res := &updateResult{}
+	err := m.replay.call(m.name, "UpdateMany", args, res)
+
+	return (*mongo.UpdateResult)(res), err
+}
+
+func (m *replayCollection) UpdateOne(
+	_ context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	args := bson.D{
+		{Key: "filter", Value: normalizeDocument(filter)},
+		{Key: "update", Value: normalizeDocument(update)},
+		{Key: "options", Value: recordOptions(options.MergeUpdateOptions(opts...))},
+	}
+
+	res := &updateResult{}
+	err := m.replay.call(m.name, "UpdateOne", args, res)
+
+	return (*mongo.UpdateResult)(res), err
+}
+
+func (m *replayCollection) BulkWrite(
+	_ context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	args := bson.D{
+		{Key: "models", Value: recordModels(models)},
+		{Key: "options", Value: recordOptions(options.MergeBulkWriteOptions(opts...))},
+	}
+
+	res := &mongo.BulkWriteResult{}
+	err := m.replay.call(m.name, "BulkWrite", args, res)
+
+	return res, err
+}
+
+func (m *replayCollection) Indexes() mongo.IndexView {
+	return mongo.IndexView{}
+}
+
+func (m *replayCollection) Name() string {
+	return m.name
+}
+
+func (m *replayCollection) Drop(_ context.Context) error {
+	return m.replay.call(m.name, "Drop", bson.D{}, nil)
+}
+
+func (m *replayCollection) EstimatedDocumentCount(
+	_ context.Context,
+	opts ...*options.EstimatedDocumentCountOptions,
+) (int64, error) {
+	args := bson.D{
+		{Key: "options", Value: recordOptions(options.MergeEstimatedDocumentCountOptions(opts...))},
+	}
+
+	var count int64
+	err := m.replay.call(m.name, "EstimatedDocumentCount", args, &count)
+
+	return count, err
+}
+
+func (m *replayCollection) Explain(
+	_ context.Context,
+	op ExplainOperation,
+	filter interface{},
+	opts ...ExplainOption,
+) (*ExplainResult, error) {
+	var raw bson.Raw
+	err := m.replay.call(m.name, "Explain", explainArgs(op, filter, opts), &raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseExplain(raw), nil
+}
+
+func (m *documentsCursor) All(_ context.Context, results interface{}) error {
+	rv := reflect.ValueOf(results)
+
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results argument must be a pointer to a slice, but was a %s", rv.Kind())
+	}
+
+	slice := rv.Elem().Slice(0, 0)
+	elemType := slice.Type().Elem()
+
+	for ; m.pos < len(m.docs); m.pos++ {
+		elem := reflect.New(elemType)
+
+		if err := bson.Unmarshal(m.docs[m.pos], elem.Interface()); err != nil {
+			return err
+		}
+
+		slice = reflect.Append(slice, elem.Elem())
+	}
+
+	rv.Elem().Set(slice)
+	return nil
+}
+
+func (m *documentsCursor) Close(_ context.Context) error {
+	m.pos = len(m.docs)
+	return nil
+}
+
+func (m *documentsCursor) Decode(val interface{}) error {
+	if m.pos == 0 || m.pos > len(m.docs) {
+		return ErrorCursorNotPositioned
+	}
+
+	return bson.Unmarshal(m.docs[m.pos-1], val)
+}
+
+func (m *documentsCursor) Err() error {
+	return nil
+}
+
+func (m *documentsCursor) ID() int64 {
+	return 0
+}
+
+func (m *documentsCursor) Next(_ context.Context) bool {
+	if m.pos >= len(m.docs) {
+		return false
+	}
+
+	m.pos++
+	return true
+}
+
+func (m *documentsCursor) TryNext(ctx context.Context) bool {
+	return m.Next(ctx)
+}
+
+// diffValues describes differences of recorded and actual values by paths,
+// removed values are prefixed with "-" and added ones with "+".
+func diffValues(path string, recorded, actual bson.RawValue) []string {
+	if recorded.Type == actual.Type {
+		switch recorded.Type {
+		case bsontype.EmbeddedDocument:
+			return diffDocuments(path, recorded.Document(), actual.Document())
+		case bsontype.Array:
+			return diffDocuments(path, recorded.Array(), actual.Array())
+		}
+
+		if recorded.Equal(actual) {
+			return nil
+		}
+	}
+
+	return []string{
+		"- " + path + ": " + recorded.String(),
+		"+ " + path + ": " + actual.String(),
+	}
+}
+
+func diffDocuments(path string, recorded, actual bson.Raw) []string {
+	var diff []string
+	recordedElements, _ := recorded.Elements()
+	actualElements, _ := actual.Elements()
+
+	for _, el := range recordedElements {
+		key := path + "." + el.Key()
+		val, err := actual.LookupErr(el.Key())
+
+		if err != nil {
+			diff = append(diff, "- "+key+": "+el.Value().String())
+			continue
+		}
+
+		diff = append(diff, diffValues(key, el.Value(), val)...)
+	}
+
+	for _, el := range actualElements {
+		if _, err := recorded.LookupErr(el.Key()); err != nil {
+			diff = append(diff, "+ "+path+"."+el.Key()+": "+el.Value().String())
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+)
+
+type recordedDatabase struct {
+	Database
+	collection CollectionInterface
+}
+
+type recordedCollection struct {
+	CollectionInterface
+	docs []bson.Raw
+}
+
+func (m *recordedDatabase) Collection(_ string) CollectionInterface {
+	return m.collection
+}
+
+func (m *recordedCollection) Name() string {
+	return "stubs"
+}
+
+func (m *recordedCollection) Find(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.FindOptions,
+) (CursorInterface, error) {
+	return &documentsCursor{docs: m.docs}, nil
+}
+
+func (m *recordedCollection) FindOne(
+	_ context.Context,
+	filter interface{},
+	_ ...*options.FindOneOptions,
+) SingleResultInterface {
+	if filter.(bson.M)["field_string"] == "value1" {
+		return &cachedSingleResult{raw: m.docs[0]}
+	}
+
+	return &SingleResult{err: mongo.ErrNoDocuments}
+}
+
+func (m *recordedCollection) UpdateOne(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func (m *recordedCollection) CountDocuments(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.CountOptions,
+) (int64, error) {
+	return int64(len(m.docs)), nil
+}
+
+func newRecordedCollection(t *testing.T) *recordedCollection {
+	var docs []bson.Raw
+
+	for _, stub := range stubs {
+		raw, err := bson.Marshal(stub)
+		assert.NoError(t, err)
+		docs = append(docs, raw)
+	}
+
+	return &recordedCollection{docs: docs}
+}
+
+func replayStubs(t *testing.T, db Database) {
+	ctx := context.Background()
+	collection := db.Collection("stubs")
+	filter := bson.M{"field_string": "value1", "field_float": bson.M{"$gt": 0}}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"field_float": 1}).SetLimit(2))
+	assert.NoError(t, err)
+
+	var result []*Stub
+	assert.NoError(t, cursor.All(ctx, &result))
+	assert.Len(t, result, len(stubs))
+	assert.Equal(t, stubs[0], result[0])
+
+	stub := &Stub{}
+	err = collection.FindOne(ctx, bson.M{"field_string": "value1"}).Decode(stub)
+	assert.NoError(t, err)
+	assert.Equal(t, stubs[0], stub)
+
+	err = collection.FindOne(ctx, bson.M{"field_string": "unknown"}).Err()
+	assert.Equal(t, mongo.ErrNoDocuments, err)
+
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": primitive.NilObjectID}, bson.M{"$set": bson.M{"field_float": 1}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, res.MatchedCount)
+	assert.EqualValues(t, 1, res.ModifiedCount)
+
+	count, err := collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(stubs), count)
+}
+
+func TestReplay_Ok(t *testing.T) {
+	buf := &bytes.Buffer{}
+	recorder := NewRecorder(&recordedDatabase{collection: newRecordedCollection(t)}, buf)
+
+	replayStubs(t, recorder)
+	assert.NoError(t, recorder.Err())
+	assert.Equal(t, 5, bytes.Count(buf.Bytes(), []byte("\n")))
+
+	replay, err := NewReplay(buf)
+	assert.NoError(t, err)
+
+	replayStubs(t, replay)
+	assert.Empty(t, replay.Unused())
+}
+
+func TestReplay_Mismatch_Error(t *testing.T) {
+	buf := &bytes.Buffer{}
+	recorder := NewRecorder(&recordedDatabase{collection: newRecordedCollection(t)}, buf)
+
+	_, err := recorder.Collection("stubs").CountDocuments(context.Background(), bson.M{"field_string": "value1", "field_float": 1})
+	assert.NoError(t, err)
+
+	replay, err := NewReplay(buf)
+	assert.NoError(t, err)
+
+	_, err = replay.Collection("stubs").CountDocuments(context.Background(), bson.M{"field_string": "value2"}, options.Count().SetLimit(1))
+	assert.ErrorIs(t, err, ErrorReplayMismatch)
+	assert.Equal(
+		t,
+		ErrorReplayMismatch.Error()+": stubs.CountDocuments\n"+
+			`- args.filter.field_float: {"$numberInt":"1"}`+"\n"+
+			`- args.filter.field_string: "value1"`+"\n"+
+			`+ args.filter.field_string: "value2"`+"\n"+
+			`+ args.options.limit: {"$numberLong":"1"}`,
+		err.Error(),
+	)
+
+	_, err = replay.Collection("stubs").Find(context.Background(), bson.M{})
+	assert.ErrorIs(t, err, ErrorReplayNotRecorded)
+
+	assert.Len(t, replay.Unused(), 1)
+}
+
+func TestNewReplay_Error(t *testing.T) {
+	_, err := NewReplay(bytes.NewBufferString("{\"collection\": \"stubs\"}\n{"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "fixture line 2")
+}
+
+type ReplayTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Replay(t *testing.T) {
+	suite.Run(t, new(ReplayTestSuite))
+}
+
+func (suite *ReplayTestSuite) SetupTest() {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *ReplayTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *ReplayTestSuite) TestReplay_Aggregate_Ok() {
+	ctx := context.Background()
+	buf := &bytes.Buffer{}
+	recorder := NewRecorder(suite.db, buf)
+	pipeline := []bson.M{
+		{"$group": bson.M{"_id": "$field_string", "total": bson.M{"$sum": "$field_float"}}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	res, err := recorder.Collection("stubs").InsertMany(ctx, stubs)
+	assert.NoError(suite.T(), err)
+
+	cursor, err := recorder.Collection("stubs").Aggregate(ctx, pipeline)
+	assert.NoError(suite.T(), err)
+
+	var recorded []bson.M
+	assert.NoError(suite.T(), cursor.All(ctx, &recorded))
+	assert.NotEmpty(suite.T(), recorded)
+	assert.NoError(suite.T(), recorder.Err())
+
+	replay, err := NewReplay(buf)
+	assert.NoError(suite.T(), err)
+
+	replayed, err := replay.Collection("stubs").InsertMany(ctx, stubs)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), res.InsertedIDs, replayed.InsertedIDs)
+
+	cursor, err = replay.Collection("stubs").Aggregate(ctx, pipeline)
+	assert.NoError(suite.T(), err)
+
+	var result []bson.M
+	assert.NoError(suite.T(), cursor.All(ctx, &result))
+	assert.Equal(suite.T(), recorded, result)
+}
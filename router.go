@@ -0,0 +1,179 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	DefaultRouterCapacity = 100
+)
+
+var (
+	ErrorDatabaseNotSupported = errors.New("router requires database created with New")
+)
+
+type DatabaseResolver func(ctx context.Context) (string, error)
+
+// ProvisionFunc prepares tenant database on the first use, e.g. creates
+// indexes. It is called again when database handle was evicted from the
+// router cache, so it must be idempotent.
+type ProvisionFunc func(ctx context.Context, db Database) error
+
+type RouterOptions struct {
+	Resolver  DatabaseResolver
+	Provision ProvisionFunc
+	Capacity  int
+}
+
+type RouterOption func(*RouterOptions)
+
+func RouterResolver(resolver DatabaseResolver) RouterOption {
+	return func(opts *RouterOptions) {
+		opts.Resolver = resolver
+	}
+}
+
+func RouterProvision(provision ProvisionFunc) RouterOption {
+	return func(opts *RouterOptions) {
+		opts.Provision = provision
+	}
+}
+
+func RouterCapacity(capacity int) RouterOption {
+	return func(opts *RouterOptions) {
+		opts.Capacity = capacity
+	}
+}
+
+// TenantDatabaseResolver resolves database name as prefix followed by the
+// tenant taken from the context with WithTenant.
+func TenantDatabaseResolver(prefix string) DatabaseResolver {
+	return func(ctx context.Context) (string, error) {
+		tenant, ok := TenantFromContext(ctx)
+
+		if !ok {
+			return "", ErrorTenantRequired
+		}
+
+		return prefix + fmt.Sprint(tenant), nil
+	}
+}
+
+// Router resolves tenant database from the context. All databases share the
+// connection of the database router created with, handles of recently used
+// databases are kept in LRU cache.
+type Router struct {
+	db   *Mongodb
+	opts *RouterOptions
+
+	mx      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type routerEntry struct {
+	name string
+	db   *Mongodb
+	once sync.Once
+	err  error
+}
+
+func NewRouter(db Database, options ...RouterOption) (*Router, error) {
+	mdb, ok := db.(*Mongodb)
+
+	if !ok {
+		return nil, ErrorDatabaseNotSupported
+	}
+
+	opts := &RouterOptions{
+		Resolver: TenantDatabaseResolver(""),
+		Capacity: DefaultRouterCapacity,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	router := &Router{
+		db:      mdb,
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	return router, nil
+}
+
+func (m *Router) Database(ctx context.Context) (Database, error) {
+	name, err := m.opts.Resolver(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry := m.entry(name)
+	entry.once.Do(func() {
+		if m.opts.Provision != nil {
+			entry.err = m.opts.Provision(ctx, entry.db)
+		}
+	})
+
+	if entry.err != nil {
+		m.remove(entry)
+		return nil, entry.err
+	}
+
+	return entry.db, nil
+}
+
+func (m *Router) Collection(ctx context.Context, name string) (CollectionInterface, error) {
+	db, err := m.Database(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Collection(name), nil
+}
+
+func (m *Router) Len() int {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return m.lru.Len()
+}
+
+func (m *Router) entry(name string) *routerEntry {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if el, ok := m.entries[name]; ok {
+		m.lru.MoveToFront(el)
+		return el.Value.(*routerEntry)
+	}
+
+	entry := &routerEntry{name: name, db: m.db.sibling(name)}
+	m.entries[name] = m.lru.PushFront(entry)
+
+	for m.opts.Capacity > 0 && m.lru.Len() > m.opts.Capacity {
+		el := m.lru.Back()
+		m.lru.Remove(el)
+		delete(m.entries, el.Value.(*routerEntry).name)
+	}
+
+	return entry
+}
+
+func (m *Router) remove(entry *routerEntry) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if el, ok := m.entries[entry.name]; ok && el.Value == entry {
+		m.lru.Remove(el)
+		delete(m.entries, entry.name)
+	}
+}
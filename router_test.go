@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+)
+
+func newRouterTestDatabase(t *testing.T) *Mongodb {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	assert.NoError(t, err)
+
+	return &Mongodb{client: client, conn: &Options{}}
+}
+
+func TestRouter_Database_Ok(t *testing.T) {
+	provisioned := make(map[string]int)
+	router, err := NewRouter(
+		newRouterTestDatabase(t),
+		RouterCapacity(2),
+		RouterResolver(TenantDatabaseResolver("tenant_")),
+		RouterProvision(func(ctx context.Context, db Database) error {
+			provisioned[db.(*Mongodb).name]++
+			return nil
+		}),
+	)
+	assert.NoError(t, err)
+
+	ctx1 := WithTenant(context.Background(), 1)
+	db1, err := router.Database(ctx1)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant_1", db1.(*Mongodb).name)
+
+	db, err := router.Database(ctx1)
+	assert.NoError(t, err)
+	assert.Same(t, db1, db)
+	assert.Same(t, db1.Collection("stubs"), db.Collection("stubs"))
+
+	_, err = router.Database(WithTenant(context.Background(), 2))
+	assert.NoError(t, err)
+
+	_, err = router.Database(ctx1)
+	assert.NoError(t, err)
+
+	_, err = router.Database(WithTenant(context.Background(), 3))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, router.Len())
+
+	_, err = router.Database(WithTenant(context.Background(), 2))
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"tenant_1": 1, "tenant_2": 2, "tenant_3": 1}, provisioned)
+
+	err = db1.Close()
+	assert.NoError(t, err)
+}
+
+func TestRouter_Caching_Ok(t *testing.T) {
+	db := newRouterTestDatabase(t)
+	db.conn.Wrappers = map[string][]CollectionWrapper{
+		"stubs": {
+			func(db Database, _ CollectionInterface) CollectionInterface {
+				raw, _ := bson.Marshal(bson.M{"_id": "1", "tenant": db.(*Mongodb).name})
+				return &racingCollection{raw: raw}
+			},
+			Caching(NewMemoryCache()),
+		},
+	}
+	router, err := NewRouter(db, RouterResolver(TenantDatabaseResolver("tenant_")))
+	assert.NoError(t, err)
+
+	ctx1 := WithTenant(context.Background(), 1)
+	ctx2 := WithTenant(context.Background(), 2)
+	tenants := map[context.Context]string{ctx1: "tenant_1", ctx2: "tenant_2"}
+
+	for i := 0; i < 2; i++ {
+		for ctx, tenant := range tenants {
+			collection, err := router.Collection(ctx, "stubs")
+			assert.NoError(t, err)
+
+			raw, err := collection.FindOne(ctx, bson.M{"_id": "1"}).DecodeBytes()
+			assert.NoError(t, err)
+			assert.Equal(t, tenant, raw.Lookup("tenant").StringValue())
+		}
+	}
+
+	db1, err := router.Database(ctx1)
+	assert.NoError(t, err)
+	db1.(*Mongodb).forget("stubs")
+
+	collection, err := router.Collection(ctx2, "stubs")
+	assert.NoError(t, err)
+	assert.NoError(t, collection.FindOne(ctx2, bson.M{"_id": "1"}).Err())
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1}, collection.(*CachedCollection).Stats())
+}
+
+func TestRouter_Database_Error(t *testing.T) {
+	_, err := NewRouter(nil)
+	assert.Equal(t, ErrorDatabaseNotSupported, err)
+
+	expected := errors.New("provision failed")
+	calls := 0
+	router, err := NewRouter(newRouterTestDatabase(t), RouterProvision(func(ctx context.Context, db Database) error {
+		calls++
+
+		if calls == 1 {
+			return expected
+		}
+
+		return nil
+	}))
+	assert.NoError(t, err)
+
+	_, err = router.Database(context.Background())
+	assert.Equal(t, ErrorTenantRequired, err)
+
+	ctx := WithTenant(context.Background(), "tenant")
+	_, err = router.Database(ctx)
+	assert.Equal(t, expected, err)
+	assert.Equal(t, 0, router.Len())
+
+	_, err = router.Database(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRouter_Provision_Ok(t *testing.T) {
+	db, err := New([]Option{Dsn("mongodb://localhost:27017/test")}...)
+	assert.NoError(t, err)
+
+	router, err := NewRouter(db, RouterProvision(func(ctx context.Context, db Database) error {
+		_, err := db.Collection("stubs").Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"field_string": 1}})
+		return err
+	}))
+	assert.NoError(t, err)
+
+	ctx := WithTenant(context.Background(), "test_tenant")
+	collection, err := router.Collection(ctx, "stubs")
+	assert.NoError(t, err)
+
+	cursor, err := collection.Indexes().List(ctx)
+	assert.NoError(t, err)
+
+	var indexes []bson.M
+	err = cursor.All(ctx, &indexes)
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 2)
+
+	tenantDb, err := router.Database(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, tenantDb.Drop())
+	assert.NoError(t, tenantDb.Close())
+
+	assert.NoError(t, db.Ping(nil))
+	assert.NoError(t, db.Close())
+}
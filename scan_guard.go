@@ -0,0 +1,327 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultScanGuardSampleRate   = 0.01
+	DefaultScanGuardMinDocuments = 10000
+	DefaultScanGuardTimeout      = 5 * time.Second
+	DefaultScanGuardConcurrency  = 4
+)
+
+var (
+	ErrorCollectionScan = errors.New("query results in collection scan")
+)
+
+// ScanReport describes sampled query which resulted in collection scan.
+type ScanReport struct {
+	Collection string
+	Operation  ExplainOperation
+	Filter     interface{}
+	Documents  int64
+	Plan       *ExplainResult
+}
+
+type ScanGuardOptions struct {
+	SampleRate   float64
+	MinDocuments int64
+	Reject       bool
+	Reporter     func(report *ScanReport)
+	Timeout      time.Duration
+	Concurrency  int
+}
+
+type ScanGuardOption func(*ScanGuardOptions)
+
+// ScanGuardSampleRate sets part of queries to explain, from 0 to 1.
+func ScanGuardSampleRate(rate float64) ScanGuardOption {
+	return func(opts *ScanGuardOptions) {
+		opts.SampleRate = rate
+	}
+}
+
+// ScanGuardMinDocuments sets estimated number of documents of collection
+// below which collection scans are allowed.
+func ScanGuardMinDocuments(documents int64) ScanGuardOption {
+	return func(opts *ScanGuardOptions) {
+		opts.MinDocuments = documents
+	}
+}
+
+// ScanGuardReject makes sampled queries explained before execution, queries
+// resulting in collection scan are rejected with ErrorCollectionScan.
+func ScanGuardReject(reject bool) ScanGuardOption {
+	return func(opts *ScanGuardOptions) {
+		opts.Reject = reject
+	}
+}
+
+// ScanGuardReporter sets function called with every detected collection
+// scan. Without reject mode it is called from background goroutine.
+func ScanGuardReporter(reporter func(report *ScanReport)) ScanGuardOption {
+	return func(opts *ScanGuardOptions) {
+		opts.Reporter = reporter
+	}
+}
+
+// ScanGuardTimeout sets timeout of explain executed in background.
+func ScanGuardTimeout(timeout time.Duration) ScanGuardOption {
+	return func(opts *ScanGuardOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// ScanGuardConcurrency sets maximum number of explains executed in
+// background, sampled queries are not explained while the limit is reached.
+// DefaultScanGuardConcurrency is used when it is not positive.
+func ScanGuardConcurrency(concurrency int) ScanGuardOption {
+	return func(opts *ScanGuardOptions) {
+		opts.Concurrency = concurrency
+	}
+}
+
+// ScanGuardCollection explains a sample of queries and reports or rejects
+// the ones resulting in collection scan on collections with many documents.
+//
+// Explained filters are the ones ScanGuardCollection receives, so it should
+// be applied before wrappers which add conditions to the filter.
+type ScanGuardCollection struct {
+	CollectionInterface
+	opts     *ScanGuardOptions
+	explains chan struct{}
+}
+
+func NewScanGuardCollection(collection CollectionInterface, options ...ScanGuardOption) *ScanGuardCollection {
+	opts := &ScanGuardOptions{
+		SampleRate:   DefaultScanGuardSampleRate,
+		MinDocuments: DefaultScanGuardMinDocuments,
+		Timeout:      DefaultScanGuardTimeout,
+		Concurrency:  DefaultScanGuardConcurrency,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultScanGuardConcurrency
+	}
+
+	return &ScanGuardCollection{
+		CollectionInterface: collection,
+		opts:                opts,
+		explains:            make(chan struct{}, opts.Concurrency),
+	}
+}
+
+func ScanGuard(options ...ScanGuardOption) CollectionWrapper {
+	return func(_ Database, collection CollectionInterface) CollectionInterface {
+		return NewScanGuardCollection(collection, options...)
+	}
+}
+
+func (m *ScanGuardCollection) Aggregate(
+	ctx context.Context,
+	pipeline interface{},
+	opts ...*options.AggregateOptions,
+) (CursorInterface, error) {
+	opt := options.MergeAggregateOptions(opts...)
+	err := m.check(ctx, ExplainAggregate, pipeline, ExplainHint(opt.Hint))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.Aggregate(ctx, pipeline, opts...)
+}
+
+func (m *ScanGuardCollection) CountDocuments(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.CountOptions,
+) (int64, error) {
+	opt := options.MergeCountOptions(opts...)
+	err := m.check(ctx, ExplainCount, filter, ExplainHint(opt.Hint))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return m.CollectionInterface.CountDocuments(ctx, filter, opts...)
+}
+
+func (m *ScanGuardCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	err := m.check(ctx, ExplainDelete, filter, ExplainMulti(true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.DeleteMany(ctx, filter, opts...)
+}
+
+func (m *ScanGuardCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	err := m.check(ctx, ExplainDelete, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.DeleteOne(ctx, filter, opts...)
+}
+
+func (m *ScanGuardCollection) Find(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (CursorInterface, error) {
+	opt := options.MergeFindOptions(opts...)
+	err := m.check(ctx, ExplainFind, filter, ExplainSort(opt.Sort), ExplainHint(opt.Hint))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.Find(ctx, filter, opts...)
+}
+
+func (m *ScanGuardCollection) FindOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) SingleResultInterface {
+	opt := options.MergeFindOneOptions(opts...)
+	err := m.check(ctx, ExplainFind, filter, ExplainSort(opt.Sort), ExplainHint(opt.Hint), ExplainLimit(1))
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.CollectionInterface.FindOne(ctx, filter, opts...)
+}
+
+func (m *ScanGuardCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	err := m.check(ctx, ExplainUpdate, filter, ExplainUpdateDocument(update, true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateMany(ctx, filter, update, opts...)
+}
+
+func (m *ScanGuardCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	err := m.check(ctx, ExplainUpdate, filter, ExplainUpdateDocument(update, false))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateOne(ctx, filter, update, opts...)
+}
+
+// check explains sampled query, in reject mode synchronously outside of the
+// transaction of the context, otherwise in background.
+func (m *ScanGuardCollection) check(
+	ctx context.Context,
+	op ExplainOperation,
+	filter interface{},
+	opts ...ExplainOption,
+) error {
+	if m.opts.SampleRate <= 0 || rand.Float64() >= m.opts.SampleRate {
+		return nil
+	}
+
+	if m.opts.Reject {
+		return m.explain(detachedContext{ctx}, op, filter, opts)
+	}
+
+	select {
+	case m.explains <- struct{}{}:
+	default:
+		return nil
+	}
+
+	go func() {
+		defer func() {
+			<-m.explains
+		}()
+
+		// failure of sampling must not crash the process, the query itself
+		// is executed as usual
+		defer func() {
+			_ = recover()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+		defer cancel()
+
+		_ = m.explain(ctx, op, filter, opts)
+	}()
+
+	return nil
+}
+
+func (m *ScanGuardCollection) explain(
+	ctx context.Context,
+	op ExplainOperation,
+	filter interface{},
+	opts []ExplainOption,
+) error {
+	count, err := m.CollectionInterface.EstimatedDocumentCount(ctx)
+
+	if err != nil || count < m.opts.MinDocuments {
+		return nil
+	}
+
+	opts = append(opts, ExplainVerbosity(ExplainQueryPlanner))
+	plan, err := m.CollectionInterface.Explain(ctx, op, filter, opts...)
+
+	if err != nil || !plan.CollectionScan {
+		return nil
+	}
+
+	report := &ScanReport{
+		Collection: m.Name(),
+		Operation:  op,
+		Filter:     filter,
+		Documents:  count,
+		Plan:       plan,
+	}
+
+	if m.opts.Reporter != nil {
+		m.opts.Reporter(report)
+	}
+
+	if m.opts.Reject {
+		return fmt.Errorf("%w: %s %s", ErrorCollectionScan, op, m.Name())
+	}
+
+	return nil
+}
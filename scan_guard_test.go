@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type explainCollection struct {
+	CollectionInterface
+	count  int64
+	plan   *ExplainResult
+	filter interface{}
+}
+
+func (m *explainCollection) Name() string {
+	return "stubs"
+}
+
+func (m *explainCollection) EstimatedDocumentCount(
+	_ context.Context,
+	_ ...*options.EstimatedDocumentCountOptions,
+) (int64, error) {
+	return m.count, nil
+}
+
+func (m *explainCollection) Explain(
+	_ context.Context,
+	_ ExplainOperation,
+	filter interface{},
+	_ ...ExplainOption,
+) (*ExplainResult, error) {
+	m.filter = filter
+	return m.plan, nil
+}
+
+func (m *explainCollection) CountDocuments(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.CountOptions,
+) (int64, error) {
+	return m.count, nil
+}
+
+func TestScanGuardCollection_Reject_Error(t *testing.T) {
+	ctx := context.Background()
+	inner := &explainCollection{count: 100, plan: &ExplainResult{CollectionScan: true}}
+	var reports []*ScanReport
+	collection := NewScanGuardCollection(
+		inner,
+		ScanGuardSampleRate(1),
+		ScanGuardMinDocuments(100),
+		ScanGuardReject(true),
+		ScanGuardReporter(func(report *ScanReport) {
+			reports = append(reports, report)
+		}),
+	)
+
+	_, err := collection.CountDocuments(ctx, bson.M{"field": "value"})
+	assert.ErrorIs(t, err, ErrorCollectionScan)
+	assert.Len(t, reports, 1)
+	assert.Equal(t, "stubs", reports[0].Collection)
+	assert.Equal(t, ExplainCount, reports[0].Operation)
+	assert.EqualValues(t, 100, reports[0].Documents)
+
+	err = collection.FindOne(ctx, bson.M{}).Err()
+	assert.ErrorIs(t, err, ErrorCollectionScan)
+
+	inner.count = 99
+
+	count, err := collection.CountDocuments(ctx, bson.M{"field": "value"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 99, count)
+
+	inner.count = 100
+	inner.plan = &ExplainResult{}
+
+	_, err = collection.CountDocuments(ctx, bson.M{"field": "value"})
+	assert.NoError(t, err)
+	assert.Len(t, reports, 2)
+}
+
+func TestScanGuardCollection_Report_Ok(t *testing.T) {
+	reports := make(chan *ScanReport, 1)
+	collection := NewScanGuardCollection(
+		&explainCollection{count: 100, plan: &ExplainResult{CollectionScan: true}},
+		ScanGuardSampleRate(1),
+		ScanGuardMinDocuments(1),
+		ScanGuardReporter(func(report *ScanReport) {
+			reports <- report
+		}),
+	)
+
+	_, err := collection.CountDocuments(context.Background(), bson.M{})
+	assert.NoError(t, err)
+
+	select {
+	case report := <-reports:
+		assert.Equal(t, ExplainCount, report.Operation)
+	case <-time.After(time.Second):
+		assert.Fail(t, "collection scan is not reported")
+	}
+
+	collection.opts.SampleRate = 0
+
+	_, err = collection.CountDocuments(context.Background(), bson.M{})
+	assert.NoError(t, err)
+
+	select {
+	case <-reports:
+		assert.Fail(t, "not sampled query is reported")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+type blockingExplainCollection struct {
+	explainCollection
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingExplainCollection) Explain(
+	_ context.Context,
+	_ ExplainOperation,
+	_ interface{},
+	_ ...ExplainOption,
+) (*ExplainResult, error) {
+	m.started <- struct{}{}
+	<-m.release
+	panic("explain failed")
+}
+
+func TestScanGuardCollection_Concurrency_Ok(t *testing.T) {
+	ctx := context.Background()
+	inner := &blockingExplainCollection{
+		explainCollection: explainCollection{count: 100},
+		started:           make(chan struct{}, 2),
+		release:           make(chan struct{}),
+	}
+	collection := NewScanGuardCollection(inner, ScanGuardSampleRate(1), ScanGuardMinDocuments(1), ScanGuardConcurrency(1))
+
+	_, err := collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+
+	select {
+	case <-inner.started:
+	case <-time.After(time.Second):
+		assert.FailNow(t, "query is not explained")
+	}
+
+	_, err = collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+
+	close(inner.release)
+
+	select {
+	case <-inner.started:
+		assert.Fail(t, "explains over concurrency limit are executed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(collection.explains) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+
+	select {
+	case <-inner.started:
+	case <-time.After(time.Second):
+		assert.Fail(t, "query is not explained after panic of previous explain")
+	}
+}
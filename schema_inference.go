@@ -0,0 +1,518 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	DefaultInferSampleSize = 1000
+)
+
+var (
+	// aliases of BSON types used by $type operator
+	bsonTypeAliases = map[bsontype.Type]string{
+		bsontype.Double:           "double",
+		bsontype.String:           "string",
+		bsontype.EmbeddedDocument: "object",
+		bsontype.Array:            "array",
+		bsontype.Binary:           "binData",
+		bsontype.Undefined:        "undefined",
+		bsontype.ObjectID:         "objectId",
+		bsontype.Boolean:          "bool",
+		bsontype.DateTime:         "date",
+		bsontype.Null:             "null",
+		bsontype.Regex:            "regex",
+		bsontype.DBPointer:        "dbPointer",
+		bsontype.JavaScript:       "javascript",
+		bsontype.Symbol:           "symbol",
+		bsontype.CodeWithScope:    "javascriptWithScope",
+		bsontype.Int32:            "int",
+		bsontype.Timestamp:        "timestamp",
+		bsontype.Int64:            "long",
+		bsontype.Decimal128:       "decimal",
+		bsontype.MinKey:           "minKey",
+		bsontype.MaxKey:           "maxKey",
+	}
+	// Go types of BSON types, imports are added by the type prefix
+	goTypes = map[string]string{
+		"double":    "float64",
+		"string":    "string",
+		"binData":   "[]byte",
+		"objectId":  "primitive.ObjectID",
+		"bool":      "bool",
+		"date":      "time.Time",
+		"regex":     "primitive.Regex",
+		"int":       "int32",
+		"timestamp": "primitive.Timestamp",
+		"long":      "int64",
+		"decimal":   "primitive.Decimal128",
+	}
+	numericTypes = map[string]bool{"int": true, "long": true, "double": true}
+)
+
+type InferOptions struct {
+	SampleSize int64
+}
+
+type InferOption func(*InferOptions)
+
+// InferSampleSize sets number of documents sampled with $sample stage.
+func InferSampleSize(size int64) InferOption {
+	return func(opts *InferOptions) {
+		opts.SampleSize = size
+	}
+}
+
+// FieldSchema describes field of sampled documents by its dotted path,
+// fields of documents in arrays are described by path of the array, e.g.
+// "items.sku".
+type FieldSchema struct {
+	Path string
+	// Count is number of documents or embedded documents having the field.
+	Count int64
+	// Parents is number of documents or embedded documents which may have
+	// the field.
+	Parents int64
+	// Types are numbers of values by BSON type alias.
+	Types map[string]int64
+	// ItemTypes are numbers of array elements by BSON type alias.
+	ItemTypes map[string]int64
+	MinItems  int
+	MaxItems  int
+}
+
+// InferredSchema is schema of the collection inferred from sampled
+// documents.
+type InferredSchema struct {
+	Documents int64
+	Fields    []*FieldSchema
+}
+
+// InferSchema samples documents of the collection and infers paths, types,
+// optionality and array cardinality of their fields.
+func InferSchema(ctx context.Context, collection CollectionInterface, options ...InferOption) (*InferredSchema, error) {
+	opts := &InferOptions{SampleSize: DefaultInferSampleSize}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	pipeline := bson.A{bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: opts.SampleSize}}}}}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	inferrer := &schemaInferrer{fields: make(map[string]*FieldSchema), objects: make(map[string]int64)}
+
+	for cursor.Next(ctx) {
+		var raw bson.Raw
+
+		if err = cursor.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		if err = inferrer.document("", raw); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return inferrer.schema(), nil
+}
+
+// Optional reports whether the field is missing in some of documents.
+func (m *FieldSchema) Optional() bool {
+	return m.Count < m.Parents
+}
+
+func (m *FieldSchema) Nullable() bool {
+	return m.Types["null"] > 0
+}
+
+// Conflict reports whether values of the field have different types, null
+// is not a conflict.
+func (m *FieldSchema) Conflict() bool {
+	return len(nonNullTypes(m.Types)) > 1
+}
+
+// TypeNames returns BSON types of values of the field ordered by frequency.
+func (m *FieldSchema) TypeNames() []string {
+	return sortedTypes(m.Types)
+}
+
+// Field returns schema of the field by path.
+func (m *InferredSchema) Field(path string) *FieldSchema {
+	for _, field := range m.Fields {
+		if field.Path == path {
+			return field
+		}
+	}
+
+	return nil
+}
+
+// Conflicts returns fields with values of different types.
+func (m *InferredSchema) Conflicts() []*FieldSchema {
+	var result []*FieldSchema
+
+	for _, field := range m.Fields {
+		if field.Conflict() {
+			result = append(result, field)
+		}
+	}
+
+	return result
+}
+
+// GoSource generates Go source file of the package with struct of the given
+// name decoding sampled documents. Embedded documents get their own structs
+// named after the field, fields of conflicting types are interface{}, except
+// of numbers which are widened.
+func (m *InferredSchema) GoSource(pkg, name string) ([]byte, error) {
+	g := &structGenerator{schema: m, imports: make(map[string]bool), names: make(map[string]bool)}
+	g.generate(name, "")
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+
+		for path := range g.imports {
+			imports = append(imports, path)
+		}
+
+		sort.Strings(imports)
+		buf.WriteString("import (\n")
+
+		for _, path := range imports {
+			fmt.Fprintf(buf, "\t%q\n", path)
+		}
+
+		buf.WriteString(")\n\n")
+	}
+
+	buf.Write(g.buf.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+type schemaInferrer struct {
+	fields map[string]*FieldSchema
+	// objects are numbers of documents and embedded documents by path
+	objects map[string]int64
+}
+
+func (m *schemaInferrer) document(prefix string, doc bson.Raw) error {
+	m.objects[prefix]++
+	elements, err := doc.Elements()
+
+	if err != nil {
+		return err
+	}
+
+	for _, el := range elements {
+		path := el.Key()
+
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		field, ok := m.fields[path]
+
+		if !ok {
+			field = &FieldSchema{Path: path, Types: make(map[string]int64), MinItems: -1}
+			m.fields[path] = field
+		}
+
+		field.Count++
+
+		if err = m.value(field, el.Value()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *schemaInferrer) value(field *FieldSchema, val bson.RawValue) error {
+	field.Types[bsonTypeAliases[val.Type]]++
+
+	switch val.Type {
+	case bsontype.EmbeddedDocument:
+		return m.document(field.Path, val.Document())
+	case bsontype.Array:
+		items, err := val.Array().Values()
+
+		if err != nil {
+			return err
+		}
+
+		if field.ItemTypes == nil {
+			field.ItemTypes = make(map[string]int64)
+		}
+
+		if field.MinItems < 0 || len(items) < field.MinItems {
+			field.MinItems = len(items)
+		}
+
+		if len(items) > field.MaxItems {
+			field.MaxItems = len(items)
+		}
+
+		for _, item := range items {
+			field.ItemTypes[bsonTypeAliases[item.Type]]++
+
+			if item.Type == bsontype.EmbeddedDocument {
+				if err = m.document(field.Path, item.Document()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *schemaInferrer) schema() *InferredSchema {
+	schema := &InferredSchema{Documents: m.objects[""], Fields: make([]*FieldSchema, 0, len(m.fields))}
+
+	for path, field := range m.fields {
+		parent := ""
+
+		if i := strings.LastIndexByte(path, '.'); i >= 0 {
+			parent = path[:i]
+		}
+
+		field.Parents = m.objects[parent]
+
+		if field.MinItems < 0 {
+			field.MinItems = 0
+		}
+
+		schema.Fields = append(schema.Fields, field)
+	}
+
+	sort.Slice(schema.Fields, func(i, j int) bool {
+		return schema.Fields[i].Path < schema.Fields[j].Path
+	})
+
+	return schema
+}
+
+type structGenerator struct {
+	schema  *InferredSchema
+	buf     bytes.Buffer
+	imports map[string]bool
+	names   map[string]bool
+}
+
+// generate writes struct of fields of the path prefix and structs of its
+// embedded documents.
+func (m *structGenerator) generate(name, prefix string) {
+	m.names[name] = true
+	var nested [][2]string
+	body := &bytes.Buffer{}
+	fields := make(map[string]bool)
+
+	for _, field := range m.schema.Fields {
+		path := field.Path
+
+		if prefix != "" {
+			if !strings.HasPrefix(path, prefix+".") {
+				continue
+			}
+
+			path = path[len(prefix)+1:]
+		}
+
+		if strings.Contains(path, ".") {
+			continue
+		}
+
+		fieldName := uniqueName(goName(path), fields)
+		goType, object := m.fieldType(field)
+
+		if object {
+			structName := uniqueName(name+fieldName, m.names)
+			nested = append(nested, [2]string{structName, field.Path})
+			goType = strings.Replace(goType, "struct", structName, 1)
+		}
+
+		tag := path
+
+		if field.Optional() {
+			tag += ",omitempty"
+		}
+
+		fmt.Fprintf(body, "\t%s %s `bson:%q`\n", fieldName, goType, tag)
+	}
+
+	fmt.Fprintf(&m.buf, "type %s struct {\n%s}\n\n", name, body.String())
+
+	for _, n := range nested {
+		m.generate(n[0], n[1])
+	}
+}
+
+// fieldType returns Go type of the field, object reports that the type is
+// embedded document with "struct" placeholder of its name.
+func (m *structGenerator) fieldType(field *FieldSchema) (string, bool) {
+	types := nonNullTypes(field.Types)
+	pointer := field.Optional() || field.Nullable()
+
+	if len(types) == 1 && types[0] == "array" {
+		items := nonNullTypes(field.ItemTypes)
+
+		if len(items) == 1 && items[0] == "object" {
+			return "[]*struct", true
+		}
+
+		if itemType := m.goType(items); itemType != "" {
+			return "[]" + itemType, false
+		}
+
+		return "[]interface{}", false
+	}
+
+	if len(types) == 1 && types[0] == "object" {
+		return "*struct", true
+	}
+
+	goType := m.goType(types)
+
+	if goType == "" {
+		return "interface{}", false
+	}
+
+	if pointer && goType != "[]byte" {
+		goType = "*" + goType
+	}
+
+	return goType, false
+}
+
+// goType returns Go type of the scalar BSON types, numbers of different
+// types are widened.
+func (m *structGenerator) goType(types []string) string {
+	if len(types) > 1 {
+		widened := "int64"
+
+		for _, t := range types {
+			if !numericTypes[t] {
+				return ""
+			}
+
+			if t == "double" {
+				widened = "float64"
+			}
+		}
+
+		return widened
+	}
+
+	if len(types) == 0 {
+		return ""
+	}
+
+	goType, ok := goTypes[types[0]]
+
+	if !ok {
+		return ""
+	}
+
+	if strings.HasPrefix(goType, "primitive.") {
+		m.imports["go.mongodb.org/mongo-driver/bson/primitive"] = true
+	}
+
+	if strings.HasPrefix(goType, "time.") {
+		m.imports["time"] = true
+	}
+
+	return goType
+}
+
+func nonNullTypes(types map[string]int64) []string {
+	result := make([]string, 0, len(types))
+
+	for _, t := range sortedTypes(types) {
+		if t != "null" && t != "undefined" {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+func sortedTypes(types map[string]int64) []string {
+	result := make([]string, 0, len(types))
+
+	for t := range types {
+		result = append(result, t)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if types[result[i]] != types[result[j]] {
+			return types[result[i]] > types[result[j]]
+		}
+
+		return result[i] < result[j]
+	})
+
+	return result
+}
+
+// goName converts field key to exported Go identifier, e.g. "created_at" to
+// "CreatedAt" and "_id" to "Id".
+func goName(key string) string {
+	var b strings.Builder
+	upper := true
+
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+
+		b.WriteRune(r)
+	}
+
+	name := b.String()
+
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Field" + name
+	}
+
+	return name
+}
+
+func uniqueName(name string, names map[string]bool) string {
+	result := name
+
+	for i := 2; names[result]; i++ {
+		result = fmt.Sprintf("%s%d", name, i)
+	}
+
+	names[result] = true
+	return result
+}
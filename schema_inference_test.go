@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type sampledCollection struct {
+	CollectionInterface
+	docs     []bson.Raw
+	pipeline interface{}
+}
+
+func (m *sampledCollection) Aggregate(
+	_ context.Context,
+	pipeline interface{},
+	_ ...*options.AggregateOptions,
+) (CursorInterface, error) {
+	m.pipeline = pipeline
+	return &documentsCursor{docs: m.docs}, nil
+}
+
+func newSampledCollection(t *testing.T, docs ...interface{}) *sampledCollection {
+	collection := &sampledCollection{}
+
+	for _, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		assert.NoError(t, err)
+		collection.docs = append(collection.docs, raw)
+	}
+
+	return collection
+}
+
+func newInferredSchema(t *testing.T) *InferredSchema {
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	collection := newSampledCollection(
+		t,
+		bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "status", Value: "paid"},
+			{Key: "total", Value: int32(10)},
+			{Key: "created_at", Value: now},
+			{Key: "address", Value: bson.D{{Key: "city", Value: "Riga"}, {Key: "zip", Value: "LV-1010"}}},
+			{Key: "items", Value: bson.A{bson.D{{Key: "sku", Value: "A1"}, {Key: "qty", Value: int32(1)}}}},
+			{Key: "tags", Value: bson.A{"new", "sale"}},
+		},
+		bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "status", Value: int32(1)},
+			{Key: "total", Value: 12.5},
+			{Key: "created_at", Value: now},
+			{Key: "address", Value: nil},
+			{Key: "items", Value: bson.A{
+				bson.D{{Key: "sku", Value: "B1"}, {Key: "qty", Value: int32(2)}},
+				bson.D{{Key: "sku", Value: "B2"}},
+			}},
+			{Key: "note", Value: "call first"},
+		},
+	)
+
+	schema, err := InferSchema(context.Background(), collection, InferSampleSize(10))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: int64(10)}}}}}, collection.pipeline)
+
+	return schema
+}
+
+func TestInferSchema_Ok(t *testing.T) {
+	schema := newInferredSchema(t)
+	assert.EqualValues(t, 2, schema.Documents)
+
+	paths := make([]string, 0, len(schema.Fields))
+
+	for _, field := range schema.Fields {
+		paths = append(paths, field.Path)
+	}
+
+	assert.Equal(t, []string{
+		"_id", "address", "address.city", "address.zip", "created_at", "items", "items.qty", "items.sku", "note", "status", "tags", "total",
+	}, paths)
+
+	address := schema.Field("address")
+	assert.False(t, address.Optional())
+	assert.True(t, address.Nullable())
+	assert.False(t, address.Conflict())
+	assert.False(t, schema.Field("address.city").Optional())
+
+	items := schema.Field("items")
+	assert.Equal(t, 1, items.MinItems)
+	assert.Equal(t, 2, items.MaxItems)
+	assert.Equal(t, map[string]int64{"object": 3}, items.ItemTypes)
+	assert.EqualValues(t, 3, schema.Field("items.sku").Parents)
+	assert.True(t, schema.Field("items.qty").Optional())
+	assert.True(t, schema.Field("note").Optional())
+	assert.True(t, schema.Field("tags").Optional())
+
+	conflicts := schema.Conflicts()
+	assert.Len(t, conflicts, 2)
+	assert.Equal(t, "status", conflicts[0].Path)
+	assert.Equal(t, []string{"int", "string"}, conflicts[0].TypeNames())
+	assert.Equal(t, "total", conflicts[1].Path)
+	assert.Nil(t, schema.Field("unknown"))
+}
+
+func TestInferredSchema_GoSource_Ok(t *testing.T) {
+	src, err := newInferredSchema(t).GoSource("models", "Order")
+	assert.NoError(t, err)
+	expected, err := ioutil.ReadFile("testdata/order.go.golden")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(src))
+}
+
+func TestGoName_Ok(t *testing.T) {
+	assert.Equal(t, "Id", goName("_id"))
+	assert.Equal(t, "CreatedAt", goName("created_at"))
+	assert.Equal(t, "UserName", goName("user-name"))
+	assert.Equal(t, "Field1st", goName("1st"))
+	assert.Equal(t, "Field", goName("$"))
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	DefaultSoftDeleteField = "deleted_at"
+)
+
+type withDeletedKey struct{}
+
+// WithDeleted returns context which makes soft-delete collections include
+// deleted documents in read operations.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+func isWithDeleted(ctx context.Context) bool {
+	val, _ := ctx.Value(withDeletedKey{}).(bool)
+	return val
+}
+
+// SoftDeleteCollection marks documents as deleted by setting deletion time to
+// the configured field instead of removing them and hides such documents from
+// read operations.
+type SoftDeleteCollection struct {
+	CollectionInterface
+	field string
+}
+
+func NewSoftDeleteCollection(collection CollectionInterface, field string) *SoftDeleteCollection {
+	if field == "" {
+		field = DefaultSoftDeleteField
+	}
+
+	return &SoftDeleteCollection{CollectionInterface: collection, field: field}
+}
+
+func SoftDelete(field string) CollectionWrapper {
+	return func(_ Database, collection CollectionInterface) CollectionInterface {
+		return NewSoftDeleteCollection(collection, field)
+	}
+}
+
+func (m *SoftDeleteCollection) Aggregate(
+	ctx context.Context,
+	pipeline interface{},
+	opts ...*options.AggregateOptions,
+) (CursorInterface, error) {
+	if !isWithDeleted(ctx) {
+		var err error
+		pipeline, err = matchPipeline(pipeline, m.notDeleted())
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.CollectionInterface.Aggregate(ctx, pipeline, opts...)
+}
+
+// BulkWrite turns delete models into updates setting the deletion mark and
+// hides deleted documents from update and replace models, so soft deletions
+// are counted as modified documents of the result.
+func (m *SoftDeleteCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	result := make([]mongo.WriteModel, len(models))
+
+	for i, model := range models {
+		switch v := model.(type) {
+		case *mongo.UpdateOneModel:
+			model := *v
+			model.Filter = m.filter(ctx, v.Filter)
+			result[i] = &model
+		case *mongo.UpdateManyModel:
+			model := *v
+			model.Filter = m.filter(ctx, v.Filter)
+			result[i] = &model
+		case *mongo.ReplaceOneModel:
+			model := *v
+			model.Filter = m.filter(ctx, v.Filter)
+			result[i] = &model
+		case *mongo.DeleteOneModel:
+			result[i] = &mongo.UpdateOneModel{
+				Filter:    andFilter(v.Filter, m.notDeleted()),
+				Update:    m.update(),
+				Collation: v.Collation,
+				Hint:      v.Hint,
+			}
+		case *mongo.DeleteManyModel:
+			result[i] = &mongo.UpdateManyModel{
+				Filter:    andFilter(v.Filter, m.notDeleted()),
+				Update:    m.update(),
+				Collation: v.Collation,
+				Hint:      v.Hint,
+			}
+		default:
+			result[i] = model
+		}
+	}
+
+	return m.CollectionInterface.BulkWrite(ctx, result, opts...)
+}
+
+func (m *SoftDeleteCollection) CountDocuments(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.CountOptions,
+) (int64, error) {
+	return m.CollectionInterface.CountDocuments(ctx, m.filter(ctx, filter), opts...)
+}
+
+// EstimatedDocumentCount counts not deleted documents with CountDocuments,
+// since collection metadata includes deleted documents.
+func (m *SoftDeleteCollection) EstimatedDocumentCount(
+	ctx context.Context,
+	opts ...*options.EstimatedDocumentCountOptions,
+) (int64, error) {
+	if isWithDeleted(ctx) {
+		return m.CollectionInterface.EstimatedDocumentCount(ctx, opts...)
+	}
+
+	return m.CountDocuments(ctx, bson.D{}, estimatedToCountOptions(opts))
+}
+
+func (m *SoftDeleteCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	res, err := m.CollectionInterface.UpdateMany(ctx, andFilter(filter, m.notDeleted()), m.update(), deleteToUpdateOptions(opts))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &mongo.DeleteResult{DeletedCount: res.ModifiedCount}, nil
+}
+
+func (m *SoftDeleteCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	res, err := m.CollectionInterface.UpdateOne(ctx, andFilter(filter, m.notDeleted()), m.update(), deleteToUpdateOptions(opts))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &mongo.DeleteResult{DeletedCount: res.ModifiedCount}, nil
+}
+
+func (m *SoftDeleteCollection) Distinct(
+	ctx context.Context,
+	fieldName string,
+	filter interface{},
+	opts ...*options.DistinctOptions,
+) ([]interface{}, error) {
+	return m.CollectionInterface.Distinct(ctx, fieldName, m.filter(ctx, filter), opts...)
+}
+
+// Explain explains the operation with deleted documents hidden the same way
+// as the operation itself does.
+func (m *SoftDeleteCollection) Explain(
+	ctx context.Context,
+	op ExplainOperation,
+	filter interface{},
+	opts ...ExplainOption,
+) (*ExplainResult, error) {
+	var err error
+
+	switch op {
+	case ExplainAggregate:
+		if !isWithDeleted(ctx) {
+			filter, err = matchPipeline(filter, m.notDeleted())
+		}
+	case ExplainFind, ExplainCount:
+		filter = m.filter(ctx, filter)
+	case ExplainDelete:
+		filter = andFilter(filter, m.notDeleted())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.Explain(ctx, op, filter, opts...)
+}
+
+func (m *SoftDeleteCollection) Find(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (CursorInterface, error) {
+	return m.CollectionInterface.Find(ctx, m.filter(ctx, filter), opts...)
+}
+
+func (m *SoftDeleteCollection) FindOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) SingleResultInterface {
+	return m.CollectionInterface.FindOne(ctx, m.filter(ctx, filter), opts...)
+}
+
+func (m *SoftDeleteCollection) FindOneAndDelete(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) SingleResultInterface {
+	opt := options.MergeFindOneAndDeleteOptions(opts...)
+	updateOpt := options.FindOneAndUpdate()
+	updateOpt.Collation = opt.Collation
+	updateOpt.MaxTime = opt.MaxTime
+	updateOpt.Projection = opt.Projection
+	updateOpt.Sort = opt.Sort
+	updateOpt.Hint = opt.Hint
+
+	return m.CollectionInterface.FindOneAndUpdate(ctx, andFilter(filter, m.notDeleted()), m.update(), updateOpt)
+}
+
+// Restore removes deletion mark from soft-deleted documents matching filter.
+func (m *SoftDeleteCollection) Restore(ctx context.Context, filter interface{}) (*mongo.UpdateResult, error) {
+	update := bson.D{{Key: "$unset", Value: bson.D{{Key: m.field, Value: ""}}}}
+	return m.CollectionInterface.UpdateMany(ctx, andFilter(filter, m.deleted()), update)
+}
+
+// Purge physically removes soft-deleted documents matching filter.
+func (m *SoftDeleteCollection) Purge(ctx context.Context, filter interface{}) (*mongo.DeleteResult, error) {
+	return m.CollectionInterface.DeleteMany(ctx, andFilter(filter, m.deleted()))
+}
+
+func (m *SoftDeleteCollection) filter(ctx context.Context, filter interface{}) interface{} {
+	if isWithDeleted(ctx) {
+		if filter == nil {
+			return bson.D{}
+		}
+
+		return filter
+	}
+
+	return andFilter(filter, m.notDeleted())
+}
+
+func (m *SoftDeleteCollection) notDeleted() bson.D {
+	return bson.D{{Key: m.field, Value: nil}}
+}
+
+func (m *SoftDeleteCollection) deleted() bson.D {
+	return bson.D{{Key: m.field, Value: bson.D{{Key: "$ne", Value: nil}}}}
+}
+
+func (m *SoftDeleteCollection) update() bson.D {
+	return bson.D{{Key: "$set", Value: bson.D{{Key: m.field, Value: time.Now().UTC()}}}}
+}
+
+func deleteToUpdateOptions(opts []*options.DeleteOptions) *options.UpdateOptions {
+	opt := options.MergeDeleteOptions(opts...)
+	updateOpt := options.Update()
+	updateOpt.Collation = opt.Collation
+	updateOpt.Hint = opt.Hint
+
+	return updateOpt
+}
+
+func estimatedToCountOptions(opts []*options.EstimatedDocumentCountOptions) *options.CountOptions {
+	countOpt := options.Count()
+
+	for _, opt := range opts {
+		if opt != nil && opt.MaxTime != nil {
+			countOpt.MaxTime = opt.MaxTime
+		}
+	}
+
+	return countOpt
+}
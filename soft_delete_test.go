@@ -0,0 +1,248 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+type SoftDeleteTestSuite struct {
+	suite.Suite
+	db         Database
+	collection *SoftDeleteCollection
+}
+
+func Test_SoftDelete(t *testing.T) {
+	suite.Run(t, new(SoftDeleteTestSuite))
+}
+
+func (suite *SoftDeleteTestSuite) SetupTest() {
+	opts := []Option{
+		Dsn("mongodb://localhost:27017/test"),
+		WrapCollection("stubs", SoftDelete(DefaultSoftDeleteField)),
+	}
+	db, err := New(opts...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	res, err := db.Collection("stubs").InsertMany(context.Background(), stubs)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+	}
+
+	assert.Len(suite.T(), res.InsertedIDs, len(stubs))
+
+	suite.db = db
+	suite.collection = db.Collection("stubs").(*SoftDeleteCollection)
+}
+
+func (suite *SoftDeleteTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *SoftDeleteTestSuite) TestSoftDelete_DeleteMany_Ok() {
+	ctx := context.Background()
+
+	res, err := suite.collection.DeleteMany(ctx, bson.M{"field_string": "value1"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, res.DeletedCount)
+
+	count, err := suite.collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 6, count)
+
+	count, err = suite.collection.CountDocuments(WithDeleted(ctx), bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 9, count)
+
+	values, err := suite.collection.Distinct(ctx, "field_string", bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), values, 3)
+
+	res, err = suite.collection.DeleteMany(ctx, bson.M{"field_string": "value1"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, res.DeletedCount)
+}
+
+func (suite *SoftDeleteTestSuite) TestSoftDelete_DeleteOne_Ok() {
+	ctx := context.Background()
+
+	res, err := suite.collection.DeleteOne(ctx, bson.M{"field_string": "value4"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, res.DeletedCount)
+
+	err = suite.collection.FindOne(ctx, bson.M{"field_string": "value4"}).Err()
+	assert.Error(suite.T(), err)
+
+	var stub bson.M
+	err = suite.collection.FindOne(WithDeleted(ctx), bson.M{"field_string": "value4"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), stub[DefaultSoftDeleteField])
+}
+
+func (suite *SoftDeleteTestSuite) TestSoftDelete_BulkWrite_Ok() {
+	ctx := context.Background()
+	models := []mongo.WriteModel{
+		mongo.NewDeleteOneModel().SetFilter(bson.M{"field_string": "value4"}),
+		mongo.NewDeleteManyModel().SetFilter(bson.M{"field_string": "value1"}),
+		mongo.NewUpdateManyModel().SetFilter(bson.M{}).SetUpdate(bson.M{"$set": bson.M{"touched": true}}),
+	}
+
+	res, err := suite.collection.BulkWrite(ctx, models)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, res.DeletedCount)
+
+	count, err := suite.collection.CountDocuments(WithDeleted(ctx), bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 9, count)
+
+	count, err = suite.collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 5, count)
+
+	count, err = suite.collection.CountDocuments(WithDeleted(ctx), bson.M{"touched": true})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 5, count)
+}
+
+func (suite *SoftDeleteTestSuite) TestSoftDelete_FindOneAndDelete_Ok() {
+	ctx := context.Background()
+
+	var stub Stub
+	err := suite.collection.FindOneAndDelete(ctx, bson.M{"field_string": "value4"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "value4", stub.FieldString)
+
+	cursor, err := suite.collection.Find(ctx, bson.M{"field_string": "value4"})
+	assert.NoError(suite.T(), err)
+
+	var result []Stub
+	err = cursor.All(ctx, &result)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), result)
+}
+
+func (suite *SoftDeleteTestSuite) TestSoftDelete_Aggregate_Ok() {
+	ctx := context.Background()
+
+	_, err := suite.collection.DeleteMany(ctx, bson.M{"field_string": "value3"})
+	assert.NoError(suite.T(), err)
+
+	pipeline := []bson.M{
+		{
+			"$group": bson.M{
+				"_id":    "$field_string",
+				"amount": bson.M{"$sum": "$field_float"},
+			},
+		},
+	}
+	cursor, err := suite.collection.Aggregate(ctx, pipeline)
+	assert.NoError(suite.T(), err)
+
+	var result []bson.M
+	err = cursor.All(ctx, &result)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result, 3)
+
+	cursor, err = suite.collection.Aggregate(WithDeleted(ctx), pipeline)
+	assert.NoError(suite.T(), err)
+
+	err = cursor.All(ctx, &result)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result, 4)
+}
+
+func (suite *SoftDeleteTestSuite) TestSoftDelete_Aggregate_Error() {
+	_, err := suite.collection.Aggregate(context.Background(), bson.D{{Key: "$match", Value: bson.D{}}})
+	assert.ErrorIs(suite.T(), err, ErrorPipelineType)
+}
+
+func (suite *SoftDeleteTestSuite) TestSoftDelete_RestorePurge_Ok() {
+	ctx := context.Background()
+
+	_, err := suite.collection.DeleteMany(ctx, bson.M{"field_string": bson.M{"$in": []string{"value1", "value2"}}})
+	assert.NoError(suite.T(), err)
+
+	restored, err := suite.collection.Restore(ctx, bson.M{"field_string": "value1"})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, restored.ModifiedCount)
+
+	purged, err := suite.collection.Purge(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 2, purged.DeletedCount)
+
+	count, err := suite.collection.CountDocuments(WithDeleted(ctx), bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 7, count)
+}
+
+func TestSoftDeleteCollection_Explain_Ok(t *testing.T) {
+	inner := &explainCollection{plan: &ExplainResult{}}
+	collection := NewSoftDeleteCollection(inner, "")
+	ctx := context.Background()
+	cond := bson.D{{Key: DefaultSoftDeleteField, Value: nil}}
+
+	_, err := collection.Explain(ctx, ExplainFind, bson.M{"field": "value"})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "$and", Value: bson.A{bson.M{"field": "value"}, cond}}}, inner.filter)
+
+	_, err = collection.Explain(ctx, ExplainAggregate, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{bson.D{{Key: "$match", Value: cond}}}, inner.filter)
+
+	_, err = collection.Explain(WithDeleted(ctx), ExplainFind, bson.M{"field": "value"})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"field": "value"}, inner.filter)
+}
+
+func TestSoftDeleteCollection_BulkWrite_Ok(t *testing.T) {
+	inner := &bulkWriteCollection{}
+	collection := NewSoftDeleteCollection(inner, "")
+	cond := bson.D{{Key: DefaultSoftDeleteField, Value: nil}}
+	filter := bson.M{"field": "value"}
+	models := []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(bson.M{"field": "value"}),
+		mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{"$set": bson.M{"field": "value2"}}),
+		mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(bson.M{"field": "value2"}),
+		mongo.NewDeleteOneModel().SetFilter(filter),
+		mongo.NewDeleteManyModel().SetFilter(filter),
+	}
+
+	_, err := collection.BulkWrite(context.Background(), models)
+	assert.NoError(t, err)
+
+	batch := inner.batches[0]
+	assert.Equal(t, models[0], batch[0])
+
+	expected := bson.D{{Key: "$and", Value: bson.A{filter, cond}}}
+	assert.Equal(t, expected, batch[1].(*mongo.UpdateOneModel).Filter)
+	assert.Equal(t, expected, batch[2].(*mongo.ReplaceOneModel).Filter)
+
+	deleteOne, ok := batch[3].(*mongo.UpdateOneModel)
+	assert.True(t, ok)
+	assert.Equal(t, expected, deleteOne.Filter)
+	assert.Equal(t, "$set", deleteOne.Update.(bson.D)[0].Key)
+
+	deleteMany, ok := batch[4].(*mongo.UpdateManyModel)
+	assert.True(t, ok)
+	assert.Equal(t, expected, deleteMany.Filter)
+
+	// original models are not changed
+	assert.Equal(t, filter, models[1].(*mongo.UpdateOneModel).Filter)
+}
@@ -0,0 +1,536 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+const (
+	DefaultTenantField = "tenant_id"
+)
+
+var (
+	ErrorTenantRequired      = errors.New("tenant not found in context")
+	ErrorCrossTenantRequired = errors.New("operation affects all tenants and requires cross-tenant context")
+	ErrorTenantUpdate        = errors.New("update must not modify tenant field")
+)
+
+type tenantKey struct{}
+
+type crossTenantKey struct{}
+
+func WithTenant(ctx context.Context, tenant interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// CrossTenant returns context which allows operations on tenant collections
+// to access documents of all tenants.
+func CrossTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, crossTenantKey{}, true)
+}
+
+func isCrossTenant(ctx context.Context) bool {
+	val, _ := ctx.Value(crossTenantKey{}).(bool)
+	return val
+}
+
+// TenantCollection restricts all operations to documents of the tenant taken
+// from the context and stamps tenant to inserted and replaced documents.
+// Operations without tenant in context are rejected with ErrorTenantRequired
+// unless context is marked with CrossTenant.
+type TenantCollection struct {
+	CollectionInterface
+	field string
+}
+
+func NewTenantCollection(collection CollectionInterface, field string) *TenantCollection {
+	if field == "" {
+		field = DefaultTenantField
+	}
+
+	return &TenantCollection{CollectionInterface: collection, field: field}
+}
+
+func Tenancy(field string) CollectionWrapper {
+	return func(_ Database, collection CollectionInterface) CollectionInterface {
+		return NewTenantCollection(collection, field)
+	}
+}
+
+func (m *TenantCollection) Aggregate(
+	ctx context.Context,
+	pipeline interface{},
+	opts ...*options.AggregateOptions,
+) (CursorInterface, error) {
+	cond, err := m.cond(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cond != nil {
+		pipeline, err = matchPipeline(pipeline, cond)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.CollectionInterface.Aggregate(ctx, pipeline, opts...)
+}
+
+func (m *TenantCollection) CountDocuments(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.CountOptions,
+) (int64, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return m.CollectionInterface.CountDocuments(ctx, filter, opts...)
+}
+
+func (m *TenantCollection) DeleteMany(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.DeleteMany(ctx, filter, opts...)
+}
+
+func (m *TenantCollection) DeleteOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.DeleteOptions,
+) (*mongo.DeleteResult, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.DeleteOne(ctx, filter, opts...)
+}
+
+// Drop removes documents of all tenants, so it is allowed only with context
+// marked with CrossTenant.
+func (m *TenantCollection) Drop(ctx context.Context) error {
+	if !isCrossTenant(ctx) {
+		return ErrorCrossTenantRequired
+	}
+
+	return m.CollectionInterface.Drop(ctx)
+}
+
+// EstimatedDocumentCount counts documents of the tenant with CountDocuments,
+// since collection metadata has no counts per tenant.
+func (m *TenantCollection) EstimatedDocumentCount(
+	ctx context.Context,
+	opts ...*options.EstimatedDocumentCountOptions,
+) (int64, error) {
+	if isCrossTenant(ctx) {
+		return m.CollectionInterface.EstimatedDocumentCount(ctx, opts...)
+	}
+
+	return m.CountDocuments(ctx, bson.D{}, estimatedToCountOptions(opts))
+}
+
+func (m *TenantCollection) Distinct(
+	ctx context.Context,
+	fieldName string,
+	filter interface{},
+	opts ...*options.DistinctOptions,
+) ([]interface{}, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.Distinct(ctx, fieldName, filter, opts...)
+}
+
+// Explain explains the operation restricted to documents of the tenant, the
+// same way as the operation itself is restricted.
+func (m *TenantCollection) Explain(
+	ctx context.Context,
+	op ExplainOperation,
+	filter interface{},
+	opts ...ExplainOption,
+) (*ExplainResult, error) {
+	cond, err := m.cond(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cond != nil && op == ExplainAggregate {
+		filter, err = matchPipeline(filter, cond)
+	} else if cond != nil {
+		filter = andFilter(filter, cond)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.Explain(ctx, op, filter, opts...)
+}
+
+func (m *TenantCollection) Find(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (CursorInterface, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.Find(ctx, filter, opts...)
+}
+
+func (m *TenantCollection) FindOne(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneOptions,
+) SingleResultInterface {
+	filter, err := m.filter(ctx, filter)
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.CollectionInterface.FindOne(ctx, filter, opts...)
+}
+
+func (m *TenantCollection) FindOneAndDelete(
+	ctx context.Context,
+	filter interface{},
+	opts ...*options.FindOneAndDeleteOptions,
+) SingleResultInterface {
+	filter, err := m.filter(ctx, filter)
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.CollectionInterface.FindOneAndDelete(ctx, filter, opts...)
+}
+
+func (m *TenantCollection) FindOneAndReplace(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) SingleResultInterface {
+	filter, err := m.filter(ctx, filter)
+
+	if err == nil {
+		replacement, err = m.stamp(ctx, replacement)
+	}
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.CollectionInterface.FindOneAndReplace(ctx, filter, replacement, opts...)
+}
+
+func (m *TenantCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	filter, err := m.filter(ctx, filter)
+
+	if err == nil {
+		update, err = m.update(ctx, update)
+	}
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, opts...)
+}
+
+func (m *TenantCollection) InsertMany(
+	ctx context.Context,
+	documents []interface{},
+	opts ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	docs := make([]interface{}, len(documents))
+
+	for i, document := range documents {
+		doc, err := m.stamp(ctx, document)
+
+		if err != nil {
+			return nil, err
+		}
+
+		docs[i] = doc
+	}
+
+	return m.CollectionInterface.InsertMany(ctx, docs, opts...)
+}
+
+func (m *TenantCollection) InsertOne(
+	ctx context.Context,
+	document interface{},
+	opts ...*options.InsertOneOptions,
+) (*mongo.InsertOneResult, error) {
+	document, err := m.stamp(ctx, document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.InsertOne(ctx, document, opts...)
+}
+
+func (m *TenantCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err == nil {
+		replacement, err = m.stamp(ctx, replacement)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.ReplaceOne(ctx, filter, replacement, opts...)
+}
+
+func (m *TenantCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err == nil {
+		update, err = m.update(ctx, update)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateMany(ctx, filter, update, opts...)
+}
+
+func (m *TenantCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	filter, err := m.filter(ctx, filter)
+
+	if err == nil {
+		update, err = m.update(ctx, update)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (m *TenantCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	if _, err := m.cond(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make([]mongo.WriteModel, len(models))
+
+	for i, model := range models {
+		var err error
+
+		switch v := model.(type) {
+		case *mongo.InsertOneModel:
+			model := *v
+			model.Document, err = m.stamp(ctx, v.Document)
+			result[i] = &model
+		case *mongo.UpdateOneModel:
+			model := *v
+			model.Filter, err = m.filter(ctx, v.Filter)
+
+			if err == nil {
+				model.Update, err = m.update(ctx, v.Update)
+			}
+
+			result[i] = &model
+		case *mongo.UpdateManyModel:
+			model := *v
+			model.Filter, err = m.filter(ctx, v.Filter)
+
+			if err == nil {
+				model.Update, err = m.update(ctx, v.Update)
+			}
+
+			result[i] = &model
+		case *mongo.ReplaceOneModel:
+			model := *v
+			model.Filter, err = m.filter(ctx, v.Filter)
+
+			if err == nil {
+				model.Replacement, err = m.stamp(ctx, v.Replacement)
+			}
+
+			result[i] = &model
+		case *mongo.DeleteOneModel:
+			model := *v
+			model.Filter, err = m.filter(ctx, v.Filter)
+			result[i] = &model
+		case *mongo.DeleteManyModel:
+			model := *v
+			model.Filter, err = m.filter(ctx, v.Filter)
+			result[i] = &model
+		default:
+			result[i] = model
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.CollectionInterface.BulkWrite(ctx, result, opts...)
+}
+
+// cond returns tenant condition for filters or nil for cross-tenant context.
+func (m *TenantCollection) cond(ctx context.Context) (bson.D, error) {
+	if isCrossTenant(ctx) {
+		return nil, nil
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+
+	if !ok {
+		return nil, ErrorTenantRequired
+	}
+
+	return bson.D{{Key: m.field, Value: tenant}}, nil
+}
+
+func (m *TenantCollection) filter(ctx context.Context, filter interface{}) (interface{}, error) {
+	cond, err := m.cond(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cond == nil {
+		if filter == nil {
+			return bson.D{}, nil
+		}
+
+		return filter, nil
+	}
+
+	return andFilter(filter, cond), nil
+}
+
+// update rejects update documents modifying tenant field of the tenant
+// documents. Update pipelines can compute fields in many ways, so tenant is
+// set back by the stage added to the end of them instead.
+func (m *TenantCollection) update(ctx context.Context, update interface{}) (interface{}, error) {
+	cond, err := m.cond(ctx)
+
+	if err != nil || cond == nil {
+		return update, err
+	}
+
+	if isPipeline(update) {
+		return addUpdateOperator(update, "$set", m.field, cond[0].Value)
+	}
+
+	doc, err := toDocument(update)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range doc {
+		if !strings.HasPrefix(op.Key, "$") {
+			continue
+		}
+
+		fields, err := toDocument(op.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range fields {
+			target, _ := field.Value.(string)
+
+			if m.isTenantField(field.Key) || (op.Key == "$rename" && m.isTenantField(target)) {
+				return nil, fmt.Errorf("%w: %s %s", ErrorTenantUpdate, op.Key, field.Key)
+			}
+		}
+	}
+
+	return update, nil
+}
+
+func (m *TenantCollection) isTenantField(path string) bool {
+	return path == m.field || strings.HasPrefix(path, m.field+".")
+}
+
+// stamp sets tenant to the document. Documents written in cross-tenant context
+// without tenant are left as is.
+func (m *TenantCollection) stamp(ctx context.Context, document interface{}) (interface{}, error) {
+	tenant, ok := TenantFromContext(ctx)
+
+	if !ok {
+		if isCrossTenant(ctx) {
+			return document, nil
+		}
+
+		return nil, ErrorTenantRequired
+	}
+
+	doc, err := toDocument(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return set(doc, m.field, tenant), nil
+}
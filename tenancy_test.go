@@ -0,0 +1,229 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+type TenancyTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Tenancy(t *testing.T) {
+	suite.Run(t, new(TenancyTestSuite))
+}
+
+func (suite *TenancyTestSuite) SetupTest() {
+	opts := []Option{
+		Dsn("mongodb://localhost:27017/test"),
+		WrapCollection("stubs", Tenancy(DefaultTenantField)),
+	}
+	db, err := New(opts...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	for _, tenant := range []string{"tenant1", "tenant2"} {
+		_, err = db.Collection("stubs").InsertMany(WithTenant(context.Background(), tenant), stubs)
+
+		if err != nil {
+			assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+		}
+	}
+
+	suite.db = db
+}
+
+func (suite *TenancyTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *TenancyTestSuite) TestTenancy_Read_Ok() {
+	ctx := WithTenant(context.Background(), "tenant1")
+	collection := suite.db.Collection("stubs")
+
+	count, err := collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), len(stubs), count)
+
+	count, err = collection.CountDocuments(CrossTenant(context.Background()), bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 2*len(stubs), count)
+
+	values, err := collection.Distinct(ctx, DefaultTenantField, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []interface{}{"tenant1"}, values)
+
+	cursor, err := collection.Aggregate(ctx, []bson.M{{"$group": bson.M{"_id": "$" + DefaultTenantField}}})
+	assert.NoError(suite.T(), err)
+
+	var result []bson.M
+	err = cursor.All(ctx, &result)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result, 1)
+}
+
+func (suite *TenancyTestSuite) TestTenancy_Write_Ok() {
+	ctx := WithTenant(context.Background(), "tenant2")
+	collection := suite.db.Collection("stubs")
+
+	res, err := collection.UpdateMany(ctx, bson.M{"field_string": "value1"}, bson.M{"$set": bson.M{"field_float": 0}})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, res.ModifiedCount)
+
+	models := []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(&Stub{FieldString: "value5"}),
+		mongo.NewDeleteManyModel().SetFilter(bson.M{"field_string": "value2"}),
+	}
+	bulkRes, err := collection.BulkWrite(ctx, models)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 2, bulkRes.DeletedCount)
+
+	var stub bson.M
+	err = collection.FindOne(ctx, bson.M{"field_string": "value5"}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "tenant2", stub[DefaultTenantField])
+
+	err = collection.FindOne(WithTenant(context.Background(), "tenant1"), bson.M{"field_string": "value5"}).Err()
+	assert.Equal(suite.T(), mongo.ErrNoDocuments, err)
+
+	count, err := collection.CountDocuments(WithTenant(context.Background(), "tenant1"), bson.M{"field_float": 0})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, count)
+}
+
+func (suite *TenancyTestSuite) TestTenancy_WithoutTenant_Error() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.Find(ctx, bson.M{})
+	assert.Equal(suite.T(), ErrorTenantRequired, err)
+
+	_, err = collection.InsertOne(ctx, &Stub{})
+	assert.Equal(suite.T(), ErrorTenantRequired, err)
+
+	err = collection.FindOneAndDelete(ctx, bson.M{}).Err()
+	assert.Equal(suite.T(), ErrorTenantRequired, err)
+
+	_, err = collection.BulkWrite(ctx, []mongo.WriteModel{mongo.NewDeleteOneModel().SetFilter(bson.M{})})
+	assert.Equal(suite.T(), ErrorTenantRequired, err)
+}
+
+func TestTenantCollection_Filter_Ok(t *testing.T) {
+	collection := NewTenantCollection(nil, "")
+	filter := bson.M{"field": "value"}
+
+	result, err := collection.filter(WithTenant(context.Background(), "tenant"), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: DefaultTenantField, Value: "tenant"}}}}}, result)
+
+	result, err = collection.filter(CrossTenant(context.Background()), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, filter, result)
+
+	_, err = collection.filter(context.Background(), filter)
+	assert.Equal(t, ErrorTenantRequired, err)
+
+	doc, err := collection.stamp(WithTenant(context.Background(), "tenant"), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "field", Value: "value"}, {Key: DefaultTenantField, Value: "tenant"}}, doc)
+
+	doc, err = collection.stamp(CrossTenant(context.Background()), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, filter, doc)
+}
+
+func TestTenantCollection_Drop_Error(t *testing.T) {
+	collection := NewTenantCollection(nil, "")
+
+	err := collection.Drop(WithTenant(context.Background(), "tenant"))
+	assert.Equal(t, ErrorCrossTenantRequired, err)
+}
+
+func TestTenantCollection_Update_Ok(t *testing.T) {
+	collection := NewTenantCollection(nil, "")
+	ctx := WithTenant(context.Background(), "tenant")
+
+	update := bson.M{"$set": bson.M{"field": "value"}, "$rename": bson.M{"old": "new"}}
+	result, err := collection.update(ctx, update)
+	assert.NoError(t, err)
+	assert.Equal(t, update, result)
+
+	result, err = collection.update(ctx, mongo.Pipeline{{{Key: "$unset", Value: DefaultTenantField}}})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{
+		bson.D{{Key: "$unset", Value: DefaultTenantField}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: DefaultTenantField, Value: "tenant"}}}},
+	}, result)
+
+	update = bson.M{"$set": bson.M{DefaultTenantField: "other"}}
+	result, err = collection.update(CrossTenant(context.Background()), update)
+	assert.NoError(t, err)
+	assert.Equal(t, update, result)
+}
+
+func TestTenantCollection_Update_Error(t *testing.T) {
+	collection := NewTenantCollection(nil, "")
+	ctx := WithTenant(context.Background(), "tenant")
+	updates := []interface{}{
+		bson.M{"$set": bson.M{DefaultTenantField: "other"}},
+		bson.M{"$unset": bson.M{DefaultTenantField + ".nested": ""}},
+		bson.M{"$rename": bson.M{"other": DefaultTenantField}},
+		bson.M{"$rename": bson.M{DefaultTenantField: "other"}},
+	}
+
+	for _, update := range updates {
+		_, err := collection.update(ctx, update)
+		assert.ErrorIs(t, err, ErrorTenantUpdate)
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{}, updates[0])
+	assert.ErrorIs(t, err, ErrorTenantUpdate)
+
+	err = collection.FindOneAndUpdate(ctx, bson.M{}, updates[0]).Err()
+	assert.ErrorIs(t, err, ErrorTenantUpdate)
+
+	_, err = collection.BulkWrite(ctx, []mongo.WriteModel{mongo.NewUpdateManyModel().SetFilter(bson.M{}).SetUpdate(updates[1])})
+	assert.ErrorIs(t, err, ErrorTenantUpdate)
+}
+
+func TestTenantCollection_Aggregate_Error(t *testing.T) {
+	collection := NewTenantCollection(nil, "")
+
+	_, err := collection.Aggregate(WithTenant(context.Background(), "tenant"), bson.D{{Key: "$match", Value: bson.D{}}})
+	assert.ErrorIs(t, err, ErrorPipelineType)
+}
+
+func TestTenantCollection_Explain_Ok(t *testing.T) {
+	inner := &explainCollection{plan: &ExplainResult{}}
+	collection := NewTenantCollection(inner, "")
+	ctx := WithTenant(context.Background(), "tenant")
+	cond := bson.D{{Key: DefaultTenantField, Value: "tenant"}}
+
+	_, err := collection.Explain(ctx, ExplainFind, bson.M{"field": "value"})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "$and", Value: bson.A{bson.M{"field": "value"}, cond}}}, inner.filter)
+
+	_, err = collection.Explain(ctx, ExplainAggregate, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{bson.D{{Key: "$match", Value: cond}}}, inner.filter)
+
+	_, err = collection.Explain(context.Background(), ExplainFind, bson.M{})
+	assert.Equal(t, ErrorTenantRequired, err)
+}
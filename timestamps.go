@@ -0,0 +1,356 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	DefaultCreatedField = "created_at"
+	DefaultUpdatedField = "updated_at"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var SystemClock Clock = systemClock{}
+
+type TimestampsOptions struct {
+	CreatedField string
+	UpdatedField string
+	Clock        Clock
+}
+
+type TimestampsOption func(*TimestampsOptions)
+
+func TimestampsCreatedField(name string) TimestampsOption {
+	return func(opts *TimestampsOptions) {
+		opts.CreatedField = name
+	}
+}
+
+func TimestampsUpdatedField(name string) TimestampsOption {
+	return func(opts *TimestampsOptions) {
+		opts.UpdatedField = name
+	}
+}
+
+func TimestampsClock(clock Clock) TimestampsOption {
+	return func(opts *TimestampsOptions) {
+		opts.Clock = clock
+	}
+}
+
+// TimestampsCollection sets creation time of documents on inserts and upserts
+// and modification time on all inserts, updates and replaces, including
+// operations inside BulkWrite.
+type TimestampsCollection struct {
+	CollectionInterface
+	opts *TimestampsOptions
+}
+
+func NewTimestampsCollection(collection CollectionInterface, options ...TimestampsOption) *TimestampsCollection {
+	opts := &TimestampsOptions{
+		CreatedField: DefaultCreatedField,
+		UpdatedField: DefaultUpdatedField,
+		Clock:        SystemClock,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return &TimestampsCollection{CollectionInterface: collection, opts: opts}
+}
+
+func Timestamps(options ...TimestampsOption) CollectionWrapper {
+	return func(_ Database, collection CollectionInterface) CollectionInterface {
+		return NewTimestampsCollection(collection, options...)
+	}
+}
+
+func (m *TimestampsCollection) FindOneAndReplace(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.FindOneAndReplaceOptions,
+) SingleResultInterface {
+	opt := options.MergeFindOneAndReplaceOptions(opts...)
+	doc, pipeline, err := m.replacement(replacement, opt.Upsert != nil && *opt.Upsert, m.now())
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	if pipeline != nil {
+		updateOpts := &options.FindOneAndUpdateOptions{
+			BypassDocumentValidation: opt.BypassDocumentValidation,
+			Collation:                opt.Collation,
+			MaxTime:                  opt.MaxTime,
+			Projection:               opt.Projection,
+			ReturnDocument:           opt.ReturnDocument,
+			Sort:                     opt.Sort,
+			Upsert:                   opt.Upsert,
+			Hint:                     opt.Hint,
+		}
+		return m.CollectionInterface.FindOneAndUpdate(ctx, filter, pipeline, updateOpts)
+	}
+
+	return m.CollectionInterface.FindOneAndReplace(ctx, filter, doc, opts...)
+}
+
+func (m *TimestampsCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	upsert := options.MergeFindOneAndUpdateOptions(opts...).Upsert
+	update, err := m.update(update, upsert != nil && *upsert, m.now())
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	return m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, opts...)
+}
+
+func (m *TimestampsCollection) InsertMany(
+	ctx context.Context,
+	documents []interface{},
+	opts ...*options.InsertManyOptions,
+) (*mongo.InsertManyResult, error) {
+	now := m.now()
+	docs := make([]interface{}, len(documents))
+
+	for i, document := range documents {
+		doc, err := m.insert(document, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		docs[i] = doc
+	}
+
+	return m.CollectionInterface.InsertMany(ctx, docs, opts...)
+}
+
+func (m *TimestampsCollection) InsertOne(
+	ctx context.Context,
+	document interface{},
+	opts ...*options.InsertOneOptions,
+) (*mongo.InsertOneResult, error) {
+	doc, err := m.insert(document, m.now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.InsertOne(ctx, doc, opts...)
+}
+
+func (m *TimestampsCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	opt := options.MergeReplaceOptions(opts...)
+	doc, pipeline, err := m.replacement(replacement, opt.Upsert != nil && *opt.Upsert, m.now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pipeline != nil {
+		updateOpts := &options.UpdateOptions{
+			BypassDocumentValidation: opt.BypassDocumentValidation,
+			Collation:                opt.Collation,
+			Hint:                     opt.Hint,
+			Upsert:                   opt.Upsert,
+		}
+		return m.CollectionInterface.UpdateOne(ctx, filter, pipeline, updateOpts)
+	}
+
+	return m.CollectionInterface.ReplaceOne(ctx, filter, doc, opts...)
+}
+
+func (m *TimestampsCollection) UpdateMany(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	upsert := options.MergeUpdateOptions(opts...).Upsert
+	update, err := m.update(update, upsert != nil && *upsert, m.now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateMany(ctx, filter, update, opts...)
+}
+
+func (m *TimestampsCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	upsert := options.MergeUpdateOptions(opts...).Upsert
+	update, err := m.update(update, upsert != nil && *upsert, m.now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CollectionInterface.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (m *TimestampsCollection) BulkWrite(
+	ctx context.Context,
+	models []mongo.WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (*mongo.BulkWriteResult, error) {
+	now := m.now()
+	result := make([]mongo.WriteModel, len(models))
+
+	for i, model := range models {
+		var err error
+
+		switch v := model.(type) {
+		case *mongo.InsertOneModel:
+			model := *v
+			model.Document, err = m.insert(v.Document, now)
+			result[i] = &model
+		case *mongo.UpdateOneModel:
+			model := *v
+			model.Update, err = m.update(v.Update, v.Upsert != nil && *v.Upsert, now)
+			result[i] = &model
+		case *mongo.UpdateManyModel:
+			model := *v
+			model.Update, err = m.update(v.Update, v.Upsert != nil && *v.Upsert, now)
+			result[i] = &model
+		case *mongo.ReplaceOneModel:
+			var doc bson.D
+			var pipeline bson.A
+			doc, pipeline, err = m.replacement(v.Replacement, v.Upsert != nil && *v.Upsert, now)
+
+			if pipeline != nil {
+				result[i] = &mongo.UpdateOneModel{
+					Collation: v.Collation,
+					Upsert:    v.Upsert,
+					Filter:    v.Filter,
+					Update:    pipeline,
+					Hint:      v.Hint,
+				}
+				break
+			}
+
+			model := *v
+			model.Replacement = doc
+			result[i] = &model
+		default:
+			result[i] = model
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.CollectionInterface.BulkWrite(ctx, result, opts...)
+}
+
+func (m *TimestampsCollection) now() time.Time {
+	return m.opts.Clock.Now().UTC()
+}
+
+func (m *TimestampsCollection) insert(document interface{}, now time.Time) (interface{}, error) {
+	doc, err := toDocument(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc = set(doc, m.opts.CreatedField, now)
+	return set(doc, m.opts.UpdatedField, now), nil
+}
+
+// replacement keeps creation time passed in the replacement document. Replace
+// overwrites the whole stored document, so replacement without creation time
+// is returned as update pipeline which replaces the document keeping its
+// creation time, and setting it on insert when upsert is requested.
+func (m *TimestampsCollection) replacement(replacement interface{}, upsert bool, now time.Time) (bson.D, bson.A, error) {
+	doc, err := toDocument(replacement)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc = set(doc, m.opts.UpdatedField, now)
+
+	if created, ok := lookup(doc, m.opts.CreatedField); ok && !isZeroTime(created) {
+		return doc, nil, nil
+	}
+
+	var created interface{} = "$" + m.opts.CreatedField
+
+	if upsert {
+		created = bson.D{{Key: "$ifNull", Value: bson.A{created, now}}}
+	}
+
+	stored := bson.D{{Key: "_id", Value: "$_id"}, {Key: m.opts.CreatedField, Value: created}}
+	merge := bson.A{stored, bson.D{{Key: "$literal", Value: unset(doc, m.opts.CreatedField)}}}
+	stage := bson.D{{Key: "$replaceWith", Value: bson.D{{Key: "$mergeObjects", Value: merge}}}}
+
+	return nil, bson.A{stage}, nil
+}
+
+func (m *TimestampsCollection) update(update interface{}, upsert bool, now time.Time) (interface{}, error) {
+	if isPipeline(update) {
+		created := bson.D{{Key: "$ifNull", Value: bson.A{"$" + m.opts.CreatedField, now}}}
+		stage := bson.D{{Key: "$set", Value: bson.D{
+			{Key: m.opts.CreatedField, Value: created},
+			{Key: m.opts.UpdatedField, Value: now},
+		}}}
+
+		if !upsert {
+			stage = bson.D{{Key: "$set", Value: bson.D{{Key: m.opts.UpdatedField, Value: now}}}}
+		}
+
+		return append(toPipeline(update), stage), nil
+	}
+
+	update, err := addUpdateOperator(update, "$set", m.opts.UpdatedField, now)
+
+	if err != nil || !upsert {
+		return update, err
+	}
+
+	return addUpdateOperator(update, "$setOnInsert", m.opts.CreatedField, now)
+}
+
+func isZeroTime(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case time.Time:
+		return v.IsZero()
+	case interface{ Time() time.Time }:
+		return v.Time().IsZero()
+	}
+
+	return false
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+type frozenClock struct {
+	now time.Time
+}
+
+func (m *frozenClock) Now() time.Time {
+	return m.now
+}
+
+type TimestampedStub struct {
+	Id          string    `bson:"_id"`
+	FieldString string    `bson:"field_string"`
+	CreatedAt   time.Time `bson:"created_at"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+type TimestampsTestSuite struct {
+	suite.Suite
+	db    Database
+	clock *frozenClock
+}
+
+func Test_Timestamps(t *testing.T) {
+	suite.Run(t, new(TimestampsTestSuite))
+}
+
+func (suite *TimestampsTestSuite) SetupTest() {
+	suite.clock = &frozenClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	opts := []Option{
+		Dsn("mongodb://localhost:27017/test"),
+		WrapCollection("stubs", Timestamps(TimestampsClock(suite.clock))),
+	}
+	db, err := New(opts...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *TimestampsTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *TimestampsTestSuite) find(id string) *TimestampedStub {
+	var stub TimestampedStub
+	err := suite.db.Collection("stubs").FindOne(context.Background(), bson.M{"_id": id}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	return &stub
+}
+
+func (suite *TimestampsTestSuite) TestTimestamps_Insert_Ok() {
+	ctx := context.Background()
+	created := suite.clock.now
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertOne(ctx, &TimestampedStub{Id: "1"})
+	assert.NoError(suite.T(), err)
+
+	_, err = collection.InsertMany(ctx, []interface{}{bson.M{"_id": "2"}, bson.M{"_id": "3"}})
+	assert.NoError(suite.T(), err)
+
+	for _, id := range []string{"1", "2", "3"} {
+		stub := suite.find(id)
+		assert.True(suite.T(), created.Equal(stub.CreatedAt))
+		assert.True(suite.T(), created.Equal(stub.UpdatedAt))
+	}
+}
+
+func (suite *TimestampsTestSuite) TestTimestamps_Update_Ok() {
+	ctx := context.Background()
+	created := suite.clock.now
+	updated := created.Add(time.Hour)
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertOne(ctx, &TimestampedStub{Id: "1"})
+	assert.NoError(suite.T(), err)
+
+	suite.clock.now = updated
+
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": "1"}, bson.M{"$set": bson.M{"field_string": "value"}})
+	assert.NoError(suite.T(), err)
+
+	stub := suite.find("1")
+	assert.True(suite.T(), created.Equal(stub.CreatedAt))
+	assert.True(suite.T(), updated.Equal(stub.UpdatedAt))
+
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": "2"}, bson.M{"$set": bson.M{"field_string": "value"}}, options.Update().SetUpsert(true))
+	assert.NoError(suite.T(), err)
+
+	stub = suite.find("2")
+	assert.True(suite.T(), updated.Equal(stub.CreatedAt))
+	assert.True(suite.T(), updated.Equal(stub.UpdatedAt))
+
+	suite.clock.now = updated.Add(time.Hour)
+
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": "2"}, mongo.Pipeline{{{Key: "$set", Value: bson.M{"field_string": "pipeline"}}}}).Err()
+	assert.NoError(suite.T(), err)
+
+	stub = suite.find("2")
+	assert.True(suite.T(), updated.Equal(stub.CreatedAt))
+	assert.True(suite.T(), suite.clock.now.Equal(stub.UpdatedAt))
+	assert.Equal(suite.T(), "pipeline", stub.FieldString)
+}
+
+func (suite *TimestampsTestSuite) TestTimestamps_Replace_Ok() {
+	ctx := context.Background()
+	created := suite.clock.now
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertOne(ctx, &TimestampedStub{Id: "1"})
+	assert.NoError(suite.T(), err)
+
+	suite.clock.now = created.Add(time.Hour)
+
+	stub := suite.find("1")
+	stub.FieldString = "value"
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": "1"}, stub)
+	assert.NoError(suite.T(), err)
+
+	stub = suite.find("1")
+	assert.True(suite.T(), created.Equal(stub.CreatedAt))
+	assert.True(suite.T(), suite.clock.now.Equal(stub.UpdatedAt))
+}
+
+func (suite *TimestampsTestSuite) TestTimestamps_Replace_WithoutCreated_Ok() {
+	ctx := context.Background()
+	created := suite.clock.now
+	collection := suite.db.Collection("stubs")
+
+	_, err := collection.InsertOne(ctx, &TimestampedStub{Id: "1"})
+	assert.NoError(suite.T(), err)
+
+	suite.clock.now = created.Add(time.Hour)
+
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": "1"}, bson.M{"field_string": "value"})
+	assert.NoError(suite.T(), err)
+
+	stub := suite.find("1")
+	assert.True(suite.T(), created.Equal(stub.CreatedAt))
+	assert.True(suite.T(), suite.clock.now.Equal(stub.UpdatedAt))
+	assert.Equal(suite.T(), "value", stub.FieldString)
+
+	opts := options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After)
+	err = collection.FindOneAndReplace(ctx, bson.M{"_id": "1"}, bson.M{"field_string": "$value"}, opts).Decode(stub)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), created.Equal(stub.CreatedAt))
+	assert.Equal(suite.T(), "$value", stub.FieldString)
+}
+
+func (suite *TimestampsTestSuite) TestTimestamps_BulkWrite_Ok() {
+	ctx := context.Background()
+	now := suite.clock.now
+	models := []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(bson.M{"_id": "1"}),
+		mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": "2"}).SetUpdate(bson.M{"$set": bson.M{"field_string": "value"}}).SetUpsert(true),
+		mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": "3"}).SetReplacement(bson.M{"field_string": "value"}).SetUpsert(true),
+	}
+
+	_, err := suite.db.Collection("stubs").BulkWrite(ctx, models)
+	assert.NoError(suite.T(), err)
+
+	for _, id := range []string{"1", "2", "3"} {
+		stub := suite.find(id)
+		assert.True(suite.T(), now.Equal(stub.CreatedAt))
+		assert.True(suite.T(), now.Equal(stub.UpdatedAt))
+	}
+}
+
+func TestTimestampsCollection_Update_Ok(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	collection := NewTimestampsCollection(nil, TimestampsCreatedField("created"), TimestampsUpdatedField("updated"))
+
+	update, err := collection.update(bson.M{"$set": bson.M{"field": "value"}}, true, now)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "field", Value: "value"}, {Key: "updated", Value: primitive.NewDateTimeFromTime(now)}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "created", Value: now}}},
+	}, update)
+
+	update, err = collection.update(bson.M{"$set": bson.M{"field": "value"}}, false, now)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "field", Value: "value"}, {Key: "updated", Value: now}}},
+	}, update)
+
+	doc, err := collection.insert(bson.M{"field": "value"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "field", Value: "value"}, {Key: "created", Value: now}, {Key: "updated", Value: now}}, doc)
+}
+
+func TestTimestampsCollection_Replacement_Ok(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	collection := NewTimestampsCollection(nil, TimestampsCreatedField("created"), TimestampsUpdatedField("updated"))
+
+	doc, pipeline, err := collection.replacement(bson.D{{Key: "field", Value: "value"}, {Key: "created", Value: now}}, false, now)
+	assert.NoError(t, err)
+	assert.Nil(t, pipeline)
+	assert.Equal(t, bson.D{{Key: "field", Value: "value"}, {Key: "created", Value: primitive.NewDateTimeFromTime(now)}, {Key: "updated", Value: now}}, doc)
+
+	doc, pipeline, err = collection.replacement(bson.M{"field": "value"}, true, now)
+	assert.NoError(t, err)
+	assert.Nil(t, doc)
+	assert.Equal(t, bson.A{
+		bson.D{{Key: "$replaceWith", Value: bson.D{{Key: "$mergeObjects", Value: bson.A{
+			bson.D{{Key: "_id", Value: "$_id"}, {Key: "created", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$created", now}}}}},
+			bson.D{{Key: "$literal", Value: bson.D{{Key: "field", Value: "value"}, {Key: "updated", Value: now}}}},
+		}}}}},
+	}, pipeline)
+
+	_, pipeline, err = collection.replacement(bson.M{"field": "value"}, false, now)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "_id", Value: "$_id"}, {Key: "created", Value: "$created"}}, pipeline[0].(bson.D)[0].Value.(bson.D)[0].Value.(bson.A)[0])
+}
@@ -0,0 +1,390 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+const (
+	// DefaultUpsertBatchSize is maxWriteBatchSize of the server.
+	DefaultUpsertBatchSize = 100000
+	// DefaultUpsertBatchBytes is maxMessageSizeBytes of the server with room
+	// left for the command itself.
+	DefaultUpsertBatchBytes = 48000000 - 1024*1024
+
+	upsertLookupSize = 1000
+)
+
+var (
+	ErrorUpsertKeyFieldsRequired = errors.New("upsert key fields are required")
+	ErrorUpsertKeyFieldMissing   = errors.New("document has no upsert key field")
+	ErrorUpsertManyFailed        = errors.New("some documents were not upserted")
+)
+
+type UpsertMode int
+
+const (
+	// UpsertModeReplace replaces matched documents with the given ones.
+	UpsertModeReplace UpsertMode = iota
+	// UpsertModeSet sets fields of the given documents to matched documents
+	// keeping other fields.
+	UpsertModeSet
+)
+
+type UpsertStatus int
+
+const (
+	UpsertInserted UpsertStatus = iota + 1
+	UpsertUpdated
+	UpsertUnchanged
+	UpsertFailed
+)
+
+func (m UpsertStatus) String() string {
+	switch m {
+	case UpsertInserted:
+		return "inserted"
+	case UpsertUpdated:
+		return "updated"
+	case UpsertUnchanged:
+		return "unchanged"
+	case UpsertFailed:
+		return "failed"
+	}
+
+	return "unknown"
+}
+
+type UpsertItemResult struct {
+	Status     UpsertStatus
+	UpsertedID interface{}
+	Err        error
+}
+
+// UpsertManyResult contains result of every input document at the same
+// index and totals by status.
+type UpsertManyResult struct {
+	Items          []UpsertItemResult
+	InsertedCount  int64
+	UpdatedCount   int64
+	UnchangedCount int64
+	FailedCount    int64
+}
+
+type UpsertManyOptions struct {
+	Mode       UpsertMode
+	BatchSize  int
+	BatchBytes int
+}
+
+type UpsertManyOption func(*UpsertManyOptions)
+
+func UpsertManyMode(mode UpsertMode) UpsertManyOption {
+	return func(opts *UpsertManyOptions) {
+		opts.Mode = mode
+	}
+}
+
+func UpsertManyBatchSize(size int) UpsertManyOption {
+	return func(opts *UpsertManyOptions) {
+		opts.BatchSize = size
+	}
+}
+
+func UpsertManyBatchBytes(bytes int) UpsertManyOption {
+	return func(opts *UpsertManyOptions) {
+		opts.BatchBytes = bytes
+	}
+}
+
+type upsertItem struct {
+	index  int
+	key    string
+	filter bson.D
+	doc    bson.D
+	model  mongo.WriteModel
+	size   int
+}
+
+// UpsertMany upserts documents matched by values of key fields with unordered
+// bulk writes split into chunks fitting server limits. Documents equal to the
+// stored ones are not written and reported as unchanged. Failures of single
+// documents do not stop the others, ErrorUpsertManyFailed is returned with
+// the result when any document failed.
+func UpsertMany(
+	ctx context.Context,
+	collection CollectionInterface,
+	documents []interface{},
+	keyFields []string,
+	options ...UpsertManyOption,
+) (*UpsertManyResult, error) {
+	if len(keyFields) == 0 {
+		return nil, ErrorUpsertKeyFieldsRequired
+	}
+
+	opts := &UpsertManyOptions{
+		Mode:       UpsertModeReplace,
+		BatchSize:  DefaultUpsertBatchSize,
+		BatchBytes: DefaultUpsertBatchBytes,
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	result := &UpsertManyResult{Items: make([]UpsertItemResult, len(documents))}
+	var chunk []*upsertItem
+	chunkBytes := 0
+
+	for i, document := range documents {
+		item, err := newUpsertItem(i, document, keyFields, opts.Mode)
+
+		if err != nil {
+			result.Items[i] = UpsertItemResult{Status: UpsertFailed, Err: err}
+			continue
+		}
+
+		if len(chunk) > 0 && (len(chunk) >= opts.BatchSize || chunkBytes+item.size > opts.BatchBytes) {
+			upsertChunk(ctx, collection, chunk, keyFields, opts.Mode, result)
+			chunk = nil
+			chunkBytes = 0
+		}
+
+		chunk = append(chunk, item)
+		chunkBytes += item.size
+	}
+
+	if len(chunk) > 0 {
+		upsertChunk(ctx, collection, chunk, keyFields, opts.Mode, result)
+	}
+
+	for _, item := range result.Items {
+		switch item.Status {
+		case UpsertInserted:
+			result.InsertedCount++
+		case UpsertUpdated:
+			result.UpdatedCount++
+		case UpsertUnchanged:
+			result.UnchangedCount++
+		case UpsertFailed:
+			result.FailedCount++
+		}
+	}
+
+	if result.FailedCount > 0 {
+		return result, fmt.Errorf("%w: %d of %d", ErrorUpsertManyFailed, result.FailedCount, len(documents))
+	}
+
+	return result, nil
+}
+
+func newUpsertItem(index int, document interface{}, keyFields []string, mode UpsertMode) (*upsertItem, error) {
+	doc, err := toDocument(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filter := make(bson.D, 0, len(keyFields))
+
+	for _, field := range keyFields {
+		val, ok := lookupPath(doc, field)
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrorUpsertKeyFieldMissing, field)
+		}
+
+		filter = append(filter, bson.E{Key: field, Value: val})
+	}
+
+	key, err := bson.MarshalExtJSON(bson.D{{Key: "key", Value: filter}}, true, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := &upsertItem{index: index, key: string(key), filter: filter, doc: doc}
+
+	if mode == UpsertModeSet {
+		item.model = mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.D{{Key: "$set", Value: unset(doc, "_id")}}).
+			SetUpsert(true)
+	} else {
+		item.model = mongo.NewReplaceOneModel().
+			SetFilter(filter).
+			SetReplacement(doc).
+			SetUpsert(true)
+	}
+
+	item.size = writeModelSize(item.model)
+
+	return item, nil
+}
+
+func upsertChunk(
+	ctx context.Context,
+	collection CollectionInterface,
+	chunk []*upsertItem,
+	keyFields []string,
+	mode UpsertMode,
+	result *UpsertManyResult,
+) {
+	existing, err := upsertExisting(ctx, collection, chunk, keyFields)
+
+	if err != nil {
+		for _, item := range chunk {
+			result.Items[item.index] = UpsertItemResult{Status: UpsertFailed, Err: err}
+		}
+
+		return
+	}
+
+	var items []*upsertItem
+	var models []mongo.WriteModel
+
+	for _, item := range chunk {
+		if doc, ok := existing[item.key]; ok && upsertUnchanged(doc, item.doc, mode) {
+			result.Items[item.index] = UpsertItemResult{Status: UpsertUnchanged}
+			continue
+		}
+
+		items = append(items, item)
+		models = append(models, item.model)
+	}
+
+	if len(models) == 0 {
+		return
+	}
+
+	res, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	errs := bulkWriteErrors(err, len(models))
+
+	for i, item := range items {
+		switch {
+		case errs[i] != nil:
+			result.Items[item.index] = UpsertItemResult{Status: UpsertFailed, Err: errs[i]}
+		case res != nil && res.UpsertedIDs[int64(i)] != nil:
+			result.Items[item.index] = UpsertItemResult{Status: UpsertInserted, UpsertedID: res.UpsertedIDs[int64(i)]}
+		default:
+			result.Items[item.index] = UpsertItemResult{Status: UpsertUpdated}
+		}
+	}
+}
+
+// upsertExisting reads stored documents matching keys of the chunk.
+func upsertExisting(
+	ctx context.Context,
+	collection CollectionInterface,
+	chunk []*upsertItem,
+	keyFields []string,
+) (map[string]bson.D, error) {
+	existing := make(map[string]bson.D)
+
+	for start := 0; start < len(chunk); start += upsertLookupSize {
+		end := start + upsertLookupSize
+
+		if end > len(chunk) {
+			end = len(chunk)
+		}
+
+		filters := make(bson.A, 0, end-start)
+
+		for _, item := range chunk[start:end] {
+			filters = append(filters, item.filter)
+		}
+
+		cursor, err := collection.Find(ctx, bson.D{{Key: "$or", Value: filters}})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for cursor.Next(ctx) {
+			var doc bson.D
+
+			if err = cursor.Decode(&doc); err != nil {
+				_ = cursor.Close(ctx)
+				return nil, err
+			}
+
+			filter := make(bson.D, 0, len(keyFields))
+
+			for _, field := range keyFields {
+				val, _ := lookupPath(doc, field)
+				filter = append(filter, bson.E{Key: field, Value: val})
+			}
+
+			key, err := bson.MarshalExtJSON(bson.D{{Key: "key", Value: filter}}, true, false)
+
+			if err != nil {
+				_ = cursor.Close(ctx)
+				return nil, err
+			}
+
+			existing[string(key)] = doc
+		}
+
+		err = cursor.Err()
+		_ = cursor.Close(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return existing, nil
+}
+
+// upsertUnchanged reports whether writing of the document would not modify
+// the stored one.
+func upsertUnchanged(stored, doc bson.D, mode UpsertMode) bool {
+	if id, ok := lookup(doc, "_id"); ok && !bsonEqual(id, stored.Map()["_id"]) {
+		return false
+	}
+
+	if mode == UpsertModeSet {
+		for _, el := range doc {
+			val, ok := lookup(stored, el.Key)
+
+			if !ok || !bsonEqual(val, el.Value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return bsonEqual(unset(stored, "_id"), unset(doc, "_id"))
+}
+
+func bsonEqual(a, b interface{}) bool {
+	rawA, errA := bson.Marshal(bson.D{{Key: "v", Value: a}})
+	rawB, errB := bson.Marshal(bson.D{{Key: "v", Value: b}})
+
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
+
+// lookupPath returns value of the field by dotted path.
+func lookupPath(doc bson.D, path string) (interface{}, bool) {
+	parts := strings.SplitN(path, ".", 2)
+	val, ok := lookup(doc, parts[0])
+
+	if !ok || len(parts) == 1 {
+		return val, ok
+	}
+
+	sub, ok := val.(bson.D)
+
+	if !ok {
+		return nil, false
+	}
+
+	return lookupPath(sub, parts[1])
+}
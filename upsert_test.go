@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+type UpsertStub struct {
+	Sku   string  `bson:"sku"`
+	Shop  string  `bson:"shop"`
+	Price float64 `bson:"price"`
+}
+
+func TestLookupPath_Ok(t *testing.T) {
+	doc := bson.D{{Key: "a", Value: bson.D{{Key: "b", Value: 1}}}, {Key: "c", Value: 2}}
+
+	val, ok := lookupPath(doc, "a.b")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+
+	val, ok = lookupPath(doc, "c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+
+	_, ok = lookupPath(doc, "c.d")
+	assert.False(t, ok)
+}
+
+func TestNewUpsertItem_Ok(t *testing.T) {
+	item, err := newUpsertItem(0, &UpsertStub{Sku: "1", Shop: "2", Price: 10}, []string{"sku", "shop"}, UpsertModeReplace)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "sku", Value: "1"}, {Key: "shop", Value: "2"}}, item.filter)
+	assert.IsType(t, &mongo.ReplaceOneModel{}, item.model)
+	assert.Equal(t, writeModelSize(item.model), item.size)
+
+	item, err = newUpsertItem(0, bson.M{"_id": 1, "sku": "1"}, []string{"sku"}, UpsertModeSet)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "$set", Value: bson.D{{Key: "sku", Value: "1"}}}}, item.model.(*mongo.UpdateOneModel).Update)
+
+	_, err = newUpsertItem(0, &UpsertStub{Sku: "1"}, []string{"missing"}, UpsertModeReplace)
+	assert.ErrorIs(t, err, ErrorUpsertKeyFieldMissing)
+}
+
+func TestUpsertUnchanged_Ok(t *testing.T) {
+	stored := bson.D{{Key: "_id", Value: 1}, {Key: "sku", Value: "1"}, {Key: "price", Value: 10.0}}
+
+	assert.True(t, upsertUnchanged(stored, bson.D{{Key: "sku", Value: "1"}, {Key: "price", Value: 10.0}}, UpsertModeReplace))
+	assert.False(t, upsertUnchanged(stored, bson.D{{Key: "sku", Value: "1"}}, UpsertModeReplace))
+	assert.True(t, upsertUnchanged(stored, bson.D{{Key: "sku", Value: "1"}}, UpsertModeSet))
+	assert.False(t, upsertUnchanged(stored, bson.D{{Key: "price", Value: 11.0}}, UpsertModeSet))
+	assert.False(t, upsertUnchanged(stored, bson.D{{Key: "_id", Value: 2}, {Key: "sku", Value: "1"}}, UpsertModeSet))
+}
+
+type UpsertTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Upsert(t *testing.T) {
+	suite.Run(t, new(UpsertTestSuite))
+}
+
+func (suite *UpsertTestSuite) SetupTest() {
+	db, err := New(Dsn("mongodb://localhost:27017/test"))
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *UpsertTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *UpsertTestSuite) TestUpsertMany_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+	docs := []interface{}{
+		&UpsertStub{Sku: "1", Shop: "1", Price: 10},
+		&UpsertStub{Sku: "2", Shop: "1", Price: 20},
+		&UpsertStub{Sku: "3", Shop: "1", Price: 30},
+	}
+
+	res, err := UpsertMany(ctx, collection, docs, []string{"sku", "shop"}, UpsertManyBatchSize(2))
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, res.InsertedCount)
+
+	docs[1] = &UpsertStub{Sku: "2", Shop: "1", Price: 25}
+	docs = append(docs, &UpsertStub{Sku: "4", Shop: "1", Price: 40}, bson.M{"shop": "1"})
+
+	res, err = UpsertMany(ctx, collection, docs, []string{"sku", "shop"}, UpsertManyBatchSize(2))
+	assert.ErrorIs(suite.T(), err, ErrorUpsertManyFailed)
+	assert.Equal(suite.T(), UpsertUnchanged, res.Items[0].Status)
+	assert.Equal(suite.T(), UpsertUpdated, res.Items[1].Status)
+	assert.Equal(suite.T(), UpsertUnchanged, res.Items[2].Status)
+	assert.Equal(suite.T(), UpsertInserted, res.Items[3].Status)
+	assert.NotNil(suite.T(), res.Items[3].UpsertedID)
+	assert.Equal(suite.T(), UpsertFailed, res.Items[4].Status)
+	assert.ErrorIs(suite.T(), res.Items[4].Err, ErrorUpsertKeyFieldMissing)
+	assert.EqualValues(suite.T(), 1, res.FailedCount)
+
+	count, err := collection.CountDocuments(ctx, bson.M{})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 4, count)
+}
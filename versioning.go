@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultVersionField = "_v"
+)
+
+var (
+	ErrVersionConflict = errors.New("document version was changed by another request")
+)
+
+// VersionedCollection implements optimistic concurrency control. Version of
+// document is taken from the replacement document in ReplaceOne and from the
+// filter in UpdateOne and FindOneAndUpdate, matched against stored version
+// and incremented on each write.
+type VersionedCollection struct {
+	CollectionInterface
+	field string
+}
+
+func NewVersionedCollection(collection CollectionInterface, field string) *VersionedCollection {
+	if field == "" {
+		field = DefaultVersionField
+	}
+
+	return &VersionedCollection{CollectionInterface: collection, field: field}
+}
+
+func Versioning(field string) CollectionWrapper {
+	return func(_ Database, collection CollectionInterface) CollectionInterface {
+		return NewVersionedCollection(collection, field)
+	}
+}
+
+func (m *VersionedCollection) ReplaceOne(
+	ctx context.Context,
+	filter interface{},
+	replacement interface{},
+	opts ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	doc, err := toDocument(replacement)
+
+	if err != nil {
+		return nil, err
+	}
+
+	val, ok := lookup(doc, m.field)
+	version, _ := toInt64(val)
+	cond := bson.D{{Key: m.field, Value: version}}
+
+	if !ok {
+		cond = bson.D{{Key: m.field, Value: bson.D{{Key: "$exists", Value: false}}}}
+	}
+
+	doc = set(doc, m.field, version+1)
+	res, err := m.CollectionInterface.ReplaceOne(ctx, andFilter(filter, cond), doc, opts...)
+
+	if err != nil {
+		return nil, m.upsertConflict(err, func() error {
+			return m.conflict(ctx, filter)
+		})
+	}
+
+	if res.MatchedCount == 0 && res.UpsertedCount == 0 {
+		if err = m.conflict(ctx, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (m *VersionedCollection) UpdateOne(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.UpdateOptions,
+) (*mongo.UpdateResult, error) {
+	update, err := addUpdateOperator(update, "$inc", m.field, int64(1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := m.CollectionInterface.UpdateOne(ctx, filter, update, opts...)
+
+	if err != nil {
+		return nil, m.upsertConflict(err, func() error {
+			return m.filterConflict(ctx, filter)
+		})
+	}
+
+	if res.MatchedCount == 0 && res.UpsertedCount == 0 {
+		if err = m.filterConflict(ctx, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (m *VersionedCollection) FindOneAndUpdate(
+	ctx context.Context,
+	filter interface{},
+	update interface{},
+	opts ...*options.FindOneAndUpdateOptions,
+) SingleResultInterface {
+	update, err := addUpdateOperator(update, "$inc", m.field, int64(1))
+
+	if err != nil {
+		return &SingleResult{err: err}
+	}
+
+	res := m.CollectionInterface.FindOneAndUpdate(ctx, filter, update, opts...)
+
+	if res.Err() == mongo.ErrNoDocuments {
+		if err = m.filterConflict(ctx, filter); err != nil {
+			return &SingleResult{err: err}
+		}
+	}
+
+	if mongo.IsDuplicateKeyError(res.Err()) {
+		return &SingleResult{err: m.upsertConflict(res.Err(), func() error {
+			return m.filterConflict(ctx, filter)
+		})}
+	}
+
+	return res
+}
+
+// upsertConflict maps duplicate key error of upsert to ErrVersionConflict when
+// the document exists with another version, so upsert tried to insert it
+// again.
+func (m *VersionedCollection) upsertConflict(err error, conflict func() error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	if conflictErr := conflict(); errors.Is(conflictErr, ErrVersionConflict) {
+		return conflictErr
+	}
+
+	return err
+}
+
+// filterConflict checks whether the document was not matched because of
+// version passed in filter only.
+func (m *VersionedCollection) filterConflict(ctx context.Context, filter interface{}) error {
+	doc, err := toDocument(filter)
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := lookup(doc, m.field); !ok {
+		return nil
+	}
+
+	return m.conflict(ctx, unset(doc, m.field))
+}
+
+func (m *VersionedCollection) conflict(ctx context.Context, filter interface{}) error {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	count, err := m.CollectionInterface.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// RetryOnConflict calls fn until it finishes without version conflict or
+// attempts are exhausted. fn must reload the document on each call to get
+// its actual version.
+func RetryOnConflict(ctx context.Context, attempts int, fn func(ctx context.Context) error) error {
+	var err error
+
+	for i := 0; i < attempts; i++ {
+		err = fn(ctx)
+
+		if !errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+)
+
+type VersionedStub struct {
+	Id          string `bson:"_id"`
+	FieldString string `bson:"field_string"`
+	Version     int64  `bson:"_v"`
+}
+
+type VersioningTestSuite struct {
+	suite.Suite
+	db Database
+}
+
+func Test_Versioning(t *testing.T) {
+	suite.Run(t, new(VersioningTestSuite))
+}
+
+func (suite *VersioningTestSuite) SetupTest() {
+	opts := []Option{
+		Dsn("mongodb://localhost:27017/test"),
+		WrapCollection("stubs", Versioning(DefaultVersionField)),
+	}
+	db, err := New(opts...)
+
+	if err != nil {
+		assert.FailNow(suite.T(), "database init failed", "%v", err)
+	}
+
+	_, err = db.Collection("stubs").InsertOne(context.Background(), &VersionedStub{Id: "1", FieldString: "value1", Version: 1})
+
+	if err != nil {
+		assert.FailNow(suite.T(), "insert stub data to collection failed", "%v", err)
+	}
+
+	suite.db = db
+}
+
+func (suite *VersioningTestSuite) TearDownTest() {
+	err := suite.db.Drop()
+
+	if err != nil {
+		suite.FailNow("database deletion failed", "%v", err)
+	}
+
+	err = suite.db.Close()
+
+	if err != nil {
+		suite.FailNow("database closing failed", "%v", err)
+	}
+}
+
+func (suite *VersioningTestSuite) find(id string) *VersionedStub {
+	var stub VersionedStub
+	err := suite.db.Collection("stubs").FindOne(context.Background(), bson.M{"_id": id}).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	return &stub
+}
+
+func (suite *VersioningTestSuite) TestVersioning_ReplaceOne_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+	assert.IsType(suite.T(), &VersionedCollection{}, collection)
+
+	stub := suite.find("1")
+	stub.FieldString = "value2"
+
+	res, err := collection.ReplaceOne(ctx, bson.M{"_id": "1"}, stub)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, res.ModifiedCount)
+
+	stored := suite.find("1")
+	assert.EqualValues(suite.T(), 2, stored.Version)
+	assert.Equal(suite.T(), "value2", stored.FieldString)
+}
+
+func (suite *VersioningTestSuite) TestVersioning_ReplaceOne_Conflict() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	stub1 := suite.find("1")
+	stub2 := suite.find("1")
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": "1"}, stub1)
+	assert.NoError(suite.T(), err)
+
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": "1"}, stub2)
+	assert.Equal(suite.T(), ErrVersionConflict, err)
+
+	res, err := collection.ReplaceOne(ctx, bson.M{"_id": "unknown"}, stub2)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 0, res.MatchedCount)
+}
+
+func (suite *VersioningTestSuite) TestVersioning_Upsert_Conflict() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	stub := suite.find("1")
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": "1"}, stub)
+	assert.NoError(suite.T(), err)
+
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": "1"}, stub, options.Replace().SetUpsert(true))
+	assert.Equal(suite.T(), ErrVersionConflict, err)
+
+	update := bson.M{"$set": bson.M{"field_string": "value2"}}
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": "1", "_v": 1}, update, options.Update().SetUpsert(true))
+	assert.Equal(suite.T(), ErrVersionConflict, err)
+
+	res := collection.FindOneAndUpdate(ctx, bson.M{"_id": "1", "_v": 1}, update, options.FindOneAndUpdate().SetUpsert(true))
+	assert.Equal(suite.T(), ErrVersionConflict, res.Err())
+}
+
+func (suite *VersioningTestSuite) TestVersioning_UpdateOne_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": "1", "_v": 1}, bson.M{"$set": bson.M{"field_string": "value2"}})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 1, res.ModifiedCount)
+	assert.EqualValues(suite.T(), 2, suite.find("1").Version)
+
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": "1", "_v": 1}, bson.M{"$set": bson.M{"field_string": "value3"}})
+	assert.Equal(suite.T(), ErrVersionConflict, err)
+
+	res, err = collection.UpdateOne(ctx, bson.M{"_id": "1"}, bson.M{"$set": bson.M{"field_string": "value3"}})
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 3, suite.find("1").Version)
+}
+
+func (suite *VersioningTestSuite) TestVersioning_FindOneAndUpdate_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var stub VersionedStub
+	err := collection.FindOneAndUpdate(ctx, bson.M{"_id": "1", "_v": 1}, bson.M{"$set": bson.M{"field_string": "value2"}}, opts).Decode(&stub)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 2, stub.Version)
+
+	res := collection.FindOneAndUpdate(ctx, bson.M{"_id": "1", "_v": 1}, bson.M{"$set": bson.M{"field_string": "value3"}})
+	assert.Equal(suite.T(), ErrVersionConflict, res.Err())
+}
+
+func (suite *VersioningTestSuite) TestVersioning_RetryOnConflict_Ok() {
+	ctx := context.Background()
+	collection := suite.db.Collection("stubs")
+	stub := suite.find("1")
+	attempts := 0
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": "1"}, bson.M{"$set": bson.M{"field_string": "concurrent"}})
+	assert.NoError(suite.T(), err)
+
+	err = RetryOnConflict(ctx, 3, func(ctx context.Context) error {
+		attempts++
+
+		if attempts > 1 {
+			stub = suite.find("1")
+		}
+
+		stub.FieldString = "value2"
+		_, err := collection.ReplaceOne(ctx, bson.M{"_id": "1"}, stub)
+		return err
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, attempts)
+
+	stored := suite.find("1")
+	assert.Equal(suite.T(), "value2", stored.FieldString)
+	assert.EqualValues(suite.T(), 3, stored.Version)
+}
+
+type duplicateCollection struct {
+	CollectionInterface
+	count int64
+}
+
+func (m *duplicateCollection) ReplaceOne(
+	_ context.Context,
+	_ interface{},
+	_ interface{},
+	_ ...*options.ReplaceOptions,
+) (*mongo.UpdateResult, error) {
+	return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "duplicate key"}}}
+}
+
+func (m *duplicateCollection) CountDocuments(
+	_ context.Context,
+	_ interface{},
+	_ ...*options.CountOptions,
+) (int64, error) {
+	return m.count, nil
+}
+
+func TestVersionedCollection_ReplaceOne_Upsert_Error(t *testing.T) {
+	ctx := context.Background()
+	collection := NewVersionedCollection(&duplicateCollection{count: 1}, "")
+	upsert := options.Replace().SetUpsert(true)
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": "1"}, bson.M{"_id": "1", "_v": 1}, upsert)
+	assert.Equal(t, ErrVersionConflict, err)
+
+	collection = NewVersionedCollection(&duplicateCollection{}, "")
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": "1"}, bson.M{"_id": "1", "_v": 1}, upsert)
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+}
+
+func TestRetryOnConflict_Error(t *testing.T) {
+	attempts := 0
+	err := RetryOnConflict(context.Background(), 3, func(ctx context.Context) error {
+		attempts++
+		return ErrVersionConflict
+	})
+	assert.Equal(t, ErrVersionConflict, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	expected := errors.New("unexpected error")
+	err = RetryOnConflict(context.Background(), 3, func(ctx context.Context) error {
+		attempts++
+		return expected
+	})
+	assert.Equal(t, expected, err)
+	assert.Equal(t, 1, attempts)
+}